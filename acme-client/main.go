@@ -6,6 +6,8 @@ import (
 	"github.com/stbuehler/go-acme-client/command_certificate"
 	"github.com/stbuehler/go-acme-client/command_certificate_show"
 	"github.com/stbuehler/go-acme-client/command_register"
+	"github.com/stbuehler/go-acme-client/command_sign_bundle"
+	"github.com/stbuehler/go-acme-client/command_submit_bundle"
 	"github.com/stbuehler/go-acme-client/ui"
 	"os"
 )
@@ -20,6 +22,8 @@ func main() {
 		println("\tauthorize-import")
 		println("\tcertificate")
 		println("\tcertificate-show")
+		println("\tsign-bundle")
+		println("\tsubmit-bundle")
 		os.Exit(1)
 	} else {
 		switch os.Args[1] {
@@ -33,6 +37,10 @@ func main() {
 			command_certificate.Run(ui.CLI, os.Args[2:])
 		case "certificate-show":
 			command_certificate_show.Run(ui.CLI, os.Args[2:])
+		case "sign-bundle":
+			command_sign_bundle.Run(ui.CLI, os.Args[2:])
+		case "submit-bundle":
+			command_submit_bundle.Run(ui.CLI, os.Args[2:])
 		default:
 			println("Unknown subcommand: " + os.Args[1])
 			os.Exit(1)
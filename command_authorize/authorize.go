@@ -4,20 +4,47 @@ import (
 	"flag"
 	"fmt"
 	"github.com/stbuehler/go-acme-client/command_base"
+	"github.com/stbuehler/go-acme-client/model"
+	_ "github.com/stbuehler/go-acme-client/solver_dns_rfc2136"
+	_ "github.com/stbuehler/go-acme-client/solver_dns_server"
+	_ "github.com/stbuehler/go-acme-client/solver_dns_zonefile"
+	_ "github.com/stbuehler/go-acme-client/solver_email"
+	_ "github.com/stbuehler/go-acme-client/solver_exec"
+	"github.com/stbuehler/go-acme-client/solver_interface"
+	"github.com/stbuehler/go-acme-client/storage_interface"
 	"github.com/stbuehler/go-acme-client/types"
 	"github.com/stbuehler/go-acme-client/ui"
 	"github.com/stbuehler/go-acme-client/utils"
+	_ "github.com/stbuehler/go-acme-client/webroot"
 	"strconv"
+	"strings"
+	"time"
 )
 
 var register_flags = flag.NewFlagSet("register", flag.ExitOnError)
 
+var solverChoices solver_interface.Choices
+var validationWait time.Duration
+var automatic bool
+var showHistory bool
+var preference string
+
 func init() {
+	register_flags.Var(&solverChoices, "solver", "Solver to use for a challenge type as TYPE=NAME (can be repeated); defaults to the first configured solver, or \"manual\"")
+	register_flags.BoolVar(&automatic, "auto", false, "Pick the cheapest combination of challenges the configured solvers can handle and solve it without asking")
+	register_flags.BoolVar(&showHistory, "history", false, "Show all attempts to solve challenges for the domain")
+	register_flags.StringVar(&preference, "prefer", strings.Join(model.DefaultChallengePreference, ","), "Challenge types in order of preference for -auto")
+	register_flags.Var(&types.VerifyAddressOverrides, "verify-address", "Check challenge responses on IP[:PORT] instead of every address the domain resolves to, given as ADDRESS or DOMAIN=ADDRESS (can be repeated)")
+	register_flags.Var(&types.VerifyTLS, "verify-tls", "How to check TLS certificates when checking challenge responses: insecure, system (roots) or the path of a CA bundle; problems are only warnings")
+	register_flags.DurationVar(&validationWait, "validation-wait", 2*time.Minute, "How long to wait for the server to validate a challenge presented by an automatic solver")
 	command_base.AddStorageFlags(register_flags)
+	command_base.AddBundleFlags(register_flags)
 	utils.AddLogFlags(register_flags)
 }
 
 func Run(UI ui.UserInterface, args []string) {
+	// solvers might come from other packages, register their flags late
+	solver_interface.AddFlags(register_flags)
 	register_flags.Parse(args)
 
 	_, _, reg := command_base.OpenStorageFromFlags(UI)
@@ -47,6 +74,15 @@ func Run(UI ui.UserInterface, args []string) {
 	}
 	locationOrDnsName := register_flags.Arg(0)
 
+	if showHistory {
+		attempts, err := reg.ChallengeAttempts(locationOrDnsName)
+		if nil != err {
+			utils.Fatalf("Couldn't load challenge attempts for %v: %s", locationOrDnsName, err)
+		}
+		UI.Message(formatChallengeAttempts(locationOrDnsName, attempts))
+		return
+	}
+
 	auth, err := reg.LoadAuthorizationByURL(locationOrDnsName)
 	if nil != err {
 		utils.Fatalf("Couldn't load authorization %v: %v", locationOrDnsName, err)
@@ -60,6 +96,27 @@ func Run(UI ui.UserInterface, args []string) {
 		}
 	}
 
+	if automatic {
+		if status := auth.Authorization().Resource.Status; 0 != len(status) {
+			UI.Messagef("Authorization already finished (%s)", status)
+			return
+		}
+		plan, err := auth.SolveAutomatically(UI, solverChoices, preferenceList(), validationWait)
+		if nil != err {
+			utils.Fatalf("Couldn't authorize %v: %s", locationOrDnsName, err)
+		}
+		UI.Messagef("Authorization valid, solved combination %s", plan)
+		return
+	}
+
+	var presentedChallenges []model.PresentedChallenge
+	cleanupChallenges := func() {
+		for _, presented := range presentedChallenges {
+			presented.Cleanup(UI)
+		}
+		presentedChallenges = nil
+	}
+
 	for {
 		// refresh every round
 		authData := auth.Authorization()
@@ -74,11 +131,20 @@ func Run(UI ui.UserInterface, args []string) {
 			} else {
 				msg += fmt.Sprintf("Challenge: %d (%s, %s)\n", ndx, challenge.GetType(), challenge.GetStatus())
 			}
+			for _, line := range challenge.ValidationDetails() {
+				msg += fmt.Sprintf("\t%s\n", line)
+			}
 		}
 		msg += fmt.Sprintf("Valid combinations: %v", authData.Resource.Combinations)
+		if 0 == len(authData.Resource.Status) {
+			if plans := model.PlanCombinations(authData, solverChoices, preferenceList()); 0 != len(plans) {
+				msg += fmt.Sprintf("\nSuggested combination: %s", plans[0])
+			}
+		}
 		UI.Message(msg)
 
 		if 0 != len(authData.Resource.Status) {
+			cleanupChallenges()
 			UI.Message("Authorization finished")
 			return
 		}
@@ -96,40 +162,19 @@ func Run(UI ui.UserInterface, args []string) {
 				UI.Messagef("Invalid input (%s), try again", err)
 				continue
 			}
-			if selCh < 0 || selCh >= len(authData.Resource.Challenges) {
-				UI.Messagef("Not a valid challenge index, try again", err)
-				continue
-			}
-
-			chResp, err := authData.Respond(reg.Registration(), selCh)
+			presented, err := auth.SolveChallenge(UI, selCh, solverChoices)
 			if nil != err {
-				utils.Fatalf("Error trying to create response: %s", err)
-			}
-			if nil == chResp {
-				UI.Messagef("Responding for challenge %d not supported", selCh)
+				UI.Messagef("%s", err)
 				continue
 			}
+			presentedChallenges = append(presentedChallenges, *presented)
 
-			if err = chResp.InitializeResponse(UI); nil != err {
-				UI.Messagef("Failed to initialize response: %s", err)
-			}
-
-			if err = chResp.ShowInstructions(UI); nil != err {
-				UI.Messagef("Failed to complete challenge: %s", err)
-				continue
-			}
-			if err = chResp.Verify(); nil != err {
-				UI.Messagef("Failed to verify challenge: %s", err)
-				if err = auth.SaveChallengeData(chResp); nil != err {
-					utils.Fatalf("Couldn't store challenge data: %s", err)
+			if solver_interface.ManualSolverName != presented.SolverName {
+				UI.Message("Waiting for the server to validate the challenge")
+				challenge := presented.Response.Challenge()
+				if _, err = auth.WaitForChallenge(challenge.GetURI(), validationWait); nil != err {
+					UI.Messagef("%s", err)
 				}
-				continue
-			}
-
-			// update refreshes auth automatically
-			if err = auth.UpdateChallenge(chResp); nil != err {
-				UI.Messagef("Failed to update challenge: %s", err)
-				continue
 			}
 		} else {
 			if err := auth.Refresh(); nil != err {
@@ -137,4 +182,46 @@ func Run(UI ui.UserInterface, args []string) {
 			}
 		}
 	}
+	cleanupChallenges()
+}
+
+func formatChallengeAttempts(dnsName string, attempts []storage_interface.ChallengeAttempt) string {
+	if 0 == len(attempts) {
+		return fmt.Sprintf("No challenge attempts for %s", dnsName)
+	}
+	msg := fmt.Sprintf("Challenge attempts for %s (newest first):", dnsName)
+	for _, attempt := range attempts {
+		status := attempt.Status
+		if 0 == len(status) {
+			if nil == attempt.Finished {
+				status = "pending"
+			} else {
+				status = "not validated"
+			}
+		}
+		msg += fmt.Sprintf("\n%s %s via %s: %s", attempt.Started.Format(time.RFC3339), attempt.ChallengeType, attempt.Solver, status)
+		if nil != attempt.Finished {
+			msg += fmt.Sprintf(" (finished %s)", attempt.Finished.Format(time.RFC3339))
+		}
+		msg += fmt.Sprintf("\n\tChallenge: %s\n\tAuthorization: %s", attempt.ChallengeURI, attempt.AuthorizationLocation)
+		msg += fmt.Sprintf("\n\tLocal check: %s", attempt.LocalResult)
+		if 0 != len(attempt.Error) {
+			msg += fmt.Sprintf("\n\tError: %s", attempt.Error)
+		}
+		if 0 != len(attempt.Parameters) {
+			msg += fmt.Sprintf("\n\tPayload: %s", attempt.Parameters)
+		}
+	}
+	return msg
+}
+
+// challenge types from -prefer ("dns-01, http-01" works too)
+func preferenceList() []string {
+	var list []string
+	for _, challengeType := range strings.Split(preference, ",") {
+		if challengeType = strings.TrimSpace(challengeType); 0 != len(challengeType) {
+			list = append(list, challengeType)
+		}
+	}
+	return list
 }
@@ -2,22 +2,38 @@ package command_base
 
 import (
 	"flag"
+	_ "github.com/stbuehler/go-acme-client/key_backend_file"
+	_ "github.com/stbuehler/go-acme-client/key_backend_ssh_agent"
 	"github.com/stbuehler/go-acme-client/model"
+	"github.com/stbuehler/go-acme-client/offline_bundle"
 	"github.com/stbuehler/go-acme-client/storage_interface"
 	"github.com/stbuehler/go-acme-client/storage_sql"
+	"github.com/stbuehler/go-acme-client/types"
 	"github.com/stbuehler/go-acme-client/ui"
 	"github.com/stbuehler/go-acme-client/utils"
 )
 
 var flagsStoragePath string
 var FlagsStorageRegistrationName string
+var flagsBundlePath string
+
+// the offline bundle from -bundle (nil if not given)
+var Bundle *offline_bundle.Bundle
 
 func AddStorageFlags(flags *flag.FlagSet) {
 	flags.StringVar(&flagsStoragePath, "storage", "storage.sqlite3", "Storagefile")
 	flags.StringVar(&FlagsStorageRegistrationName, "registration", "", "Registration name in storage")
 }
 
+// for registrations whose key is kept offline: requests are added to the
+// bundle instead
+func AddBundleFlags(flags *flag.FlagSet) {
+	flags.StringVar(&flagsBundlePath, "bundle", "", "Add requests needing the registration key to this bundle file (for sign-bundle on the offline host)")
+}
+
 func OpenStorageFromFlags(UI ui.UserInterface) (storage_interface.Storage, model.Controller, model.RegistrationModel) {
+	types.KeyBackendUI = UI
+
 	st, err := storage_sql.OpenSQLite(UI, flagsStoragePath)
 	if nil != err {
 		utils.Fatalf("Couldn't access storage: %s", err)
@@ -31,5 +47,14 @@ func OpenStorageFromFlags(UI ui.UserInterface) (storage_interface.Storage, model
 	}
 	// reg still can be nil!
 
+	if 0 != len(flagsBundlePath) && nil != reg {
+		if Bundle, err = offline_bundle.Open(flagsBundlePath, reg.Registration()); nil != err {
+			utils.Fatalf("Couldn't open bundle: %s", err)
+		}
+		offline_bundle.Activate(Bundle)
+	} else if nil != reg && !reg.Registration().SigningKey.CanSign() {
+		utils.Infof("The registration key is kept offline, use -bundle to prepare requests")
+	}
+
 	return st, controller, reg
 }
@@ -0,0 +1,8 @@
+//go:build pkcs11
+// +build pkcs11
+
+package command_base
+
+import (
+	_ "github.com/stbuehler/go-acme-client/key_backend_pkcs11"
+)
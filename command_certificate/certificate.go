@@ -4,23 +4,41 @@ import (
 	"encoding/pem"
 	"flag"
 	"github.com/stbuehler/go-acme-client/command_base"
+	"github.com/stbuehler/go-acme-client/key_backend_file"
+	"github.com/stbuehler/go-acme-client/model"
 	"github.com/stbuehler/go-acme-client/types"
 	"github.com/stbuehler/go-acme-client/ui"
 	"github.com/stbuehler/go-acme-client/utils"
 	"os"
+	"path/filepath"
+	"time"
 )
 
 var register_flags = flag.NewFlagSet("register", flag.ExitOnError)
 
-var rsabits int = 2048
-var curve utils.Curve = utils.CurveP521
-var keyType utils.KeyType = utils.KeyRSA
+var keySpecFlags *utils.KeySpecFlags
+var renewLocation string
+var keyBackend string
+var keyReference string
+var keyGenerate bool
+var storeKeyPath bool
+var keyFormat utils.KeyFormat = utils.KeyFormatPEM
+var keyPolicy types.KeyPolicy
+var keyGrace time.Duration
 
 func init() {
-	register_flags.IntVar(&rsabits, "rsa-bits", 2048, "Number of bits to generate the RSA key with (if selected)")
-	register_flags.Var(&curve, "curve", "Elliptic curve to generate ECDSA key with (if selected), one of P-256, P-384, P-521")
-	register_flags.Var(&keyType, "key-type", "Key type to generate, RSA or ECDSA")
+	keySpecFlags = utils.AddKeySpecFlags(register_flags, utils.KeySpecRSA2048)
+	register_flags.StringVar(&renewLocation, "renew", "", "Renew the stored certificate with this URL and the certificates issued with it (same names and key specs, keys according to the key policy)")
+	register_flags.Var(&keyPolicy, "key-policy", "What to do with the private key when reissuing: rotate, reuse or rotate-N (new key after N certificates); renewals default to the stored policy")
+	register_flags.DurationVar(&keyGrace, "key-grace", 30*24*time.Hour, "Keep the private keys of replaced certificates for this long")
+	register_flags.Var(&keyFormat, "key-format", "Format to show the private key in, one of pem, pkcs8, jwk")
+	register_flags.StringVar(&keyBackend, "key-backend", "", "Use a key from a key backend (only the reference gets stored)")
+	register_flags.StringVar(&keyReference, "key-reference", "", "Backend specific reference of the key (see -key-backend)")
+	register_flags.BoolVar(&keyGenerate, "key-generate", false, "Generate the key in the key backend instead of using an existing one")
+	register_flags.BoolVar(&storeKeyPath, "store-key-path", false, "Only store the path of the given key file (and its public key to validate it), not the key itself")
 	command_base.AddStorageFlags(register_flags)
+	command_base.AddBundleFlags(register_flags)
+	utils.AddKeyCheckFlags(register_flags)
 	utils.AddLogFlags(register_flags)
 }
 
@@ -49,81 +67,328 @@ func Run(UI ui.UserInterface, args []string) {
 		utils.Fatalf("You don't have any valid authorizations.")
 	}
 
+	keySpecs, err := keySpecFlags.Specs()
+	if nil != err {
+		utils.Fatalf("Invalid key spec: %s", err)
+	}
+
+	var renewDomains []string
+	var renewCerts []model.CertificateModel
+	if 0 != len(renewLocation) {
+		oldCert, err := reg.LoadCertificate(renewLocation)
+		if nil != err {
+			utils.Fatalf("Couldn't load certificate: %s", err)
+		} else if nil == oldCert {
+			utils.Fatalf("Couldn't find certificate %s", renewLocation)
+		}
+		if renewDomains, err = certificateNames(oldCert.Certificate()); nil != err {
+			utils.Fatalf("Couldn't parse certificate: %s", err)
+		}
+		// renew all certificates issued together
+		linked, err := oldCert.LinkedCertificates()
+		if nil != err {
+			utils.Fatalf("Couldn't load linked certificates: %s", err)
+		}
+		renewCerts = append([]model.CertificateModel{oldCert}, linked...)
+	}
+
+	privateKeyGenerated := 0 == len(keyBackend) && 0 == len(register_flags.Args())
+	var keyFilePath string
+	if storeKeyPath {
+		if 0 == len(register_flags.Args()) {
+			utils.Fatalf("-store-key-path needs a key file (use -key-backend %s -key-reference PATH -key-generate for new keys)", key_backend_file.BackendName)
+		} else if keyFilePath, err = filepath.Abs(register_flags.Arg(0)); nil != err {
+			utils.Fatalf("%s", err)
+		}
+	}
+
+	var keys []certificateKey
+	renewSameSpecs := !keySpecFlags.IsSet() || sameKeySpecs(renewCerts, keySpecs)
+	if nil != renewCerts && renewSameSpecs && privateKeyGenerated && nil == command_base.Bundle {
+		keys = renewKeys(UI, renewCerts, keySpecs[0])
+	} else {
+		if len(keySpecs) > 1 && (!privateKeyGenerated || nil != command_base.Bundle) {
+			utils.Fatalf("Multiple key specs only work with generated keys (not with a key file, -key-backend or -bundle)")
+		}
+		if keySpecFlags.IsSet() {
+			checkKeySpecChange(renewCerts, keySpecs, privateKeyGenerated)
+		}
+		for _, keySpec := range keySpecs {
+			keys = append(keys, certificateKey{
+				privateKey: obtainPrivateKey(UI, keySpec),
+				policy:     renewPolicy(renewCerts, keySpec),
+			})
+		}
+	}
+
+	var selectedDomains []string
+	if nil != renewDomains {
+		for _, domain := range renewDomains {
+			if !validAuths[domain] {
+				utils.Fatalf("No valid authorization for %#v, authorize it first", domain)
+			}
+		}
+		selectedDomains = renewDomains
+	} else {
+		selectedDomains = selectDomains(UI, validAuths, validDomains)
+	}
+
+	if 0 == len(selectedDomains) {
+		UI.Message("No domains entered, aborting")
+		return
+	}
+
+	var dnsNames, emailAddresses []string
+	for _, domain := range selectedDomains {
+		if identifier := types.NewIdentifier(domain); types.IdentifierEmail == identifier.Type {
+			emailAddresses = append(emailAddresses, identifier.Value)
+		} else {
+			dnsNames = append(dnsNames, identifier.Value)
+		}
+	}
+
+	var certs []model.CertificateModel
+	for _, key := range keys {
+		csr, err := utils.MakeCertificateRequest(utils.CertificateRequestParameters{
+			PrivateKey:     key.privateKey,
+			DNSNames:       dnsNames,
+			EmailAddresses: emailAddresses,
+		})
+		if nil != err {
+			keepIssued(UI, reg, certs)
+			utils.Fatalf("Couldn't create certificate request: %s", err)
+		}
+
+		utils.Debugf("CSR:\n%s", pem.EncodeToMemory(csr))
+
+		cert, err := reg.NewCertificate(*csr)
+		if nil != err {
+			keepIssued(UI, reg, certs)
+			utils.Fatalf("Certificate request failed: %s", err)
+		}
+
+		if nil != key.reusedFrom {
+			if err := cert.ReusePrivateKey(key.reusedFrom); nil != err {
+				utils.Errorf("Couldn't store private key: %s", err)
+			}
+		} else if 0 != len(keyBackend) {
+			if err := cert.SetPrivateKeyReference(keyBackend, keyReference, utils.MustPublicKey(key.privateKey)); nil != err {
+				utils.Errorf("Couldn't store private key reference: %s", err)
+			}
+		} else if 0 != len(keyFilePath) {
+			if err := cert.SetPrivateKeyReference(key_backend_file.BackendName, keyFilePath, utils.MustPublicKey(key.privateKey)); nil != err {
+				utils.Errorf("Couldn't store private key path: %s", err)
+			}
+		} else if privateKeyGenerated {
+			if err := cert.SetPrivateKey(key.privateKey); nil != err {
+				utils.Errorf("Couldn't store private key: %s", err)
+			}
+		}
+		if err := cert.SetKeyPolicy(key.policy); nil != err {
+			utils.Errorf("Couldn't store key policy: %s", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if err := reg.LinkCertificates(certs); nil != err {
+		utils.Errorf("Couldn't link certificates: %s", err)
+	}
+
+	for _, oldCert := range renewCerts {
+		if err := oldCert.SetReplacedBy(replacementFor(oldCert, certs)); nil != err {
+			utils.Errorf("Couldn't mark %s as replaced: %s", oldCert.Certificate().Location, err)
+		}
+	}
+	if purged, err := reg.PurgeReplacedKeys(keyGrace); nil != err {
+		utils.Errorf("Couldn't remove private keys of replaced certificates: %s", err)
+	} else if purged > 0 {
+		UI.Messagef("Removed the private keys of %d replaced certificate(s)", purged)
+	}
+
+	for _, cert := range certs {
+		certData := cert.Certificate()
+
+		if 0 != len(certData.KeySpec) {
+			UI.Messagef("Private key: %s", certData.KeySpec)
+		}
+		UI.Messagef("New certificate is available under: %s (DER encoded)", certData.Location)
+		if 0 != len(certData.LinkIssuer) {
+			UI.Messagef("Issueing certificate available at: %s", certData.LinkIssuer)
+		}
+		UI.Messagef("%s", pem.EncodeToMemory(certData.Certificate))
+		if privateKey, err := certData.ExportPrivateKey(keyFormat); nil != err {
+			utils.Errorf("Couldn't export private key: %s", err)
+		} else if nil != privateKey {
+			UI.Messagef("%s", privateKey)
+		}
+	}
+}
+
+// links and lists the certificates issued before a later request failed
+func keepIssued(UI ui.UserInterface, reg model.RegistrationModel, certs []model.CertificateModel) {
+	if err := reg.LinkCertificates(certs); nil != err {
+		utils.Errorf("Couldn't link certificates: %s", err)
+	}
+	for _, cert := range certs {
+		certData := cert.Certificate()
+		UI.Messagef("Certificate (%s) issued before the failure is available under: %s", certData.KeySpec, certData.Location)
+	}
+}
+
+// from the key backend, the key file or generated
+func obtainPrivateKey(UI ui.UserInterface, keySpec utils.KeySpec) interface{} {
 	var pkey interface{}
-	privateKeyGenerated := false
-	if 0 != len(register_flags.Args()) {
+	var err error
+	if 0 != len(keyBackend) && keyGenerate {
+		UI.Message("Generating private key for certificate in key backend")
+		if pkey, err = types.GenerateBackendKey(UI, keyBackend, keyReference, keySpec); nil != err {
+			utils.Fatalf("Couldn't create key %#v in key backend %s: %s", keyReference, keyBackend, err)
+		}
+	} else if 0 != len(keyBackend) {
+		if pkey, err = types.LoadBackendKey(UI, keyBackend, keyReference); nil != err {
+			utils.Fatalf("Couldn't load key %#v from key backend %s: %s", keyReference, keyBackend, err)
+		}
+	} else if 0 != len(register_flags.Args()) {
 		pkeyPrompt, _ := UI.PasswordPromptOnce("Enter private key password")
 		if pkeyFile, err := os.Open(register_flags.Arg(0)); nil != err {
 			utils.Fatalf("%s", err)
 		} else if pkey, err = utils.LoadFirstPrivateKey(pkeyFile, pkeyPrompt); nil != err {
 			utils.Fatalf("%s", err)
+		} else if err := utils.CheckKey(pkey); nil != err {
+			utils.Fatalf("Refusing private key from %s: %s", register_flags.Arg(0), err)
 		}
+	} else if nil != command_base.Bundle {
+		// the certificate is only stored when the bundle gets submitted
+		utils.Fatalf("With -bundle the private key for the certificate needs to be given as file")
 	} else {
-		UI.Message("Generating private key for certificate")
-		privateKeyGenerated = true
-		var err error
-		if pkey, err = utils.CreatePrivateKey(keyType, curve, &rsabits); nil != err {
+		UI.Messagef("Generating private key (%s) for certificate", keySpec)
+		if pkey, err = utils.CreateKeySpecPrivateKey(keySpec); nil != err {
 			utils.Fatalf("Couldn't create private key for certificate: %s", err)
 		}
 	}
+	return pkey
+}
 
-	UI.Messagef("Available domains: %v", validDomains)
+type certificateKey struct {
+	privateKey interface{}
+	// policy to store with the new certificate
+	policy types.KeyPolicy
+	// certificate the key gets reused from (nil for new keys)
+	reusedFrom model.CertificateModel
+}
 
-	markSelectedDomains := make(map[string]bool)
-	var selectedDomains []string
-	for {
-		domain, err := UI.Prompt("Enter domain to add to certificate (empty to end list)")
-		if err != nil {
-			utils.Fatalf("Couldn't read domain: %s", err)
-		}
-		if 0 == len(domain) {
-			break
+// keys for renewing certificates (one per key spec, defaultSpec if
+// unknown): the old key if the key policy allows it, otherwise a new one
+func renewKeys(UI ui.UserInterface, certs []model.CertificateModel, defaultSpec utils.KeySpec) []certificateKey {
+	var keys []certificateKey
+	seen := make(map[utils.KeySpec]bool)
+	for _, cert := range certs {
+		certData := cert.Certificate()
+		spec := certData.KeySpec
+		if 0 == len(spec) {
+			spec = defaultSpec
 		}
-		if markSelectedDomains[domain] {
-			UI.Messagef("Already selected %#v", domain)
+		if seen[spec] {
 			continue
 		}
-		markSelectedDomains[domain] = true
-		if !validAuths[domain] {
-			UI.Messagef("Unknown domain %#v, not adding - try again", domain)
-			continue
+		seen[spec] = true
+
+		policy := keyPolicy
+		if 0 == len(policy) {
+			policy = certData.KeyPolicy
+		}
+		reuse := policy.ReuseKey(certData.KeyUses)
+		if reuse && nil == certData.PrivateKey {
+			missingReusedKey(certData, policy)
+		}
+		if backend, reference, ok := certData.KeyReference(); ok && !reuse {
+			// don't silently move the key into the database
+			utils.Fatalf("The private key of %s is kept in key backend %s (%#v), the new key needs to be given with -key-backend or as key file",
+				certData.Location, backend, reference)
+		}
+		if reuse {
+			pkey, err := certData.LoadPrivateKey()
+			if nil != err {
+				utils.Fatalf("Couldn't load private key of %s: %s", certData.Location, err)
+			}
+			UI.Messagef("Reusing private key (%s) of %s", spec, certData.Location)
+			keys = append(keys, certificateKey{privateKey: pkey, policy: policy, reusedFrom: cert})
+		} else {
+			keys = append(keys, certificateKey{privateKey: obtainPrivateKey(UI, spec), policy: policy})
 		}
-		selectedDomains = append(selectedDomains, domain)
 	}
+	return keys
+}
 
-	if 0 == len(selectedDomains) {
-		UI.Message("No domains entered, aborting")
-		return
+// -key-policy, or the stored policy of the renewed certificate with the
+// key spec (the first one if there is none)
+func renewPolicy(certs []model.CertificateModel, keySpec utils.KeySpec) types.KeyPolicy {
+	if 0 != len(keyPolicy) || 0 == len(certs) {
+		return keyPolicy
 	}
-
-	csr, err := utils.MakeCertificateRequest(utils.CertificateRequestParameters{
-		PrivateKey: pkey,
-		DNSNames:   selectedDomains,
-	})
-	if nil != err {
-		utils.Fatalf("Couldn't create certificate request: %s", err)
+	for _, cert := range certs {
+		if certData := cert.Certificate(); keySpec == certData.KeySpec {
+			return certData.KeyPolicy
+		}
 	}
+	return certs[0].Certificate().KeyPolicy
+}
 
-	utils.Debugf("CSR:\n%s", pem.EncodeToMemory(csr))
-
-	cert, err := reg.NewCertificate(*csr)
-	if nil != err {
-		utils.Fatalf("Certificate request failed: %s", err)
+// whether -key-spec asks for exactly the key specs of the renewed
+// certificates
+func sameKeySpecs(certs []model.CertificateModel, keySpecs []utils.KeySpec) bool {
+	oldSpecs := make(map[utils.KeySpec]bool)
+	for _, cert := range certs {
+		oldSpecs[cert.Certificate().KeySpec] = true
 	}
-
-	if privateKeyGenerated {
-		if err := cert.SetPrivateKey(pkey); nil != err {
-			utils.Errorf("Couldn't store private key: %s", err)
+	if len(oldSpecs) != len(keySpecs) {
+		return false
+	}
+	for _, spec := range keySpecs {
+		if !oldSpecs[spec] {
+			return false
 		}
 	}
-	certData := cert.Certificate()
+	return true
+}
 
-	UI.Messagef("New certificate is available under: %s (DER encoded)", certData.Location)
-	if 0 != len(certData.LinkIssuer) {
-		UI.Messagef("Issueing certificate available at: %s", certData.LinkIssuer)
+// refuses to drop a key the key policy says to reuse for a key with
+// another spec, or for a generated key if the old one isn't stored
+func checkKeySpecChange(certs []model.CertificateModel, keySpecs []utils.KeySpec, generated bool) {
+	for _, cert := range certs {
+		certData := cert.Certificate()
+		policy := renewPolicy([]model.CertificateModel{cert}, certData.KeySpec)
+		if !policy.ReuseKey(certData.KeyUses) {
+			continue
+		}
+		found := false
+		for _, spec := range keySpecs {
+			if spec == certData.KeySpec {
+				found = true
+			}
+		}
+		if !found {
+			utils.Fatalf("Key policy %s of %s reuses its %s key, use -key-policy rotate to switch to another key spec",
+				policy, certData.Location, certData.KeySpec)
+		} else if generated && nil == certData.PrivateKey {
+			missingReusedKey(certData, policy)
+		}
 	}
-	UI.Messagef("%s", pem.EncodeToMemory(certData.Certificate))
-	if nil != certData.PrivateKey {
-		UI.Messagef("%s", pem.EncodeToMemory(certData.PrivateKey))
+}
+
+// a new key would break pinning (or TLSA records) the policy is used for
+func missingReusedKey(certData types.Certificate, policy types.KeyPolicy) {
+	utils.Fatalf("Key policy %s of %s says to reuse its key, but the key isn't stored (it was given as key file or already removed); give the key file or use -key-policy rotate",
+		policy, certData.Location)
+}
+
+// the new certificate with the same key spec as the old one (the first
+// new one if there is none)
+func replacementFor(oldCert model.CertificateModel, certs []model.CertificateModel) model.CertificateModel {
+	for _, cert := range certs {
+		if cert.Certificate().KeySpec == oldCert.Certificate().KeySpec {
+			return cert
+		}
 	}
+	return certs[0]
 }
@@ -0,0 +1,175 @@
+package command_certificate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"github.com/stbuehler/go-acme-client/model"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"testing"
+)
+
+// only Certificate() is implemented
+type testCertificate struct {
+	model.CertificateModel
+	data types.Certificate
+}
+
+func (cert *testCertificate) Certificate() types.Certificate {
+	return cert.data
+}
+
+// only LinkCertificates is implemented
+type testRegistration struct {
+	model.RegistrationModel
+	linked []model.CertificateModel
+}
+
+func (reg *testRegistration) LinkCertificates(certs []model.CertificateModel) error {
+	reg.linked = certs
+	return nil
+}
+
+// stored certificate with a (generated) private key
+func storedCertificate(t *testing.T, location string, spec utils.KeySpec, policy types.KeyPolicy, uses int) *testCertificate {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	block, err := utils.EncodePrivateKey(privateKey)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return &testCertificate{data: types.Certificate{
+		Location:   location,
+		PrivateKey: block,
+		KeySpec:    spec,
+		KeyPolicy:  policy,
+		KeyUses:    uses,
+	}}
+}
+
+func TestReplacementFor(t *testing.T) {
+	rsaCert := &testCertificate{data: types.Certificate{Location: "rsa", KeySpec: utils.KeySpecRSA2048}}
+	ecCert := &testCertificate{data: types.Certificate{Location: "ec", KeySpec: utils.KeySpecEC256}}
+	edCert := &testCertificate{data: types.Certificate{Location: "ed", KeySpec: utils.KeySpecEd25519}}
+	newCerts := []model.CertificateModel{
+		&testCertificate{data: types.Certificate{Location: "rsa-2", KeySpec: utils.KeySpecRSA2048}},
+		&testCertificate{data: types.Certificate{Location: "ec-2", KeySpec: utils.KeySpecEC256}},
+	}
+	for old, expected := range map[*testCertificate]string{rsaCert: "rsa-2", ecCert: "ec-2", edCert: "rsa-2"} {
+		if replacement := replacementFor(old, newCerts).Certificate().Location; expected != replacement {
+			t.Errorf("%s: expected replacement %s, got %s", old.data.Location, expected, replacement)
+		}
+	}
+}
+
+func TestRenewKeys(t *testing.T) {
+	defer func(policy types.KeyPolicy) { keyPolicy = policy }(keyPolicy)
+
+	reuse := storedCertificate(t, "reuse", utils.KeySpecEC256, "reuse", 5)
+	rotate := storedCertificate(t, "rotate", utils.KeySpecEC384, "rotate", 1)
+	// the same spec only gets one key
+	duplicate := storedCertificate(t, "duplicate", utils.KeySpecEC384, "reuse", 1)
+	// unknown spec: the default spec
+	legacy := storedCertificate(t, "legacy", "", "rotate-2", 1)
+	certs := []model.CertificateModel{reuse, rotate, duplicate, legacy}
+
+	keyPolicy = ""
+	keys := renewKeys(ui.CLI, certs, utils.KeySpecEC521)
+	if 3 != len(keys) {
+		t.Fatalf("Expected 3 keys, got %d", len(keys))
+	}
+	for ndx, expected := range []struct {
+		reusedFrom *testCertificate
+		// of new keys
+		spec   utils.KeySpec
+		policy types.KeyPolicy
+	}{
+		{reuse, "", "reuse"},
+		{nil, utils.KeySpecEC384, "rotate"},
+		{legacy, "", "rotate-2"},
+	} {
+		key := keys[ndx]
+		if expected.policy != key.policy {
+			t.Errorf("Key %d: expected policy %s, got %s", ndx, expected.policy, key.policy)
+		}
+		if nil == expected.reusedFrom {
+			if nil != key.reusedFrom {
+				t.Errorf("Key %d: reused key of %s", ndx, key.reusedFrom.Certificate().Location)
+			} else if spec, err := utils.KeySpecOf(key.privateKey); nil != err || expected.spec != spec {
+				t.Errorf("Key %d: expected new %s key, got %s (%v)", ndx, expected.spec, spec, err)
+			}
+			continue
+		}
+		if expected.reusedFrom != key.reusedFrom {
+			t.Errorf("Key %d: expected key of %s", ndx, expected.reusedFrom.data.Location)
+			continue
+		}
+		oldKey, _ := expected.reusedFrom.data.LoadPrivateKey()
+		if !oldKey.(*ecdsa.PrivateKey).Equal(key.privateKey) {
+			t.Errorf("Key %d: reused key differs", ndx)
+		}
+	}
+
+	// -key-policy overrides the stored policies
+	keyPolicy = "rotate"
+	keys = renewKeys(ui.CLI, []model.CertificateModel{reuse}, utils.KeySpecEC521)
+	if 1 != len(keys) || nil != keys[0].reusedFrom || "rotate" != keys[0].policy {
+		t.Fatalf("Expected a new key with -key-policy rotate, got %#v", keys)
+	}
+	// rotate-2 after the second use
+	keyPolicy = ""
+	legacy.data.KeyUses = 2
+	keys = renewKeys(ui.CLI, []model.CertificateModel{legacy}, utils.KeySpecEC256)
+	if 1 != len(keys) || nil != keys[0].reusedFrom {
+		t.Fatal("rotate-2 key reused a third time")
+	}
+}
+
+func TestRenewPolicy(t *testing.T) {
+	defer func(policy types.KeyPolicy) { keyPolicy = policy }(keyPolicy)
+	certs := []model.CertificateModel{
+		&testCertificate{data: types.Certificate{KeySpec: utils.KeySpecRSA2048, KeyPolicy: "rotate-3"}},
+		&testCertificate{data: types.Certificate{KeySpec: utils.KeySpecEC256, KeyPolicy: "reuse"}},
+	}
+	keyPolicy = ""
+	for spec, expected := range map[utils.KeySpec]types.KeyPolicy{
+		utils.KeySpecRSA2048: "rotate-3",
+		utils.KeySpecEC256:   "reuse",
+		// new spec: the policy of the first certificate
+		utils.KeySpecEd25519: "rotate-3",
+	} {
+		if policy := renewPolicy(certs, spec); expected != policy {
+			t.Errorf("%s: expected policy %s, got %s", spec, expected, policy)
+		}
+	}
+	if policy := renewPolicy(nil, utils.KeySpecEC256); "" != policy {
+		t.Errorf("New certificate: expected default policy, got %s", policy)
+	}
+	keyPolicy = "rotate"
+	if policy := renewPolicy(certs, utils.KeySpecEC256); "rotate" != policy {
+		t.Errorf("Expected -key-policy rotate, got %s", policy)
+	}
+
+	if !sameKeySpecs(certs, []utils.KeySpec{utils.KeySpecEC256, utils.KeySpecRSA2048}) {
+		t.Error("Same key specs in other order not detected")
+	}
+	if sameKeySpecs(certs, []utils.KeySpec{utils.KeySpecEC256}) || sameKeySpecs(certs, []utils.KeySpec{utils.KeySpecEC256, utils.KeySpecEd25519}) {
+		t.Error("Different key specs not detected")
+	}
+}
+
+func TestKeepIssued(t *testing.T) {
+	reg := &testRegistration{}
+	issued := []model.CertificateModel{
+		&testCertificate{data: types.Certificate{Location: "rsa", KeySpec: utils.KeySpecRSA2048}},
+		&testCertificate{data: types.Certificate{Location: "ec", KeySpec: utils.KeySpecEC256}},
+	}
+	keepIssued(ui.CLI, reg, issued)
+	if 2 != len(reg.linked) || issued[0] != reg.linked[0] || issued[1] != reg.linked[1] {
+		t.Fatalf("Expected the issued certificates to be linked, got %v", reg.linked)
+	}
+}
@@ -0,0 +1,46 @@
+package command_certificate
+
+import (
+	"crypto/x509"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+)
+
+func selectDomains(UI ui.UserInterface, validAuths map[string]bool, validDomains []string) []string {
+	UI.Messagef("Available domains: %v", validDomains)
+
+	markSelectedDomains := make(map[string]bool)
+	var selectedDomains []string
+	for {
+		domain, err := UI.Prompt("Enter domain to add to certificate (empty to end list)")
+		if err != nil {
+			utils.Fatalf("Couldn't read domain: %s", err)
+		}
+		if 0 == len(domain) {
+			break
+		}
+		if markSelectedDomains[domain] {
+			UI.Messagef("Already selected %#v", domain)
+			continue
+		}
+		markSelectedDomains[domain] = true
+		if !validAuths[domain] {
+			UI.Messagef("Unknown domain %#v, not adding - try again", domain)
+			continue
+		}
+		selectedDomains = append(selectedDomains, domain)
+	}
+	return selectedDomains
+}
+
+// DNS names and email addresses of a stored certificate (for renewals)
+func certificateNames(certData types.Certificate) ([]string, error) {
+	cert, err := x509.ParseCertificate(certData.Certificate.Bytes)
+	if nil != err {
+		return nil, err
+	}
+	names := append([]string{}, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	return names, nil
+}
@@ -3,14 +3,25 @@ package command_certificate_show
 import (
 	"encoding/pem"
 	"flag"
+	"fmt"
 	"github.com/stbuehler/go-acme-client/command_base"
+	"github.com/stbuehler/go-acme-client/model"
 	"github.com/stbuehler/go-acme-client/ui"
 	"github.com/stbuehler/go-acme-client/utils"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 var register_flags = flag.NewFlagSet("register", flag.ExitOnError)
 
+var keyFormat utils.KeyFormat = utils.KeyFormatPEM
+var outDir string
+
 func init() {
+	register_flags.Var(&keyFormat, "key-format", "Format to show the private key in, one of pem, pkcs8, jwk")
+	register_flags.StringVar(&outDir, "out", "", "Write certificates and private keys to this directory (cert-<keyspec>.pem and key-<keyspec>.pem)")
 	command_base.AddStorageFlags(register_flags)
 	utils.AddLogFlags(register_flags)
 }
@@ -30,7 +41,18 @@ func Run(UI ui.UserInterface, args []string) {
 		}
 		UI.Message("Certificate list")
 		for _, certInfo := range certs {
-			UI.Messagef("\t%s", certInfo.Location)
+			details := certInfo.KeySpec
+			if 0 != len(certInfo.Group) && certInfo.Group != certInfo.Location {
+				details += ", issued with " + certInfo.Group
+			}
+			if 0 != len(certInfo.ReplacedBy) {
+				details += ", replaced by " + certInfo.ReplacedBy
+			}
+			if 0 != len(details) {
+				UI.Messagef("\t%s (%s)", certInfo.Location, strings.TrimPrefix(details, ", "))
+			} else {
+				UI.Messagef("\t%s", certInfo.Location)
+			}
 		}
 	} else {
 		location := register_flags.Arg(0)
@@ -40,15 +62,72 @@ func Run(UI ui.UserInterface, args []string) {
 		} else if nil == cert {
 			utils.Fatalf("Couldn't find certificate")
 		}
-		certData := cert.Certificate()
+		linked, err := cert.LinkedCertificates()
+		if nil != err {
+			utils.Fatalf("Couldn't load linked certificates: %s", err)
+		}
 
-		UI.Messagef("Certificate from %s (DER encoded)", location)
-		if 0 != len(certData.LinkIssuer) {
-			UI.Messagef("Issued by %s", certData.LinkIssuer)
+		for ndx, c := range append([]model.CertificateModel{cert}, linked...) {
+			showCertificate(UI, c)
+			if 0 != len(outDir) {
+				if err := writeCertificate(c, ndx); nil != err {
+					utils.Fatalf("Couldn't write certificate: %s", err)
+				}
+			}
 		}
-		UI.Messagef("%s", pem.EncodeToMemory(certData.Certificate))
-		if nil != certData.PrivateKey {
-			UI.Messagef("%s", pem.EncodeToMemory(certData.PrivateKey))
+	}
+}
+
+func showCertificate(UI ui.UserInterface, cert model.CertificateModel) {
+	certData := cert.Certificate()
+
+	UI.Messagef("Certificate from %s (DER encoded)", certData.Location)
+	if 0 != len(certData.LinkIssuer) {
+		UI.Messagef("Issued by %s", certData.LinkIssuer)
+	}
+	if 0 != len(certData.KeySpec) {
+		UI.Messagef("Private key: %s", certData.KeySpec)
+	}
+	if 0 != len(certData.KeyPolicy) {
+		UI.Messagef("Key policy: %s (key used for %d certificate(s))", certData.KeyPolicy, certData.KeyUses)
+	}
+	if nil != certData.Replaced {
+		UI.Messagef("Replaced by %s on %s", certData.ReplacedBy, certData.Replaced.Format(time.RFC3339))
+	}
+	UI.Messagef("%s", pem.EncodeToMemory(certData.Certificate))
+	if backend, reference, ok := certData.KeyReference(); ok {
+		UI.Messagef("Private key kept in key backend %s: %s", backend, reference)
+	} else if privateKey, err := certData.ExportPrivateKey(keyFormat); nil != err {
+		utils.Errorf("Couldn't export private key: %s", err)
+	} else if nil != privateKey {
+		UI.Messagef("%s", privateKey)
+	}
+}
+
+func writeCertificate(cert model.CertificateModel, ndx int) error {
+	certData := cert.Certificate()
+	name := string(certData.KeySpec)
+	if 0 == len(name) {
+		name = fmt.Sprintf("%d", ndx)
+	}
+
+	certFile := filepath.Join(outDir, "cert-"+name+".pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(certData.Certificate), 0644); nil != err {
+		return err
+	}
+	utils.Infof("Wrote %s", certFile)
+
+	if _, _, ok := certData.KeyReference(); ok {
+		// the key isn't in the database
+		return nil
+	} else if privateKey, err := certData.ExportPrivateKey(keyFormat); nil != err {
+		return err
+	} else if nil != privateKey {
+		keyFile := filepath.Join(outDir, "key-"+name+".pem")
+		if err := ioutil.WriteFile(keyFile, privateKey, 0600); nil != err {
+			return err
 		}
+		utils.Infof("Wrote %s", keyFile)
 	}
+	return nil
 }
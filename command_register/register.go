@@ -1,39 +1,52 @@
 package command_register
 
 import (
+	"crypto"
 	"flag"
 	"fmt"
 	"github.com/stbuehler/go-acme-client/command_base"
 	"github.com/stbuehler/go-acme-client/types"
 	"github.com/stbuehler/go-acme-client/ui"
 	"github.com/stbuehler/go-acme-client/utils"
+	"io/ioutil"
+	"os"
 	"reflect"
+	"strings"
 )
 
 var register_flags = flag.NewFlagSet("register", flag.ExitOnError)
 
-var rsabits int = 2048
-var curve utils.Curve = utils.CurveP521
-var keyType utils.KeyType = utils.KeyRSA
+var keySpecFlags *utils.KeySpecFlags
 var storagePath string
 var no_refresh bool
 var show_tos bool
 var agree_tos bool
 var modify bool
 var directoryURL string
+var keyBackend string
+var keyReference string
+var keyGenerate bool
+var keyFile string
+var exportKey string
+var keyFormat utils.KeyFormat = utils.KeyFormatPEM
 
 const demoDirectoryURL = "https://acme-staging.api.letsencrypt.org/directory"
 
 func init() {
-	register_flags.IntVar(&rsabits, "rsa-bits", 2048, "Number of bits to generate the RSA key with (if selected)")
-	register_flags.Var(&curve, "curve", "Elliptic curve to generate ECDSA key with (if selected), one of P-256, P-384, P-521")
-	register_flags.Var(&keyType, "key-type", "Key type to generate, RSA or ECDSA")
+	keySpecFlags = utils.AddKeySpecFlags(register_flags, utils.KeySpecRSA2048)
+	register_flags.StringVar(&keyBackend, "key-backend", "", fmt.Sprintf("Keep the new registration key outside the storage in a key backend (available: %s)", strings.Join(types.KeyBackendNames(), ", ")))
+	register_flags.StringVar(&keyReference, "key-reference", "", "Backend specific reference of the key (see -key-backend)")
+	register_flags.BoolVar(&keyGenerate, "key-generate", false, "Generate the key in the key backend instead of using an existing one")
+	register_flags.StringVar(&keyFile, "key-file", "", "Use the private key from this file (PEM, PKCS#8 or JWK) for a new registration")
+	register_flags.StringVar(&exportKey, "export-key", "", "Write the registration key to this file (see -key-format)")
+	register_flags.Var(&keyFormat, "key-format", "Format for -export-key, one of pem, pkcs8, jwk")
 	register_flags.StringVar(&directoryURL, "url", demoDirectoryURL, "ACME Directory URL")
 	register_flags.BoolVar(&no_refresh, "no-refresh", false, "Disable automatically fetching an updated registration")
 	register_flags.BoolVar(&show_tos, "show-tos", false, "Show Terms of service if available, even when already agreed to something")
 	register_flags.BoolVar(&agree_tos, "agree-tos", false, "Automatically agree to terms of service")
 	register_flags.BoolVar(&modify, "modify", false, "Modify contact information")
 	command_base.AddStorageFlags(register_flags)
+	utils.AddKeyCheckFlags(register_flags)
 	utils.AddLogFlags(register_flags)
 }
 
@@ -72,10 +85,35 @@ func Run(UI ui.UserInterface, args []string) {
 			utils.Fatalf("Couldn't fetch directory for '%s': %s", demoDirectoryURL, err)
 		}
 
-		UI.Message("Generating private key, might take some time")
-		signingKey, err := types.CreateSigningKey(keyType, curve, &rsabits)
+		keySpec, err := keySpecFlags.Spec()
 		if nil != err {
-			utils.Fatalf("Couldn't create private key for registration: %s", err)
+			utils.Fatalf("Invalid key spec: %s", err)
+		}
+
+		var signingKey types.SigningKey
+		if 0 != len(keyBackend) && keyGenerate {
+			UI.Message("Generating private key in key backend, might take some time")
+			if signingKey, err = types.GenerateExternalSigningKey(UI, keyBackend, keyReference, keySpec); nil != err {
+				utils.Fatalf("Couldn't create key %#v in key backend %s: %s", keyReference, keyBackend, err)
+			}
+		} else if 0 != len(keyBackend) {
+			if signingKey, err = types.NewExternalSigningKey(UI, keyBackend, keyReference); nil != err {
+				utils.Fatalf("Couldn't load key %#v from key backend %s: %s", keyReference, keyBackend, err)
+			}
+		} else if 0 != len(keyFile) {
+			pkeyPrompt, _ := UI.PasswordPromptOnce("Enter private key password")
+			if pkeyFile, err := os.Open(keyFile); nil != err {
+				utils.Fatalf("%s", err)
+			} else if pkey, err := utils.LoadFirstPrivateKey(pkeyFile, pkeyPrompt); nil != err {
+				utils.Fatalf("Couldn't load private key for registration: %s", err)
+			} else if signingKey, err = types.NewSigningKey(pkey.(crypto.Signer)); nil != err {
+				utils.Fatalf("Couldn't use private key for registration: %s", err)
+			}
+		} else {
+			UI.Message("Generating private key, might take some time")
+			if signingKey, err = types.CreateSigningKey(keySpec); nil != err {
+				utils.Fatalf("Couldn't create private key for registration: %s", err)
+			}
 		}
 		contact, err := EnterNewContact(UI)
 		if nil != err {
@@ -135,4 +173,20 @@ func Run(UI ui.UserInterface, args []string) {
 		UI.Messagef("You didn't agree to the terms of service at %s", regData.LinkTermsOfService)
 	}
 	UI.Messagef("Your recovery token is: %s", regData.RecoveryToken)
+
+	if 0 != len(exportKey) {
+		var password string
+		if utils.KeyFormatJWK != keyFormat {
+			var err error
+			if password, err = UI.NewPasswordPrompt("Enter password for exported key (empty for none)", "Enter password again"); nil != err {
+				utils.Fatalf("Couldn't read password for exported key: %s", err)
+			}
+		}
+		if data, err := regData.SigningKey.ExportPrivateKey(keyFormat, password); nil != err {
+			utils.Fatalf("Couldn't export registration key: %s", err)
+		} else if err := ioutil.WriteFile(exportKey, data, 0600); nil != err {
+			utils.Fatalf("Couldn't write registration key: %s", err)
+		}
+		UI.Messagef("Wrote registration key to %s", exportKey)
+	}
 }
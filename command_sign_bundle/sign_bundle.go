@@ -0,0 +1,86 @@
+package command_sign_bundle
+
+import (
+	"flag"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/command_base"
+	"github.com/stbuehler/go-acme-client/offline_bundle"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"io/ioutil"
+)
+
+var register_flags = flag.NewFlagSet("sign-bundle", flag.ExitOnError)
+
+var exportRegistration string
+var noConfirm bool
+
+func init() {
+	register_flags.StringVar(&exportRegistration, "export-registration", "", "Write the registration without private key to this file (for submit-bundle -import-registration on the online host)")
+	register_flags.BoolVar(&noConfirm, "yes", false, "Sign without asking for confirmation")
+	command_base.AddStorageFlags(register_flags)
+	utils.AddLogFlags(register_flags)
+}
+
+func Run(UI ui.UserInterface, args []string) {
+	register_flags.Parse(args)
+
+	_, _, reg := command_base.OpenStorageFromFlags(UI)
+	if nil == reg {
+		utils.Fatalf("You need to register first")
+	}
+	regData := reg.Registration()
+	if !regData.SigningKey.CanSign() {
+		utils.Fatalf("The private key of this registration is not available here; run sign-bundle on the offline host")
+	}
+
+	if 0 != len(exportRegistration) {
+		data, err := regData.ExportPublic(reg.Directory().Directory().RootURL)
+		if nil != err {
+			utils.Fatalf("Couldn't export registration: %s", err)
+		}
+		if err := ioutil.WriteFile(exportRegistration, data, 0644); nil != err {
+			utils.Fatalf("Couldn't write registration: %s", err)
+		}
+		UI.Messagef("Wrote registration %s without private key to %s", regData.Location, exportRegistration)
+		return
+	}
+
+	if 1 != len(register_flags.Args()) {
+		utils.Fatalf("Provide the bundle file to sign as command line parameter")
+	}
+	bundle, err := offline_bundle.Load(register_flags.Arg(0))
+	if nil != err {
+		utils.Fatalf("Couldn't load bundle: %s", err)
+	}
+	if err := bundle.CheckKey(regData.SigningKey); nil != err {
+		utils.Fatalf("%s", err)
+	}
+
+	entries := bundle.WithStatus(offline_bundle.StatusPrepared)
+	if 0 == len(entries) {
+		UI.Message("Nothing to sign")
+		return
+	}
+	msg := "Entries to sign for registration " + regData.Location + ":\n"
+	for _, entry := range entries {
+		msg += fmt.Sprintf("\t%d: %s\n", entry.ID, entry.Describe())
+	}
+	UI.Message(msg)
+
+	if !noConfirm {
+		if sign, err := UI.YesNoDialog("", "", "Sign these entries?", false); nil != err {
+			utils.Fatalf("%s", err)
+		} else if !sign {
+			return
+		}
+	}
+
+	if err := bundle.Sign(regData.SigningKey); nil != err {
+		utils.Fatalf("%s", err)
+	}
+	if err := bundle.Save(); nil != err {
+		utils.Fatalf("Couldn't save bundle: %s", err)
+	}
+	UI.Messagef("Signed %d entries; run submit-bundle with %s on the online host", len(entries), bundle.Path())
+}
@@ -0,0 +1,91 @@
+package command_submit_bundle
+
+import (
+	"flag"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/command_base"
+	"github.com/stbuehler/go-acme-client/offline_bundle"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+var register_flags = flag.NewFlagSet("submit-bundle", flag.ExitOnError)
+
+var importRegistration string
+var maxNonceAge time.Duration
+
+func init() {
+	register_flags.StringVar(&importRegistration, "import-registration", "", "Store the registration (without private key) written by sign-bundle -export-registration")
+	register_flags.DurationVar(&maxNonceAge, "nonce-max-age", time.Hour, "Prepare unsigned entries with older nonces again")
+	command_base.AddStorageFlags(register_flags)
+	utils.AddLogFlags(register_flags)
+}
+
+func Run(UI ui.UserInterface, args []string) {
+	register_flags.Parse(args)
+
+	st, controller, reg := command_base.OpenStorageFromFlags(UI)
+
+	if 0 != len(importRegistration) {
+		if nil != reg {
+			utils.Fatalf("There already is a registration with name %#v", command_base.FlagsStorageRegistrationName)
+		}
+		data, err := ioutil.ReadFile(importRegistration)
+		if nil != err {
+			utils.Fatalf("%s", err)
+		}
+		regData, directoryURL, err := types.ImportPublicRegistration(data)
+		if nil != err {
+			utils.Fatalf("Couldn't load registration: %s", err)
+		}
+		regData.Name = command_base.FlagsStorageRegistrationName
+
+		dir, err := controller.GetDirectory(directoryURL, false)
+		if nil != err {
+			utils.Fatalf("Couldn't fetch directory for '%s': %s", directoryURL, err)
+		}
+		if password, err := UI.NewPasswordPrompt("Enter new password for storage", "Enter password again"); nil != err {
+			utils.Fatalf("Couldn't read new password for storage file: %s", err)
+		} else {
+			st.SetPassword(password)
+		}
+		if _, err := dir.ImportRegistration(*regData); nil != err {
+			utils.Fatalf("Couldn't store registration: %s", err)
+		}
+		UI.Messagef("Imported registration %s; its private key stays offline", regData.Location)
+		return
+	}
+
+	if nil == reg {
+		utils.Fatalf("You need to register (or import a registration) first")
+	}
+	if 1 != len(register_flags.Args()) {
+		utils.Fatalf("Provide the bundle file to submit as command line parameter")
+	}
+	bundle, err := offline_bundle.Load(register_flags.Arg(0))
+	if nil != err {
+		utils.Fatalf("Couldn't load bundle: %s", err)
+	}
+
+	submitErr := reg.SubmitBundle(bundle, maxNonceAge)
+
+	msg := "Bundle " + bundle.Path() + ":\n"
+	for _, entry := range bundle.Entries {
+		summary := strings.SplitN(entry.Describe(), "\n", 2)[0]
+		msg += fmt.Sprintf("\t%d [%s] %s\n", entry.ID, entry.Status, summary)
+		if 0 != len(entry.Result) {
+			msg += fmt.Sprintf("\t\t%s\n", entry.Result)
+		}
+	}
+	UI.Message(msg)
+	if nil != submitErr {
+		utils.Fatalf("Submitting bundle failed: %s", submitErr)
+	}
+	if pending := len(bundle.WithStatus(offline_bundle.StatusPrepared)); 0 != pending {
+		UI.Messagef("%d entries need to be signed (again) with sign-bundle", pending)
+	}
+}
@@ -0,0 +1,83 @@
+package key_backend_file
+
+import (
+	"crypto"
+	"encoding/pem"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"os"
+	"path/filepath"
+)
+
+// keeps the registration or certificate key in a separate (optionally
+// encrypted) PEM file; the reference is the absolute path of the file
+const BackendName = "file"
+
+type backend struct{}
+
+func init() {
+	types.RegisterKeyBackend(BackendName, backend{})
+}
+
+func (backend) Load(UI ui.UserInterface, reference string) (crypto.Signer, error) {
+	if !filepath.IsAbs(reference) {
+		return nil, fmt.Errorf("Key file reference must be an absolute path: %s", reference)
+	}
+	file, err := os.Open(reference)
+	if nil != err {
+		return nil, err
+	}
+	defer file.Close()
+	if info, err := file.Stat(); nil != err {
+		return nil, err
+	} else if 0 != info.Mode().Perm()&0077 {
+		utils.Warningf("Key file %s is accessible by other users (mode %04o)", reference, info.Mode().Perm())
+	}
+	prompt, _ := UI.PasswordPromptOnce("Enter password for key file " + reference)
+	privateKey, err := utils.LoadFirstPrivateKey(file, prompt)
+	if nil != err {
+		return nil, fmt.Errorf("Couldn't load key file %s: %s", reference, err)
+	}
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("Key in %s can't be used for signing", reference)
+	}
+	return signer, nil
+}
+
+// new unencrypted key file only readable by the owner; doesn't overwrite
+// existing files
+func (backend) Generate(UI ui.UserInterface, reference string, spec utils.KeySpec) (crypto.Signer, error) {
+	if !filepath.IsAbs(reference) {
+		return nil, fmt.Errorf("Key file reference must be an absolute path: %s", reference)
+	}
+	privateKey, err := utils.CreateKeySpecPrivateKey(spec)
+	if nil != err {
+		return nil, err
+	}
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("Key spec %s can't be used for signing", spec)
+	}
+	block, err := utils.EncodePrivateKey(privateKey)
+	if nil != err {
+		return nil, err
+	}
+	file, err := os.OpenFile(reference, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if nil != err {
+		return nil, err
+	}
+	if err := pem.Encode(file, block); nil != err {
+		file.Close()
+		os.Remove(reference)
+		return nil, err
+	}
+	if err := file.Close(); nil != err {
+		os.Remove(reference)
+		return nil, err
+	}
+	utils.Infof("Wrote new private key to %s", reference)
+	return signer, nil
+}
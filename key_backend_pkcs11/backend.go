@@ -0,0 +1,149 @@
+//go:build pkcs11
+// +build pkcs11
+
+package key_backend_pkcs11
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"fmt"
+	"github.com/miekg/pkcs11"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"strconv"
+	"strings"
+)
+
+const BackendName = "pkcs11"
+
+type backend struct{}
+
+func init() {
+	types.RegisterKeyBackend(BackendName, backend{})
+}
+
+type keyReference struct {
+	module string
+	slot   uint
+	label  string
+}
+
+func parseReference(reference string) (keyReference, error) {
+	var ref keyReference
+	haveSlot := false
+	for _, part := range strings.Split(reference, ";") {
+		if 0 == len(part) {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if 2 != len(kv) {
+			return ref, fmt.Errorf("Invalid PKCS#11 key reference part %#v (expected key=value)", part)
+		}
+		switch kv[0] {
+		case "module":
+			ref.module = kv[1]
+		case "slot":
+			slot, err := strconv.ParseUint(kv[1], 10, 32)
+			if nil != err {
+				return ref, fmt.Errorf("Invalid PKCS#11 slot %#v: %s", kv[1], err)
+			}
+			ref.slot = uint(slot)
+			haveSlot = true
+		case "label":
+			ref.label = kv[1]
+		default:
+			return ref, fmt.Errorf("Unknown PKCS#11 key reference part %#v", kv[0])
+		}
+	}
+	if 0 == len(ref.module) || !haveSlot || 0 == len(ref.label) {
+		return ref, fmt.Errorf("PKCS#11 key reference needs module, slot and label: %#v", reference)
+	}
+	return ref, nil
+}
+
+func (backend) Load(UI ui.UserInterface, reference string) (crypto.Signer, error) {
+	ref, err := parseReference(reference)
+	if nil != err {
+		return nil, err
+	}
+	tok, err := openToken(UI, ref.module, ref.slot)
+	if nil != err {
+		return nil, err
+	}
+	return tok.findKey(ref.label)
+}
+
+func (backend) Generate(UI ui.UserInterface, reference string, spec utils.KeySpec) (crypto.Signer, error) {
+	ref, err := parseReference(reference)
+	if nil != err {
+		return nil, err
+	}
+	keyType, curve, rsaBits, err := spec.Parse()
+	if nil != err {
+		return nil, err
+	}
+	tok, err := openToken(UI, ref.module, ref.slot)
+	if nil != err {
+		return nil, err
+	}
+	if _, err := tok.findKey(ref.label); nil == err {
+		return nil, fmt.Errorf("Key %#v already exists on the token", ref.label)
+	}
+
+	publicTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, ref.label),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, []byte(ref.label)),
+	}
+	privateTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, ref.label),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, []byte(ref.label)),
+	}
+
+	var mechanism *pkcs11.Mechanism
+	switch keyType {
+	case utils.KeyRSA:
+		mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil)
+		publicTemplate = append(publicTemplate,
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, rsaBits))
+	case utils.KeyEcdsa:
+		var ellipticCurve elliptic.Curve
+		switch curve {
+		case utils.CurveP256:
+			ellipticCurve = elliptic.P256()
+		case utils.CurveP384:
+			ellipticCurve = elliptic.P384()
+		case utils.CurveP521:
+			ellipticCurve = elliptic.P521()
+		default:
+			return nil, utils.UnknownCurve
+		}
+		params, err := asn1.Marshal(curveOIDs[ellipticCurve])
+		if nil != err {
+			return nil, err
+		}
+		mechanism = pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)
+		publicTemplate = append(publicTemplate,
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params))
+	default:
+		return nil, fmt.Errorf("Key spec %s not supported on PKCS#11 tokens", spec)
+	}
+
+	if err := tok.generateKeyPair(mechanism, publicTemplate, privateTemplate); nil != err {
+		return nil, fmt.Errorf("Couldn't generate key on token: %s", err)
+	}
+	return tok.findKey(ref.label)
+}
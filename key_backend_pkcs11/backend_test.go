@@ -0,0 +1,168 @@
+//go:build pkcs11
+// +build pkcs11
+
+package key_backend_pkcs11
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"github.com/miekg/pkcs11"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"math/big"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// Runs against SoftHSM when SOFTHSM2_CONF is set; needs an initialized
+// token:
+//
+//	softhsm2-util --init-token --free --label acme-test --pin 1234 --so-pin 1234
+//	PKCS11_TEST_SLOT=<slot from softhsm2-util> go test -tags pkcs11 ./key_backend_pkcs11
+//
+// SOFTHSM2_MODULE (default /usr/lib/softhsm/libsofthsm2.so) and
+// PKCS11_TEST_PIN (default 1234) can be changed.
+
+// answers the PIN prompt
+type pinUI struct {
+	ui.UserInterface
+	pin string
+}
+
+func (p pinUI) PasswordPrompt(prompt string) (string, error) {
+	return p.pin, nil
+}
+
+func softHSM(t *testing.T) (ui.UserInterface, string, uint) {
+	if 0 == len(os.Getenv("SOFTHSM2_CONF")) {
+		t.Skip("SOFTHSM2_CONF not set")
+	}
+	module := os.Getenv("SOFTHSM2_MODULE")
+	if 0 == len(module) {
+		module = "/usr/lib/softhsm/libsofthsm2.so"
+	}
+	slot, err := strconv.ParseUint(os.Getenv("PKCS11_TEST_SLOT"), 10, 32)
+	if nil != err {
+		t.Fatalf("PKCS11_TEST_SLOT needs to name the slot of an initialized SoftHSM token: %s", err)
+	}
+	pin := os.Getenv("PKCS11_TEST_PIN")
+	if 0 == len(pin) {
+		pin = "1234"
+	}
+	return pinUI{UserInterface: ui.CLI, pin: pin}, module, uint(slot)
+}
+
+// new key on the token, removed when the test ends
+func generateTestKey(t *testing.T, spec utils.KeySpec) crypto.Signer {
+	UI, module, slot := softHSM(t)
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); nil != err {
+		t.Fatal(err)
+	}
+	label := "acme-test-" + string(spec) + "-" + hex.EncodeToString(random)
+	reference := "module=" + module + ";slot=" + strconv.FormatUint(uint64(slot), 10) + ";label=" + label
+
+	generated, err := backend{}.Generate(UI, reference, spec)
+	if nil != err {
+		t.Fatalf("Generating %s key failed: %s", spec, err)
+	}
+	t.Cleanup(func() {
+		tok := generated.(*signer).tok
+		tok.mutex.Lock()
+		defer tok.mutex.Unlock()
+		for _, class := range []uint{pkcs11.CKO_PRIVATE_KEY, pkcs11.CKO_PUBLIC_KEY} {
+			if object, err := tok.findObject(class, label); nil == err {
+				tok.ctx.DestroyObject(tok.session, object)
+			}
+		}
+	})
+
+	// reads the public key (and its CK_ULONG key type) back from the token
+	loaded, err := backend{}.Load(UI, reference)
+	if nil != err {
+		t.Fatalf("Loading %s key failed: %s", spec, err)
+	}
+	expected, _ := utils.PublicKeyFingerprint(generated.Public())
+	if actual, err := utils.PublicKeyFingerprint(loaded.Public()); nil != err {
+		t.Fatal(err)
+	} else if expected != actual {
+		t.Fatalf("Loaded %s key doesn't match the generated one", spec)
+	}
+	if keySpec, err := utils.KeySpecOf(loaded.Public()); nil != err || spec != keySpec {
+		t.Fatalf("Expected key spec %s, got %s (%v)", spec, keySpec, err)
+	}
+	return loaded
+}
+
+func testCSR(t *testing.T, signer crypto.Signer) {
+	csr, err := utils.MakeCertificateRequest(utils.CertificateRequestParameters{
+		PrivateKey: signer,
+		DNSNames:   []string{"example.com"},
+	})
+	if nil != err {
+		t.Fatalf("Couldn't create certificate request: %s", err)
+	}
+	request, err := x509.ParseCertificateRequest(csr.Bytes)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err := request.CheckSignature(); nil != err {
+		t.Fatalf("Invalid CSR signature: %s", err)
+	}
+}
+
+// signs a JWS with the token key and returns the digest and the raw
+// signature
+func testJWS(t *testing.T, signer crypto.Signer, hash crypto.Hash) ([]byte, []byte) {
+	skey, err := types.NewSigningKey(signer)
+	if nil != err {
+		t.Fatal(err)
+	}
+	jws, err := skey.Sign([]byte(`{"resource":"new-reg"}`), "nonce")
+	if nil != err {
+		t.Fatalf("Couldn't sign JWS: %s", err)
+	}
+	signature, err := utils.Base64UrlDecode(jws.Signature)
+	if nil != err {
+		t.Fatal(err)
+	}
+	h := hash.New()
+	h.Write([]byte(jws.Protected + "." + jws.Payload))
+	return h.Sum(nil), signature
+}
+
+func TestECKey(t *testing.T) {
+	signer := generateTestKey(t, utils.KeySpecEC256)
+	testCSR(t, signer)
+
+	// token R || S converted to DER and back to R || S for the JWS
+	digest, signature := testJWS(t, signer, crypto.SHA256)
+	if 64 != len(signature) {
+		t.Fatalf("Invalid ES256 signature length %d", len(signature))
+	}
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(signer.Public().(*ecdsa.PublicKey), digest, r, s) {
+		t.Fatal("Invalid ES256 signature")
+	}
+}
+
+func TestRSAKey(t *testing.T) {
+	signer := generateTestKey(t, utils.KeySpecRSA2048)
+	// PKCS#1 v1.5 with DigestInfo prefix
+	testCSR(t, signer)
+
+	// PSS parameters (SHA-512, MGF1 SHA-512, salt length of the hash)
+	digest, signature := testJWS(t, signer, crypto.SHA512)
+	if err := rsa.VerifyPSS(signer.Public().(*rsa.PublicKey), crypto.SHA512, digest, signature, &rsa.PSSOptions{
+		SaltLength: rsa.PSSSaltLengthEqualsHash,
+	}); nil != err {
+		t.Fatalf("Invalid PS512 signature: %s", err)
+	}
+}
@@ -0,0 +1,6 @@
+// Package key_backend_pkcs11 keeps registration and certificate keys on a
+// PKCS#11 token (e.g. SoftHSM); it needs cgo and is only built with the
+// "pkcs11" build tag.
+//
+// Keys are referenced as "module=/path/to/module.so;slot=0;label=name".
+package key_backend_pkcs11
@@ -0,0 +1,79 @@
+//go:build pkcs11
+// +build pkcs11
+
+package key_backend_pkcs11
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"github.com/miekg/pkcs11"
+	"io"
+	"math/big"
+)
+
+// crypto.Signer for a private key on the token
+type signer struct {
+	tok        *token
+	privateKey pkcs11.ObjectHandle
+	publicKey  crypto.PublicKey
+}
+
+// DigestInfo prefixes for PKCS#1 v1.5 signatures (RFC 3447 section 9.2)
+var pkcs1Prefixes = map[crypto.Hash][]byte{
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+var pssMechanisms = map[crypto.Hash][2]uint{
+	crypto.SHA256: {pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256},
+	crypto.SHA384: {pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384},
+	crypto.SHA512: {pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512},
+}
+
+func (s *signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+func (s *signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	switch pubKey := s.publicKey.(type) {
+	case *rsa.PublicKey:
+		if pssOpts, ok := opts.(*rsa.PSSOptions); ok {
+			mechs, ok := pssMechanisms[pssOpts.Hash]
+			if !ok {
+				return nil, fmt.Errorf("Unsupported hash for PSS: %v", pssOpts.Hash)
+			}
+			saltLength := pssOpts.SaltLength
+			if rsa.PSSSaltLengthEqualsHash == saltLength || rsa.PSSSaltLengthAuto == saltLength {
+				saltLength = pssOpts.Hash.Size()
+			}
+			params := pkcs11.NewPSSParams(mechs[0], mechs[1], uint(saltLength))
+			return s.tok.sign(pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS, params), s.privateKey, digest)
+		}
+		prefix, ok := pkcs1Prefixes[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported hash for PKCS#1 v1.5: %v", opts.HashFunc())
+		}
+		return s.tok.sign(pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil), s.privateKey, append(append([]byte{}, prefix...), digest...))
+	case *ecdsa.PublicKey:
+		raw, err := s.tok.sign(pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil), s.privateKey, digest)
+		if nil != err {
+			return nil, err
+		}
+		if 0 != len(raw)%2 {
+			return nil, fmt.Errorf("Invalid ECDSA signature length %d from token", len(raw))
+		}
+		// token returns R || S, crypto.Signer returns ASN.1 DER
+		return asn1.Marshal(struct {
+			R, S *big.Int
+		}{
+			R: new(big.Int).SetBytes(raw[:len(raw)/2]),
+			S: new(big.Int).SetBytes(raw[len(raw)/2:]),
+		})
+	default:
+		return nil, fmt.Errorf("Unknown public key type %T", pubKey)
+	}
+}
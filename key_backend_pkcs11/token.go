@@ -0,0 +1,208 @@
+//go:build pkcs11
+// +build pkcs11
+
+package key_backend_pkcs11
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"github.com/miekg/pkcs11"
+	"github.com/stbuehler/go-acme-client/ui"
+	"math/big"
+	"sync"
+)
+
+var curveOIDs = map[elliptic.Curve]asn1.ObjectIdentifier{
+	elliptic.P256(): asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7},
+	elliptic.P384(): asn1.ObjectIdentifier{1, 3, 132, 0, 34},
+	elliptic.P521(): asn1.ObjectIdentifier{1, 3, 132, 0, 35},
+}
+
+// logged in session on a slot; a session handles one operation at a time
+type token struct {
+	mutex   sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+}
+
+var tokensMutex sync.Mutex
+var tokens = make(map[string]*token)
+
+func openToken(UI ui.UserInterface, module string, slot uint) (*token, error) {
+	tokensMutex.Lock()
+	defer tokensMutex.Unlock()
+
+	tokenKey := fmt.Sprintf("%s#%d", module, slot)
+	if tok, ok := tokens[tokenKey]; ok {
+		return tok, nil
+	}
+
+	ctx := pkcs11.New(module)
+	if nil == ctx {
+		return nil, fmt.Errorf("Couldn't load PKCS#11 module %s", module)
+	}
+	if err := ctx.Initialize(); nil != err {
+		ctx.Destroy()
+		return nil, fmt.Errorf("Couldn't initialize PKCS#11 module %s: %s", module, err)
+	}
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if nil != err {
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, fmt.Errorf("Couldn't open session on PKCS#11 slot %d: %s", slot, err)
+	}
+	pin, err := UI.PasswordPrompt(fmt.Sprintf("Enter PIN for PKCS#11 slot %d", slot))
+	if nil != err {
+		ctx.CloseSession(session)
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+	if err := ctx.Login(session, pkcs11.CKU_USER, pin); nil != err && pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) != err {
+		ctx.CloseSession(session)
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, fmt.Errorf("Couldn't login to PKCS#11 slot %d: %s", slot, err)
+	}
+
+	tok := &token{ctx: ctx, session: session}
+	tokens[tokenKey] = tok
+	return tok, nil
+}
+
+func (tok *token) findObject(class uint, label string) (pkcs11.ObjectHandle, error) {
+	if err := tok.ctx.FindObjectsInit(tok.session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}); nil != err {
+		return 0, err
+	}
+	objects, _, err := tok.ctx.FindObjects(tok.session, 2)
+	if finalErr := tok.ctx.FindObjectsFinal(tok.session); nil == err {
+		err = finalErr
+	}
+	if nil != err {
+		return 0, err
+	}
+	switch len(objects) {
+	case 0:
+		return 0, fmt.Errorf("No key %#v on the token", label)
+	case 1:
+		return objects[0], nil
+	default:
+		return 0, fmt.Errorf("Multiple keys %#v on the token", label)
+	}
+}
+
+func (tok *token) findKey(label string) (*signer, error) {
+	tok.mutex.Lock()
+	defer tok.mutex.Unlock()
+
+	privateKey, err := tok.findObject(pkcs11.CKO_PRIVATE_KEY, label)
+	if nil != err {
+		return nil, err
+	}
+	publicKeyObject, err := tok.findObject(pkcs11.CKO_PUBLIC_KEY, label)
+	if nil != err {
+		return nil, err
+	}
+	publicKey, err := tok.readPublicKey(publicKeyObject)
+	if nil != err {
+		return nil, fmt.Errorf("Couldn't read public key %#v from the token: %s", label, err)
+	}
+	return &signer{
+		tok:        tok,
+		privateKey: privateKey,
+		publicKey:  publicKey,
+	}, nil
+}
+
+func (tok *token) readPublicKey(object pkcs11.ObjectHandle) (crypto.PublicKey, error) {
+	attrs, err := tok.ctx.GetAttributeValue(tok.session, object, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if nil != err {
+		return nil, err
+	}
+	keyType := new(big.Int).SetBytes(reverse(attrs[0].Value)).Uint64()
+
+	switch keyType {
+	case pkcs11.CKK_RSA:
+		attrs, err := tok.ctx.GetAttributeValue(tok.session, object, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+		if nil != err {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attrs[0].Value),
+			E: int(new(big.Int).SetBytes(attrs[1].Value).Int64()),
+		}, nil
+	case pkcs11.CKK_EC:
+		attrs, err := tok.ctx.GetAttributeValue(tok.session, object, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+		if nil != err {
+			return nil, err
+		}
+		var oid asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(attrs[0].Value, &oid); nil != err {
+			return nil, err
+		}
+		var curve elliptic.Curve
+		for c, cOID := range curveOIDs {
+			if cOID.Equal(oid) {
+				curve = c
+			}
+		}
+		if nil == curve {
+			return nil, fmt.Errorf("Unsupported curve %s", oid)
+		}
+		// the point is wrapped in an OCTET STRING
+		var point []byte
+		if _, err := asn1.Unmarshal(attrs[1].Value, &point); nil != err {
+			return nil, err
+		}
+		x, y := elliptic.Unmarshal(curve, point)
+		if nil == x {
+			return nil, fmt.Errorf("Invalid EC point")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported key type %d", keyType)
+	}
+}
+
+func (tok *token) generateKeyPair(mechanism *pkcs11.Mechanism, publicTemplate, privateTemplate []*pkcs11.Attribute) error {
+	tok.mutex.Lock()
+	defer tok.mutex.Unlock()
+
+	_, _, err := tok.ctx.GenerateKeyPair(tok.session, []*pkcs11.Mechanism{mechanism}, publicTemplate, privateTemplate)
+	return err
+}
+
+func (tok *token) sign(mechanism *pkcs11.Mechanism, privateKey pkcs11.ObjectHandle, data []byte) ([]byte, error) {
+	tok.mutex.Lock()
+	defer tok.mutex.Unlock()
+
+	if err := tok.ctx.SignInit(tok.session, []*pkcs11.Mechanism{mechanism}, privateKey); nil != err {
+		return nil, err
+	}
+	return tok.ctx.Sign(tok.session, data)
+}
+
+// CK_ULONG attributes are in native (little endian on all supported
+// platforms) byte order
+func reverse(data []byte) []byte {
+	result := make([]byte, len(data))
+	for i, b := range data {
+		result[len(data)-1-i] = b
+	}
+	return result
+}
@@ -0,0 +1,117 @@
+package key_backend_ssh_agent
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ssh-agent protocol (draft-miller-ssh-agent), only the requests needed to
+// list and use keys
+
+const (
+	agentFailure           = 5
+	agentRequestIdentities = 11
+	agentIdentitiesAnswer  = 12
+	agentSignRequest       = 13
+	agentSignResponse      = 14
+)
+
+// larger answers are refused
+const maxAgentMessage = 256 * 1024
+
+var malformedAgentMessage = errors.New("Malformed message from ssh-agent")
+
+type identity struct {
+	blob    []byte // public key in SSH wire format
+	comment string
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	return append(buf, b[:]...)
+}
+
+func appendString(buf []byte, s []byte) []byte {
+	return append(appendUint32(buf, uint32(len(s))), s...)
+}
+
+func readUint32(data []byte) (uint32, []byte, bool) {
+	if len(data) < 4 {
+		return 0, nil, false
+	}
+	return binary.BigEndian.Uint32(data), data[4:], true
+}
+
+func readString(data []byte) ([]byte, []byte, bool) {
+	length, data, ok := readUint32(data)
+	if !ok || uint32(len(data)) < length {
+		return nil, nil, false
+	}
+	return data[:length], data[length:], true
+}
+
+func roundTrip(conn io.ReadWriter, request []byte) ([]byte, error) {
+	if _, err := conn.Write(appendString(nil, request)); nil != err {
+		return nil, err
+	}
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); nil != err {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if 0 == length || length > maxAgentMessage {
+		return nil, fmt.Errorf("Invalid ssh-agent message length %d", length)
+	}
+	response := make([]byte, length)
+	if _, err := io.ReadFull(conn, response); nil != err {
+		return nil, err
+	}
+	return response, nil
+}
+
+func listIdentities(conn io.ReadWriter) ([]identity, error) {
+	response, err := roundTrip(conn, []byte{agentRequestIdentities})
+	if nil != err {
+		return nil, err
+	} else if agentIdentitiesAnswer != response[0] {
+		return nil, fmt.Errorf("Unexpected answer %d from ssh-agent when listing keys", response[0])
+	}
+	count, data, ok := readUint32(response[1:])
+	if !ok {
+		return nil, malformedAgentMessage
+	}
+	var identities []identity
+	for ndx := uint32(0); ndx < count; ndx++ {
+		var blob, comment []byte
+		if blob, data, ok = readString(data); !ok {
+			return nil, malformedAgentMessage
+		} else if comment, data, ok = readString(data); !ok {
+			return nil, malformedAgentMessage
+		}
+		identities = append(identities, identity{blob: blob, comment: string(comment)})
+	}
+	return identities, nil
+}
+
+// returns the signature in SSH wire format
+func agentSign(conn io.ReadWriter, blob []byte, data []byte) ([]byte, error) {
+	request := appendString([]byte{agentSignRequest}, blob)
+	request = appendString(request, data)
+	request = appendUint32(request, 0) // flags only matter for RSA
+	response, err := roundTrip(conn, request)
+	if nil != err {
+		return nil, err
+	} else if agentFailure == response[0] {
+		return nil, errors.New("ssh-agent refused to sign (key removed, agent locked or confirmation denied?)")
+	} else if agentSignResponse != response[0] {
+		return nil, fmt.Errorf("Unexpected answer %d from ssh-agent when signing", response[0])
+	}
+	signature, _, ok := readString(response[1:])
+	if !ok {
+		return nil, malformedAgentMessage
+	}
+	return signature, nil
+}
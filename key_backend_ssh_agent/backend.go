@@ -0,0 +1,116 @@
+package key_backend_ssh_agent
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"io"
+	"net"
+	"os"
+)
+
+const BackendName = "ssh-agent"
+
+// Backend opens a new connection with Dial for each request (agents close
+// idle connections); a custom Dial can connect to an in-process agent
+type Backend struct {
+	Dial func() (io.ReadWriteCloser, error)
+}
+
+func init() {
+	types.RegisterKeyBackend(BackendName, Backend{Dial: DialAuthSock})
+}
+
+// connect to the agent from SSH_AUTH_SOCK
+func DialAuthSock() (io.ReadWriteCloser, error) {
+	path := os.Getenv("SSH_AUTH_SOCK")
+	if 0 == len(path) {
+		return nil, errors.New("SSH_AUTH_SOCK not set, no ssh-agent available")
+	}
+	return net.Dial("unix", path)
+}
+
+func (backend Backend) Load(UI ui.UserInterface, reference string) (crypto.Signer, error) {
+	conn, err := backend.Dial()
+	if nil != err {
+		return nil, err
+	}
+	defer conn.Close()
+	identities, err := listIdentities(conn)
+	if nil != err {
+		return nil, err
+	}
+
+	var matches []identity
+	for _, id := range identities {
+		if id.comment == reference || fingerprint(id.blob) == reference {
+			matches = append(matches, id)
+		}
+	}
+	if 0 == len(matches) {
+		return nil, fmt.Errorf("No key %#v in ssh-agent (use the comment or the SHA256 fingerprint shown by \"ssh-add -l\")", reference)
+	} else if len(matches) > 1 {
+		return nil, fmt.Errorf("%d keys in ssh-agent match %#v, use the SHA256 fingerprint instead", len(matches), reference)
+	}
+
+	publicKey, err := parsePublicKey(matches[0].blob)
+	if nil != err {
+		return nil, err
+	}
+	return &signer{
+		dial:      backend.Dial,
+		blob:      matches[0].blob,
+		publicKey: publicKey,
+	}, nil
+}
+
+// crypto.Signer (and types.MessageSigner) for a key in the agent
+type signer struct {
+	dial      func() (io.ReadWriteCloser, error)
+	blob      []byte
+	publicKey crypto.PublicKey
+}
+
+func (s *signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// only works for Ed25519 (which signs the message itself); the agent
+// can't sign ECDSA digests, see SignMessage
+func (s *signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := s.publicKey.(ed25519.PublicKey); ok && 0 == opts.HashFunc() {
+		return s.sign(digest)
+	}
+	return nil, errors.New("ssh-agent can't sign digests, only messages")
+}
+
+func (s *signer) SignMessage(rand io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	switch pubKey := s.publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if hash := curveHash(pubKey.Curve); hash != opts.HashFunc() {
+			return nil, fmt.Errorf("ssh-agent signs %s keys with %v, not %v", pubKey.Curve.Params().Name, hash, opts.HashFunc())
+		}
+	case ed25519.PublicKey:
+		if 0 != opts.HashFunc() {
+			return nil, errors.New("ssh-agent only supports pure Ed25519 signatures")
+		}
+	}
+	return s.sign(message)
+}
+
+func (s *signer) sign(data []byte) ([]byte, error) {
+	conn, err := s.dial()
+	if nil != err {
+		return nil, err
+	}
+	defer conn.Close()
+	signature, err := agentSign(conn, s.blob, data)
+	if nil != err {
+		return nil, err
+	}
+	return parseSignature(s.publicKey, signature)
+}
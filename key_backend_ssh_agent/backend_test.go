@@ -0,0 +1,298 @@
+package key_backend_ssh_agent
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+)
+
+type testKey struct {
+	privateKey crypto.Signer
+	comment    string
+}
+
+func (key testKey) blob() []byte {
+	switch pubKey := key.privateKey.Public().(type) {
+	case *ecdsa.PublicKey:
+		curveName := "nistp" + strings.TrimPrefix(pubKey.Curve.Params().Name, "P-")
+		blob := appendString(nil, []byte("ecdsa-sha2-"+curveName))
+		blob = appendString(blob, []byte(curveName))
+		return appendString(blob, elliptic.Marshal(pubKey.Curve, pubKey.X, pubKey.Y))
+	case ed25519.PublicKey:
+		return appendString(appendString(nil, []byte("ssh-ed25519")), pubKey)
+	}
+	panic("unsupported test key")
+}
+
+// signature in SSH wire format, as the agent would create it
+func (key testKey) sign(data []byte) []byte {
+	switch privateKey := key.privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		h := curveHash(privateKey.Curve).New()
+		h.Write(data)
+		der, err := ecdsa.SignASN1(rand.Reader, privateKey, h.Sum(nil))
+		if nil != err {
+			panic(err)
+		}
+		var sig ecdsaSignature
+		if _, err := asn1.Unmarshal(der, &sig); nil != err {
+			panic(err)
+		}
+		format, _, _ := readString(key.blob())
+		blob := appendString(appendString(nil, sig.R.Bytes()), sig.S.Bytes())
+		return appendString(appendString(nil, format), blob)
+	case ed25519.PrivateKey:
+		return appendString(appendString(nil, []byte("ssh-ed25519")), ed25519.Sign(privateKey, data))
+	}
+	panic("unsupported test key")
+}
+
+// in-process agent; reply (if set) overrides the answer to all requests
+type testAgent struct {
+	keys  []testKey
+	reply []byte
+}
+
+func (agent *testAgent) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(conn, header[:]); nil != err {
+			return
+		}
+		request := make([]byte, binary.BigEndian.Uint32(header[:]))
+		if _, err := io.ReadFull(conn, request); nil != err {
+			return
+		}
+		response := agent.reply
+		if nil == response {
+			response = agent.handle(request)
+		}
+		if _, err := conn.Write(appendString(nil, response)); nil != err {
+			return
+		}
+	}
+}
+
+func (agent *testAgent) handle(request []byte) []byte {
+	switch request[0] {
+	case agentRequestIdentities:
+		response := appendUint32([]byte{agentIdentitiesAnswer}, uint32(len(agent.keys)))
+		for _, key := range agent.keys {
+			response = appendString(response, key.blob())
+			response = appendString(response, []byte(key.comment))
+		}
+		return response
+	case agentSignRequest:
+		blob, data, _ := readString(request[1:])
+		data, _, _ = readString(data)
+		for _, key := range agent.keys {
+			if string(key.blob()) == string(blob) {
+				return appendString([]byte{agentSignResponse}, key.sign(data))
+			}
+		}
+	}
+	return []byte{agentFailure}
+}
+
+func (agent *testAgent) backend() Backend {
+	return Backend{Dial: func() (io.ReadWriteCloser, error) {
+		client, server := net.Pipe()
+		go agent.serve(server)
+		return client, nil
+	}}
+}
+
+func newTestAgent(t *testing.T) *testAgent {
+	agent := &testAgent{}
+	for ndx, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if nil != err {
+			t.Fatal(err)
+		}
+		agent.keys = append(agent.keys, testKey{privateKey: privateKey, comment: []string{"ec256", "ec384", "ec521"}[ndx]})
+	}
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	agent.keys = append(agent.keys, testKey{privateKey: privateKey, comment: "ed25519"})
+	return agent
+}
+
+// checks the JWS signature with the stdlib and returns the protected header
+func verifyJWS(t *testing.T, jws *types.JsonWebSignature, publicKey crypto.PublicKey) map[string]interface{} {
+	protected, err := utils.Base64UrlDecode(jws.Protected)
+	if nil != err {
+		t.Fatal(err)
+	}
+	var header map[string]interface{}
+	if err := json.Unmarshal(protected, &header); nil != err {
+		t.Fatal(err)
+	}
+	signature, err := utils.Base64UrlDecode(jws.Signature)
+	if nil != err {
+		t.Fatal(err)
+	}
+	signingInput := []byte(jws.Protected + "." + jws.Payload)
+
+	switch pubKey := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		expectedAlg := map[int]string{256: "ES256", 384: "ES384", 521: "ES512"}[pubKey.Curve.Params().BitSize]
+		if header["alg"] != expectedAlg {
+			t.Fatalf("Expected alg %s, got %v", expectedAlg, header["alg"])
+		}
+		size := (pubKey.Curve.Params().BitSize + 7) / 8
+		if 2*size != len(signature) {
+			t.Fatalf("Invalid signature length %d", len(signature))
+		}
+		h := curveHash(pubKey.Curve).New()
+		h.Write(signingInput)
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pubKey, h.Sum(nil), r, s) {
+			t.Fatalf("Invalid %s signature", expectedAlg)
+		}
+	case ed25519.PublicKey:
+		if header["alg"] != "EdDSA" {
+			t.Fatalf("Expected alg EdDSA, got %v", header["alg"])
+		}
+		jwk, _ := header["jwk"].(map[string]interface{})
+		if jwk["kty"] != "OKP" || jwk["crv"] != "Ed25519" || jwk["x"] != utils.Base64UrlEncode(pubKey) {
+			t.Fatalf("Unexpected jwk %v", header["jwk"])
+		}
+		if !ed25519.Verify(pubKey, signingInput, signature) {
+			t.Fatal("Invalid EdDSA signature")
+		}
+	}
+	return header
+}
+
+func TestLoadByCommentAndFingerprint(t *testing.T) {
+	agent := newTestAgent(t)
+	backend := agent.backend()
+	for _, key := range agent.keys {
+		for _, reference := range []string{key.comment, fingerprint(key.blob())} {
+			signer, err := backend.Load(ui.CLI, reference)
+			if nil != err {
+				t.Fatalf("Loading %s failed: %s", reference, err)
+			}
+			expected, _ := utils.PublicKeyFingerprint(key.privateKey.Public())
+			if actual, _ := utils.PublicKeyFingerprint(signer.Public()); expected != actual {
+				t.Fatalf("Loaded wrong key for %s", reference)
+			}
+		}
+	}
+
+	if _, err := backend.Load(ui.CLI, "missing"); nil == err {
+		t.Fatal("Loaded a missing key")
+	}
+	agent.keys = append(agent.keys, testKey{privateKey: agent.keys[0].privateKey, comment: "ec384"})
+	if _, err := backend.Load(ui.CLI, "ec384"); nil == err || !strings.Contains(err.Error(), "fingerprint") {
+		t.Fatalf("Expected error for ambiguous comment, got %v", err)
+	}
+}
+
+func TestRegistrationJWS(t *testing.T) {
+	agent := newTestAgent(t)
+	backend := agent.backend()
+	payload := []byte(`{"resource":"new-reg"}`)
+	for _, key := range agent.keys {
+		signer, err := backend.Load(ui.CLI, key.comment)
+		if nil != err {
+			t.Fatal(err)
+		}
+		skey, err := types.NewSigningKey(signer)
+		if nil != err {
+			t.Fatalf("%s: %s", key.comment, err)
+		}
+		jws, err := skey.Sign(payload, "nonce-"+key.comment)
+		if nil != err {
+			t.Fatalf("%s: %s", key.comment, err)
+		}
+		header := verifyJWS(t, jws, key.privateKey.Public())
+		if header["nonce"] != "nonce-"+key.comment {
+			t.Fatalf("Unexpected nonce %v", header["nonce"])
+		}
+
+		// and through the parser and verifier used for offline bundles
+		var signedPayload []byte
+		if err := skey.Verify(jws.FullSerialize(), &signedPayload, nil); nil != err {
+			t.Fatalf("%s: %s", key.comment, err)
+		} else if string(payload) != string(signedPayload) {
+			t.Fatalf("Unexpected payload %s", signedPayload)
+		}
+	}
+}
+
+func TestAgentErrors(t *testing.T) {
+	agent := newTestAgent(t)
+	backend := agent.backend()
+	signer, err := backend.Load(ui.CLI, "ec256")
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// the agent can't sign ECDSA digests
+	if _, err := signer.Sign(rand.Reader, make([]byte, 32), crypto.SHA256); nil == err {
+		t.Fatal("Signing a digest should fail")
+	}
+	// nistp256 keys are always signed with SHA-256
+	if _, err := signer.(types.MessageSigner).SignMessage(rand.Reader, []byte("data"), crypto.SHA512); nil == err {
+		t.Fatal("Signing with the wrong hash should fail")
+	}
+
+	for name, reply := range map[string][]byte{
+		"failure":             {agentFailure},
+		"unexpected":          {agentSignResponse + 1},
+		"truncated signature": append([]byte{agentSignResponse}, 0, 0, 0, 10, 1),
+		"truncated blob":      appendString([]byte{agentSignResponse}, appendString(nil, []byte("ecdsa-sha2-nistp256"))),
+		"wrong format":        appendString([]byte{agentSignResponse}, appendString(appendString(nil, []byte("ssh-ed25519")), make([]byte, 64))),
+	} {
+		agent.reply = reply
+		if _, err := signer.(types.MessageSigner).SignMessage(rand.Reader, []byte("data"), crypto.SHA256); nil == err {
+			t.Fatalf("%s: signing should fail", name)
+		}
+	}
+
+	for name, reply := range map[string][]byte{
+		"failure":          {agentFailure},
+		"missing count":    {agentIdentitiesAnswer, 0, 0},
+		"truncated key":    {agentIdentitiesAnswer, 0, 0, 0, 1, 0, 0, 0, 5, 1},
+		"missing comment":  appendString([]byte{agentIdentitiesAnswer, 0, 0, 0, 1}, agent.keys[0].blob()),
+		"unsupported type": appendString(appendString([]byte{agentIdentitiesAnswer, 0, 0, 0, 1}, appendString(nil, []byte("ssh-rsa"))), []byte("ec256")),
+	} {
+		agent.reply = reply
+		if _, err := backend.Load(ui.CLI, "ec256"); nil == err {
+			t.Fatalf("%s: loading should fail", name)
+		}
+	}
+}
+
+func TestOversizedAnswer(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		var header [4]byte
+		io.ReadFull(server, header[:])
+		io.ReadFull(server, make([]byte, binary.BigEndian.Uint32(header[:])))
+		server.Write(appendUint32(nil, maxAgentMessage+1))
+	}()
+	defer client.Close()
+	if _, err := listIdentities(client); nil == err {
+		t.Fatal("Oversized answer should be refused")
+	}
+}
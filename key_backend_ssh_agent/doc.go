@@ -0,0 +1,9 @@
+// Package key_backend_ssh_agent uses ECDSA and Ed25519 keys held by an
+// ssh-agent (reached through SSH_AUTH_SOCK, e.g. backed by a hardware
+// token) as registration or certificate keys; only the public key and the
+// reference are stored.
+//
+// Keys are referenced by their comment or their SHA256 fingerprint as
+// shown by "ssh-add -l". The agent hashes the data itself, so the signers
+// implement types.MessageSigner.
+package key_backend_ssh_agent
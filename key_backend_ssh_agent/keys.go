@@ -0,0 +1,113 @@
+package key_backend_ssh_agent
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var sshCurves = map[string]elliptic.Curve{
+	"nistp256": elliptic.P256(),
+	"nistp384": elliptic.P384(),
+	"nistp521": elliptic.P521(),
+}
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// fingerprint as shown by "ssh-add -l"
+func fingerprint(blob []byte) string {
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// hash the agent uses for ECDSA signatures (RFC 5656 section 6.2.1)
+func curveHash(curve elliptic.Curve) crypto.Hash {
+	switch curve.Params().BitSize {
+	case 256:
+		return crypto.SHA256
+	case 384:
+		return crypto.SHA384
+	default:
+		return crypto.SHA512
+	}
+}
+
+func parsePublicKey(blob []byte) (crypto.PublicKey, error) {
+	keyType, data, ok := readString(blob)
+	if !ok {
+		return nil, malformedAgentMessage
+	}
+	switch string(keyType) {
+	case "ssh-ed25519":
+		key, _, ok := readString(data)
+		if !ok || ed25519.PublicKeySize != len(key) {
+			return nil, malformedAgentMessage
+		}
+		return ed25519.PublicKey(append([]byte{}, key...)), nil
+	case "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521":
+		curveName, data, ok := readString(data)
+		if !ok || "ecdsa-sha2-"+string(curveName) != string(keyType) {
+			return nil, malformedAgentMessage
+		}
+		point, _, ok := readString(data)
+		if !ok {
+			return nil, malformedAgentMessage
+		}
+		curve := sshCurves[string(curveName)]
+		x, y := elliptic.Unmarshal(curve, point)
+		if nil == x {
+			return nil, fmt.Errorf("Invalid %s public key from ssh-agent", keyType)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported ssh key type %s (only ECDSA and Ed25519 keys can be used)", keyType)
+	}
+}
+
+// converts SSH signatures to the format crypto.Signer returns (ASN.1 DER
+// for ECDSA)
+func parseSignature(publicKey crypto.PublicKey, signature []byte) ([]byte, error) {
+	format, data, ok := readString(signature)
+	if !ok {
+		return nil, malformedAgentMessage
+	}
+	blob, _, ok := readString(data)
+	if !ok {
+		return nil, malformedAgentMessage
+	}
+	switch pubKey := publicKey.(type) {
+	case ed25519.PublicKey:
+		if "ssh-ed25519" != string(format) || ed25519.SignatureSize != len(blob) {
+			return nil, fmt.Errorf("Unexpected %s signature from ssh-agent", format)
+		}
+		return blob, nil
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(string(format), "ecdsa-sha2-") {
+			return nil, fmt.Errorf("Unexpected %s signature from ssh-agent", format)
+		}
+		r, data, ok := readString(blob)
+		if !ok {
+			return nil, malformedAgentMessage
+		}
+		s, _, ok := readString(data)
+		if !ok {
+			return nil, malformedAgentMessage
+		}
+		// mpints are positive here
+		return asn1.Marshal(ecdsaSignature{
+			R: new(big.Int).SetBytes(r),
+			S: new(big.Int).SetBytes(s),
+		})
+	default:
+		return nil, fmt.Errorf("Unknown public key type %T", pubKey)
+	}
+}
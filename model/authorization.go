@@ -3,8 +3,11 @@ package model
 import (
 	"fmt"
 	"github.com/stbuehler/go-acme-client/requests"
+	"github.com/stbuehler/go-acme-client/solver_interface"
 	"github.com/stbuehler/go-acme-client/storage_interface"
 	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"time"
 )
 
 type AuthorizationModel interface {
@@ -14,6 +17,18 @@ type AuthorizationModel interface {
 
 	UpdateChallenge(challengeResponse types.ChallengeResponding) error
 	SaveChallengeData(challengeResponse types.ChallengeResponding) error
+
+	Respond(challengeIndex int) (types.ChallengeResponding, error)
+	// present, verify and send a response with the chosen solver
+	SolveChallenge(UI ui.UserInterface, challengeIndex int, choices solver_interface.Choices) (*PresentedChallenge, error)
+	// poll until the server is done with the challenge; returns its status
+	WaitForChallenge(uri string, timeout time.Duration) (string, error)
+	// solve all challenges of the combination, waiting for each validation
+	SolveCombination(UI ui.UserInterface, plan CombinationPlan, choices solver_interface.Choices, validationWait time.Duration) ([]PresentedChallenge, error)
+	// solve the cheapest combination (falling back to others if solving
+	// fails locally); combinations needing the manual solver are skipped.
+	// returns the combination which succeeded
+	SolveAutomatically(UI ui.UserInterface, choices solver_interface.Choices, preference []string, validationWait time.Duration) (*CombinationPlan, error)
 }
 
 type authorization struct {
@@ -27,8 +42,28 @@ func (auth *authorization) Refresh() error {
 	} else {
 		authData := *auth.sauth.Authorization()
 		authData.Resource = *newAuth
-		return auth.sauth.SetAuthorization(authData)
+		if err := auth.sauth.SetAuthorization(authData); nil != err {
+			return err
+		}
+		return auth.finishChallengeAttempts()
+	}
+}
+
+// record the server's verdict in the history
+func (auth *authorization) finishChallengeAttempts() error {
+	for _, challenge := range auth.Authorization().Resource.Challenges {
+		switch status := challenge.GetStatus(); status {
+		case "valid", "invalid":
+			errorText := ""
+			if problem := challenge.GetError(); nil != problem {
+				errorText = problem.String()
+			}
+			if err := auth.sauth.FinishChallengeAttempts(challenge.GetURI(), status, errorText); nil != err {
+				return err
+			}
+		}
 	}
+	return nil
 }
 
 func (auth *authorization) Authorization() types.Authorization {
@@ -180,7 +215,7 @@ func (reg *registration) GetAuthorizationByDNS(dnsIdentifier string, refresh boo
 }
 
 func (reg *registration) NewAuthorization(dnsIdentifier string) (AuthorizationModel, error) {
-	if authData, err := requests.NewDNSAuthorization(reg.sreg.Directory(), reg.sreg.Registration().SigningKey, dnsIdentifier); nil != err {
+	if authData, err := requests.NewAuthorization(reg.sreg.Directory(), reg.sreg.Registration().SigningKey, types.NewIdentifier(dnsIdentifier)); nil != err {
 		return nil, err
 	} else if auth, err := reg.sreg.NewAuthorization(*authData); nil != err {
 		return nil, err
@@ -189,6 +224,10 @@ func (reg *registration) NewAuthorization(dnsIdentifier string) (AuthorizationMo
 	}
 }
 
+func (reg *registration) ChallengeAttempts(dnsIdentifier string) ([]storage_interface.ChallengeAttempt, error) {
+	return reg.sreg.ChallengeAttempts(dnsIdentifier)
+}
+
 func (reg *registration) AuthorizeDNS(dnsIdentifier string) (AuthorizationModel, error) {
 	if auth, err := reg.GetAuthorizationByDNS(dnsIdentifier, true /* refresh */); nil != err {
 		return nil, err
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/offline_bundle"
+	"github.com/stbuehler/go-acme-client/requests"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	"time"
+)
+
+// SubmitBundle sends all signed requests of the bundle and records the
+// results like the direct requests would. Entries with stale nonces (prepared
+// longer than maxNonceAge ago or rejected by the server) are prepared again
+// and need to be signed once more.
+func (reg *registration) SubmitBundle(bundle *offline_bundle.Bundle, maxNonceAge time.Duration) error {
+	if err := bundle.CheckKey(reg.Registration().SigningKey); nil != err {
+		return err
+	}
+
+	// results of the entries submitted before a failure are kept too
+	err := reg.submitEntries(bundle, maxNonceAge)
+	if saveErr := bundle.Save(); nil != saveErr {
+		if nil == err {
+			return fmt.Errorf("Couldn't save bundle: %s", saveErr)
+		}
+		utils.Errorf("Couldn't save bundle: %s", saveErr)
+	}
+	return err
+}
+
+func (reg *registration) submitEntries(bundle *offline_bundle.Bundle, maxNonceAge time.Duration) error {
+	for _, entry := range bundle.Entries {
+		if entry.Detached() {
+			// used by later requests
+			continue
+		}
+		switch entry.Status {
+		case offline_bundle.StatusPrepared:
+			if time.Since(entry.Prepared) > maxNonceAge {
+				utils.Infof("Entry %d: nonce too old, preparing again", entry.ID)
+				if err := bundle.Reprepare(entry); nil != err {
+					return err
+				}
+			}
+		case offline_bundle.StatusSigned:
+			resp, err := bundle.Submit(entry)
+			if offline_bundle.StaleNonce == err {
+				utils.Warningf("Entry %d: nonce expired, preparing again", entry.ID)
+				if err := bundle.Reprepare(entry); nil != err {
+					return err
+				}
+				continue
+			} else if nil != err {
+				// network problems; try again later
+				return fmt.Errorf("Entry %d: %s", entry.ID, err)
+			}
+			if result, err := reg.recordBundleResult(entry, resp); nil != err {
+				var problem types.Problem
+				if resp.StatusCode >= 400 && nil == json.Unmarshal(resp.Body, &problem) && 0 != len(problem.Type) {
+					err = fmt.Errorf("%s (%s)", err, problem.String())
+				}
+				utils.Errorf("Entry %d failed: %s", entry.ID, err)
+				entry.Status = offline_bundle.StatusFailed
+				entry.Result = err.Error()
+			} else {
+				utils.Infof("Entry %d submitted: %s", entry.ID, result)
+				entry.Status = offline_bundle.StatusSubmitted
+				entry.Result = result
+			}
+		}
+	}
+	return nil
+}
+
+func (reg *registration) recordBundleResult(entry *offline_bundle.Entry, resp *utils.HttpResponse) (string, error) {
+	switch entry.Resource() {
+	case types.Resource_NewAuthorization.String():
+		authData, err := requests.AuthorizationFromResponse(resp)
+		if nil != err {
+			return "", err
+		}
+		if _, err := reg.sreg.NewAuthorization(*authData); nil != err {
+			return "", err
+		}
+		return authData.Location, nil
+	case types.Resource_NewCertificate.String():
+		certData, err := requests.CertificateFromResponse(resp)
+		if nil != err {
+			return "", err
+		}
+		if _, err := reg.sreg.NewCertificate(*certData); nil != err {
+			return "", err
+		}
+		return certData.Location, nil
+	case types.Resource_NewRegistration.String(), types.Resource_Registration.String():
+		old := reg.Registration()
+		newReg, err := requests.RegistrationFromResponse(resp, entry.URL, old.SigningKey, &old)
+		if nil != err {
+			return "", err
+		}
+		if err := reg.sreg.SetRegistration(*newReg); nil != err {
+			return "", err
+		}
+		return newReg.Location, nil
+	case types.Resource_Challenge.String():
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return "", fmt.Errorf("%s", resp.Status)
+		}
+		auths, err := reg.Authorizations()
+		if nil != err {
+			return "", err
+		}
+		for _, auth := range auths {
+			for _, challenge := range auth.Authorization().Resource.Challenges {
+				if entry.URL == challenge.GetURI() {
+					if err := auth.Refresh(); nil != err {
+						return "", err
+					}
+					return fmt.Sprintf("authorization %s", auth.Authorization().Resource.Status), nil
+				}
+			}
+		}
+		return "challenge accepted", nil
+	default:
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return "", fmt.Errorf("%s", resp.Status)
+		}
+		return resp.Status, nil
+	}
+}
@@ -1,11 +1,13 @@
 package model
 
 import (
+	"crypto"
 	"encoding/pem"
 	"github.com/stbuehler/go-acme-client/requests"
 	"github.com/stbuehler/go-acme-client/storage_interface"
 	"github.com/stbuehler/go-acme-client/types"
 	"github.com/stbuehler/go-acme-client/utils"
+	"time"
 )
 
 type CertificateModel interface {
@@ -13,7 +15,25 @@ type CertificateModel interface {
 
 	Certificate() types.Certificate
 
+	// also records the key spec of the key
 	SetPrivateKey(privateKey interface{}) error
+
+	// for keys in a key backend only the reference is stored
+	SetPrivateKeyReference(backend string, reference string, publicKey crypto.PublicKey) error
+
+	// the other certificates issued together with this one (see
+	// RegistrationModel.LinkCertificates)
+	LinkedCertificates() ([]CertificateModel, error)
+
+	SetKeyPolicy(policy types.KeyPolicy) error
+
+	// store the private key of the previous certificate (counts as one
+	// more use of the key)
+	ReusePrivateKey(previous CertificateModel) error
+
+	// mark the certificate as replaced; the private key gets removed by
+	// RegistrationModel.PurgeReplacedKeys after a grace period
+	SetReplacedBy(replacement CertificateModel) error
 }
 
 type certificate struct {
@@ -25,6 +45,14 @@ func (cert *certificate) Refresh() error {
 	if certData, err := requests.FetchCertificate(cert.Certificate().Location); nil != err {
 		return err
 	} else {
+		// keep local data
+		certData.PrivateKey = cert.Certificate().PrivateKey
+		certData.KeySpec = cert.Certificate().KeySpec
+		certData.Group = cert.Certificate().Group
+		certData.KeyPolicy = cert.Certificate().KeyPolicy
+		certData.KeyUses = cert.Certificate().KeyUses
+		certData.ReplacedBy = cert.Certificate().ReplacedBy
+		certData.Replaced = cert.Certificate().Replaced
 		return cert.scert.SetCertificate(*certData)
 	}
 }
@@ -41,14 +69,116 @@ func (cert *certificate) SetPrivateKey(privateKey interface{}) error {
 		return nil
 	} else if privKeyPem, err := utils.EncodePrivateKey(privateKey); nil != err {
 		return err
+	} else if keySpec, err := utils.KeySpecOf(privateKey); nil != err {
+		return err
 	} else {
 		certData := cert.scert.Certificate()
 		certData.PrivateKey = privKeyPem
+		certData.KeySpec = keySpec
+		certData.KeyUses = 1
+		cert.scert.SetCertificate(*certData)
+		return nil
+	}
+}
+
+func (cert *certificate) SetPrivateKeyReference(backend string, reference string, publicKey crypto.PublicKey) error {
+	if refPem, err := types.EncodeKeyReference(backend, reference, publicKey); nil != err {
+		return err
+	} else if keySpec, err := utils.KeySpecOf(publicKey); nil != err {
+		return err
+	} else {
+		certData := cert.scert.Certificate()
+		certData.PrivateKey = refPem
+		certData.KeySpec = keySpec
+		certData.KeyUses = 1
 		cert.scert.SetCertificate(*certData)
 		return nil
 	}
 }
 
+func (cert *certificate) LinkedCertificates() ([]CertificateModel, error) {
+	certData := cert.Certificate()
+	if 0 == len(certData.Group) {
+		return nil, nil
+	}
+	certs, err := cert.reg.Certificates()
+	if nil != err {
+		return nil, err
+	}
+	var linked []CertificateModel
+	for _, other := range certs {
+		otherData := other.Certificate()
+		if otherData.Group == certData.Group && otherData.Location != certData.Location {
+			linked = append(linked, other)
+		}
+	}
+	return linked, nil
+}
+
+func (cert *certificate) SetKeyPolicy(policy types.KeyPolicy) error {
+	certData := cert.Certificate()
+	certData.KeyPolicy = policy
+	return cert.scert.SetCertificate(certData)
+}
+
+func (cert *certificate) ReusePrivateKey(previous CertificateModel) error {
+	prevData := previous.Certificate()
+	keyUses := prevData.KeyUses
+	if keyUses < 1 {
+		keyUses = 1
+	}
+	certData := cert.Certificate()
+	certData.PrivateKey = prevData.PrivateKey
+	certData.KeySpec = prevData.KeySpec
+	certData.KeyUses = keyUses + 1
+	return cert.scert.SetCertificate(certData)
+}
+
+func (cert *certificate) SetReplacedBy(replacement CertificateModel) error {
+	now := time.Now()
+	certData := cert.Certificate()
+	certData.ReplacedBy = replacement.Certificate().Location
+	certData.Replaced = &now
+	return cert.scert.SetCertificate(certData)
+}
+
+func (reg *registration) PurgeReplacedKeys(grace time.Duration) (int, error) {
+	certs, err := reg.Certificates()
+	if nil != err {
+		return 0, err
+	}
+	purged := 0
+	for _, cert := range certs {
+		certData := cert.Certificate()
+		if nil == certData.PrivateKey || nil == certData.Replaced || time.Since(*certData.Replaced) < grace {
+			continue
+		}
+		utils.Debugf("Removing private key of replaced certificate %s", certData.Location)
+		certData.PrivateKey = nil
+		if err := cert.(*certificate).scert.SetCertificate(certData); nil != err {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (reg *registration) LinkCertificates(certs []CertificateModel) error {
+	if len(certs) < 2 {
+		return nil
+	}
+	group := certs[0].Certificate().Location
+	for _, cert := range certs {
+		c := cert.(*certificate)
+		certData := c.Certificate()
+		certData.Group = group
+		if err := c.scert.SetCertificate(certData); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
 func (reg *registration) importCertificate(certURL string, refresh bool) (*certificate, error) {
 	if cert, err := reg.sreg.LoadCertificate(certURL); nil != err {
 		return nil, err
@@ -0,0 +1,279 @@
+package model
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"database/sql"
+	"encoding/pem"
+	"github.com/stbuehler/go-acme-client/storage_sql"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// registration in an in-memory database
+func testRegistration(t *testing.T) *registration {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if nil != err {
+		t.Fatal(err)
+	}
+	// each connection would get a database of its own
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	storage, err := storage_sql.Open(ui.CLI, db)
+	if nil != err {
+		t.Fatal(err)
+	}
+	storage.SetPassword("test")
+
+	sdir, err := storage.NewDirectory(types.Directory{RootURL: "https://ca.example/directory"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	skey, err := types.NewSigningKey(privateKey)
+	if nil != err {
+		t.Fatal(err)
+	}
+	sreg, err := sdir.NewRegistration(types.Registration{
+		Name:       "test",
+		Location:   "https://ca.example/reg/1",
+		SigningKey: skey,
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	return &registration{dir: &directory{sdir: sdir}, sreg: sreg}
+}
+
+// stored certificate without private key (like NewCertificate would
+// store it)
+func testCertificate(t *testing.T, reg *registration, location string) CertificateModel {
+	scert, err := reg.sreg.NewCertificate(types.Certificate{
+		Location:    location,
+		Certificate: &pem.Block{Type: "CERTIFICATE", Bytes: []byte(location)},
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	return &certificate{reg: reg, scert: scert}
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return privateKey
+}
+
+func TestKeyUses(t *testing.T) {
+	reg := testRegistration(t)
+
+	first := testCertificate(t, reg, "https://ca.example/cert/1")
+	if err := first.SetPrivateKey(newTestKey(t)); nil != err {
+		t.Fatal(err)
+	}
+	if err := first.SetKeyPolicy("rotate-3"); nil != err {
+		t.Fatal(err)
+	}
+	if 1 != first.Certificate().KeyUses || utils.KeySpecEC256 != first.Certificate().KeySpec {
+		t.Fatalf("New key: expected 1 use (ec256), got %d (%s)", first.Certificate().KeyUses, first.Certificate().KeySpec)
+	}
+
+	// each reuse counts, and the count survives loading from storage
+	previous := first
+	for uses := 2; uses <= 3; uses++ {
+		next := testCertificate(t, reg, "https://ca.example/cert/"+strconv.Itoa(uses))
+		if err := next.ReusePrivateKey(previous); nil != err {
+			t.Fatal(err)
+		}
+		loaded, err := reg.LoadCertificate(next.Certificate().Location)
+		if nil != err {
+			t.Fatal(err)
+		}
+		certData := loaded.Certificate()
+		if uses != certData.KeyUses || utils.KeySpecEC256 != certData.KeySpec {
+			t.Fatalf("Expected %d uses (ec256), got %d (%s)", uses, certData.KeyUses, certData.KeySpec)
+		}
+		if string(first.Certificate().PrivateKey.Bytes) != string(certData.PrivateKey.Bytes) {
+			t.Fatal("Reused private key differs")
+		}
+		previous = loaded
+	}
+	if types.KeyPolicy("rotate-3").ReuseKey(previous.Certificate().KeyUses) {
+		t.Fatal("rotate-3 key reused a fourth time")
+	}
+
+	// keys from before the use count was stored count as used once
+	legacy := testCertificate(t, reg, "https://ca.example/cert/legacy")
+	legacyData := legacy.Certificate()
+	legacyData.PrivateKey = first.Certificate().PrivateKey
+	if err := legacy.(*certificate).scert.SetCertificate(legacyData); nil != err {
+		t.Fatal(err)
+	}
+	renewed := testCertificate(t, reg, "https://ca.example/cert/renewed")
+	if err := renewed.ReusePrivateKey(legacy); nil != err {
+		t.Fatal(err)
+	}
+	if 2 != renewed.Certificate().KeyUses {
+		t.Fatalf("Expected 2 uses after reusing a legacy key, got %d", renewed.Certificate().KeyUses)
+	}
+
+	// a new key starts counting again
+	if err := renewed.SetPrivateKey(newTestKey(t)); nil != err {
+		t.Fatal(err)
+	}
+	if 1 != renewed.Certificate().KeyUses {
+		t.Fatalf("Expected 1 use for a new key, got %d", renewed.Certificate().KeyUses)
+	}
+}
+
+// locations of the certificates, for comparisons
+func locations(certs []CertificateModel) string {
+	var result []string
+	for _, cert := range certs {
+		result = append(result, cert.Certificate().Location)
+	}
+	sort.Strings(result)
+	return strings.Join(result, " ")
+}
+
+func TestLinkCertificates(t *testing.T) {
+	reg := testRegistration(t)
+	rsaCert := testCertificate(t, reg, "https://ca.example/cert/rsa")
+	ecCert := testCertificate(t, reg, "https://ca.example/cert/ec")
+	single := testCertificate(t, reg, "https://ca.example/cert/single")
+
+	if err := reg.LinkCertificates([]CertificateModel{rsaCert, ecCert}); nil != err {
+		t.Fatal(err)
+	}
+	// a single certificate doesn't get a group
+	if err := reg.LinkCertificates([]CertificateModel{single}); nil != err {
+		t.Fatal(err)
+	}
+	if 0 != len(single.Certificate().Group) {
+		t.Fatalf("Single certificate got group %s", single.Certificate().Group)
+	}
+	if linked, err := single.LinkedCertificates(); nil != err || 0 != len(linked) {
+		t.Fatalf("Single certificate linked to %s (%v)", locations(linked), err)
+	}
+
+	loaded, err := reg.LoadCertificate(rsaCert.Certificate().Location)
+	if nil != err {
+		t.Fatal(err)
+	}
+	linked, err := loaded.LinkedCertificates()
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "https://ca.example/cert/ec" != locations(linked) {
+		t.Fatalf("Expected rsa certificate linked to ec, got %s", locations(linked))
+	}
+
+	// renewing the group: the old certificates are replaced by the new
+	// ones with the same key spec, the new ones form a group of their own
+	newRsa := testCertificate(t, reg, "https://ca.example/cert/rsa-2")
+	newEc := testCertificate(t, reg, "https://ca.example/cert/ec-2")
+	if err := reg.LinkCertificates([]CertificateModel{newRsa, newEc}); nil != err {
+		t.Fatal(err)
+	}
+	if err := rsaCert.SetReplacedBy(newRsa); nil != err {
+		t.Fatal(err)
+	}
+	if err := ecCert.SetReplacedBy(newEc); nil != err {
+		t.Fatal(err)
+	}
+	if linked, err := newEc.LinkedCertificates(); nil != err || "https://ca.example/cert/rsa-2" != locations(linked) {
+		t.Fatalf("Expected new ec certificate linked to new rsa, got %s (%v)", locations(linked), err)
+	}
+	if linked, err := rsaCert.LinkedCertificates(); nil != err || "https://ca.example/cert/ec" != locations(linked) {
+		t.Fatalf("Old group changed: %s (%v)", locations(linked), err)
+	}
+	for old, replacement := range map[CertificateModel]string{rsaCert: "https://ca.example/cert/rsa-2", ecCert: "https://ca.example/cert/ec-2"} {
+		loaded, err := reg.LoadCertificate(old.Certificate().Location)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if certData := loaded.Certificate(); replacement != certData.ReplacedBy || nil == certData.Replaced {
+			t.Fatalf("Expected %s replaced by %s, got %#v", certData.Location, replacement, certData.ReplacedBy)
+		}
+	}
+
+	// a failed request in the next renewal: the certificates issued before
+	// are kept as a group
+	issued := []CertificateModel{
+		testCertificate(t, reg, "https://ca.example/cert/rsa-3"),
+		testCertificate(t, reg, "https://ca.example/cert/ec-3"),
+	}
+	if err := reg.LinkCertificates(issued); nil != err {
+		t.Fatal(err)
+	}
+	if linked, err := issued[0].LinkedCertificates(); nil != err || "https://ca.example/cert/ec-3" != locations(linked) {
+		t.Fatalf("Certificates issued before the failure not linked: %s (%v)", locations(linked), err)
+	}
+	if linked, err := newRsa.LinkedCertificates(); nil != err || "https://ca.example/cert/ec-2" != locations(linked) {
+		t.Fatalf("Renewed group changed by the failed renewal: %s (%v)", locations(linked), err)
+	}
+}
+
+func TestPurgeReplacedKeys(t *testing.T) {
+	reg := testRegistration(t)
+	certs := make(map[string]CertificateModel)
+	for _, name := range []string{"old", "recent", "current", "nokey"} {
+		certs[name] = testCertificate(t, reg, "https://ca.example/cert/"+name)
+		if "nokey" != name {
+			if err := certs[name].SetPrivateKey(newTestKey(t)); nil != err {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, name := range []string{"old", "recent", "nokey"} {
+		if err := certs[name].SetReplacedBy(certs["current"]); nil != err {
+			t.Fatal(err)
+		}
+	}
+	// replaced two days ago
+	oldData := certs["old"].Certificate()
+	replaced := time.Now().Add(-48 * time.Hour)
+	oldData.Replaced = &replaced
+	if err := certs["old"].(*certificate).scert.SetCertificate(oldData); nil != err {
+		t.Fatal(err)
+	}
+
+	if purged, err := reg.PurgeReplacedKeys(24 * time.Hour); nil != err {
+		t.Fatal(err)
+	} else if 1 != purged {
+		t.Fatalf("Expected 1 purged key, got %d", purged)
+	}
+	for name, hasKey := range map[string]bool{"old": false, "recent": true, "current": true} {
+		loaded, err := reg.LoadCertificate(certs[name].Certificate().Location)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if hasKey != (nil != loaded.Certificate().PrivateKey) {
+			t.Fatalf("%s: expected private key %v", name, hasKey)
+		}
+	}
+
+	// without grace period all replaced keys go
+	if purged, err := reg.PurgeReplacedKeys(0); nil != err {
+		t.Fatal(err)
+	} else if 1 != purged {
+		t.Fatalf("Expected 1 purged key, got %d", purged)
+	}
+	if loaded, err := reg.LoadCertificate(certs["current"].Certificate().Location); nil != err {
+		t.Fatal(err)
+	} else if nil == loaded.Certificate().PrivateKey {
+		t.Fatal("Private key of current certificate purged")
+	}
+}
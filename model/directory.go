@@ -12,6 +12,7 @@ type DirectoryModel interface {
 	Directory() types.Directory
 
 	NewRegistration(name string, signingKey types.SigningKey, contact []string) (RegistrationModel, error)
+	ImportRegistration(registration types.Registration) (RegistrationModel, error)
 }
 
 type directory struct {
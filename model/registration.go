@@ -3,12 +3,15 @@ package model
 import (
 	"encoding/pem"
 	"fmt"
+	"github.com/stbuehler/go-acme-client/offline_bundle"
 	"github.com/stbuehler/go-acme-client/requests"
 	"github.com/stbuehler/go-acme-client/storage_interface"
 	"github.com/stbuehler/go-acme-client/types"
+	"time"
 )
 
 type RegistrationModel interface {
+	Directory() DirectoryModel
 	Registration() types.Registration
 	Refresh() error
 	Update(contact []string, AgreementURL *string) error
@@ -20,8 +23,10 @@ type RegistrationModel interface {
 	FetchAllAuthorizations(updateAll bool) error
 	ImportAuthorizationByURL(authURL string, refresh bool) (AuthorizationModel, error)
 	GetAuthorizationByDNS(dnsIdentifier string, refresh bool) (AuthorizationModel, error)
+	// identifiers containing an "@" are email addresses, all others dns names
 	NewAuthorization(dnsIdentifier string) (AuthorizationModel, error)
 	AuthorizeDNS(dnsIdentifier string) (AuthorizationModel, error)
+	ChallengeAttempts(dnsIdentifier string) ([]storage_interface.ChallengeAttempt, error)
 
 	CertificateInfos() ([]storage_interface.CertificateInfo, error)
 	Certificates() ([]CertificateModel, error)
@@ -29,6 +34,13 @@ type RegistrationModel interface {
 	FetchAllCertificates(updateAll bool) error
 	ImportCertificate(certURL string, refresh bool) (CertificateModel, error)
 	NewCertificate(csr pem.Block) (CertificateModel, error)
+	// store certificates for the same names (but different keys) as group
+	LinkCertificates(certs []CertificateModel) error
+
+	// remove the private keys of certificates replaced longer than grace ago
+	PurgeReplacedKeys(grace time.Duration) (int, error)
+
+	SubmitBundle(bundle *offline_bundle.Bundle, maxNonceAge time.Duration) error
 }
 
 type registration struct {
@@ -36,6 +48,10 @@ type registration struct {
 	sreg storage_interface.StorageRegistration
 }
 
+func (reg *registration) Directory() DirectoryModel {
+	return reg.dir
+}
+
 func (reg *registration) Registration() types.Registration {
 	return *reg.sreg.Registration()
 }
@@ -101,6 +117,25 @@ func (dir *directory) NewRegistration(name string, signingKey types.SigningKey,
 	}
 }
 
+// ImportRegistration stores a registration created elsewhere (usually a
+// copy without private key for offline bundles)
+func (dir *directory) ImportRegistration(regData types.Registration) (RegistrationModel, error) {
+	if reg, err := dir.sdir.Storage().LoadRegistration(regData.Name); nil != err {
+		return nil, err
+	} else if nil != reg {
+		return nil, fmt.Errorf("There already is a registration with name %#v", regData.Name)
+	}
+
+	if sreg, err := dir.sdir.NewRegistration(regData); nil != err || nil == sreg {
+		return nil, err
+	} else {
+		return &registration{
+			dir:  dir,
+			sreg: sreg,
+		}, nil
+	}
+}
+
 func (c *controller) LoadRegistration(name string) (RegistrationModel, error) {
 	if sreg, err := c.storage.LoadRegistration(name); nil != err || nil == sreg {
 		return nil, err
@@ -0,0 +1,346 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/solver_interface"
+	"github.com/stbuehler/go-acme-client/storage_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"sort"
+	"strings"
+	"time"
+)
+
+// challenge types in order of preference when picking a combination; types
+// not listed come last
+var DefaultChallengePreference = []string{
+	types.DNSChallengeIdentifier,
+	types.SimpleHttpIdentifier,
+	types.DVSNIIdentifier,
+	types.EmailReplyIdentifier,
+}
+
+// needing the operator is more expensive than any preference
+const manualCost = 1000
+
+// a challenge response made available by a solver; needs to be cleaned up
+// once the server is done with the challenge
+type PresentedChallenge struct {
+	SolverName string
+	Solver     solver_interface.Solver
+	Response   types.ChallengeResponding
+}
+
+func (presented PresentedChallenge) Cleanup(UI ui.UserInterface) {
+	if err := presented.Solver.Cleanup(UI, presented.Response); nil != err {
+		utils.Errorf("Couldn't clean up challenge response: %s", err)
+	}
+}
+
+// a satisfiable combination of challenges and the solvers to use
+type CombinationPlan struct {
+	// index into Combinations, -1 if the authorization didn't list any (all
+	// challenges are needed then)
+	Combination int
+	Challenges  []int
+	SolverNames []string
+	Cost        int
+}
+
+func (plan CombinationPlan) String() string {
+	var parts []string
+	for ndx, challengeIndex := range plan.Challenges {
+		if 0 == len(plan.SolverNames[ndx]) {
+			parts = append(parts, fmt.Sprintf("%d already valid", challengeIndex))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d via %s", challengeIndex, plan.SolverNames[ndx]))
+		}
+	}
+	return fmt.Sprintf("%v (%s)", plan.Challenges, strings.Join(parts, ", "))
+}
+
+// cheapest first, then fewer challenges
+type plansByCost []CombinationPlan
+
+func (plans plansByCost) Len() int {
+	return len(plans)
+}
+
+func (plans plansByCost) Less(i, j int) bool {
+	if plans[i].Cost != plans[j].Cost {
+		return plans[i].Cost < plans[j].Cost
+	}
+	return len(plans[i].Challenges) < len(plans[j].Challenges)
+}
+
+func (plans plansByCost) Swap(i, j int) {
+	plans[i], plans[j] = plans[j], plans[i]
+}
+
+// authorization still waiting for responses (or validation)
+func authorizationPending(status types.AuthorizationStatus) bool {
+	return "" == status || "processing" == status
+}
+
+func preferenceCost(challengeType string, preference []string) int {
+	for ndx, preferred := range preference {
+		if preferred == challengeType {
+			return ndx
+		}
+	}
+	return len(preference)
+}
+
+// PlanCombinations lists the combinations of the authorization which have
+// a solver for every challenge, cheapest first. A combination costs the sum
+// of the preference positions of its challenge types, plus a penalty for
+// every challenge needing the manual solver.
+func PlanCombinations(authData types.Authorization, choices solver_interface.Choices, preference []string) []CombinationPlan {
+	combinations := authData.Resource.Combinations
+	implicit := 0 == len(combinations)
+	if implicit {
+		all := make([]int, len(authData.Resource.Challenges))
+		for ndx := range all {
+			all[ndx] = ndx
+		}
+		combinations = [][]int{all}
+	}
+
+	var plans []CombinationPlan
+	for combinationIndex, combination := range combinations {
+		plan := CombinationPlan{Combination: combinationIndex, Challenges: combination}
+		if implicit {
+			plan.Combination = -1
+		}
+		satisfiable := 0 != len(combination)
+		for _, challengeIndex := range combination {
+			if challengeIndex < 0 || challengeIndex >= len(authData.Resource.Challenges) {
+				satisfiable = false
+				break
+			}
+			challenge := authData.Resource.Challenges[challengeIndex]
+			challengeType := challenge.GetType()
+			if !types.IsKnownChallengeType(challengeType) {
+				satisfiable = false
+				break
+			}
+			if "valid" == challenge.GetStatus() {
+				// nothing to do
+				plan.SolverNames = append(plan.SolverNames, "")
+				continue
+			}
+			solverName, _, err := choices.Solver(challengeType, authData.Resource.Identifier.Value)
+			if nil != err {
+				satisfiable = false
+				break
+			}
+			plan.SolverNames = append(plan.SolverNames, solverName)
+			plan.Cost += preferenceCost(challengeType, preference)
+			if solver_interface.ManualSolverName == solverName {
+				plan.Cost += manualCost
+			}
+		}
+		if satisfiable {
+			plans = append(plans, plan)
+		}
+	}
+
+	sort.Stable(plansByCost(plans))
+	return plans
+}
+
+func (auth *authorization) Respond(challengeIndex int) (types.ChallengeResponding, error) {
+	authData := auth.Authorization()
+	if challengeIndex < 0 || challengeIndex >= len(authData.Resource.Challenges) {
+		return nil, fmt.Errorf("Not a valid challenge index: %d", challengeIndex)
+	}
+	if chResp, err := authData.Respond(auth.reg.Registration(), challengeIndex); nil != err {
+		return nil, err
+	} else if nil == chResp {
+		return nil, fmt.Errorf("Responding for challenge %d not supported", challengeIndex)
+	} else {
+		return chResp, nil
+	}
+}
+
+func (auth *authorization) SolveChallenge(UI ui.UserInterface, challengeIndex int, choices solver_interface.Choices) (*PresentedChallenge, error) {
+	chResp, err := auth.Respond(challengeIndex)
+	if nil != err {
+		return nil, err
+	}
+	if err = chResp.InitializeResponse(UI); nil != err {
+		return nil, fmt.Errorf("Failed to initialize response: %s", err)
+	}
+
+	challenge := chResp.Challenge()
+	solverName, solver, err := choices.Solver(challenge.GetType(), chResp.DNSIdentifier())
+	if nil != err {
+		return nil, err
+	}
+	utils.Infof("Using solver %s for challenge %d", solverName, challengeIndex)
+	presented := &PresentedChallenge{SolverName: solverName, Solver: solver, Response: chResp}
+
+	attempt := &storage_interface.ChallengeAttempt{
+		DNSIdentifier:         chResp.DNSIdentifier(),
+		AuthorizationLocation: auth.Authorization().Location,
+		ChallengeURI:          challenge.GetURI(),
+		ChallengeType:         challenge.GetType(),
+		Started:               time.Now(),
+		Solver:                solverName,
+	}
+	if payload, err := chResp.SendPayload(); nil == err {
+		if payloadJson, err := json.Marshal(payload); nil == err {
+			attempt.Parameters = string(payloadJson)
+		}
+	}
+	// the response wasn't sent, the attempt is over
+	abandon := func(localResult string, err error) error {
+		presented.Cleanup(UI)
+		now := time.Now()
+		attempt.LocalResult = localResult
+		attempt.Finished = &now
+		if saveErr := auth.sauth.SaveChallengeAttempt(attempt); nil != saveErr {
+			utils.Errorf("Couldn't store challenge attempt: %s", saveErr)
+		}
+		return err
+	}
+
+	if err = solver.Present(UI, chResp); nil != err {
+		return nil, abandon(fmt.Sprintf("present failed: %s", err), fmt.Errorf("Failed to complete challenge: %s", err))
+	}
+	if err = solver.Verify(UI, chResp); nil != err {
+		if saveErr := auth.SaveChallengeData(chResp); nil != saveErr {
+			return nil, abandon(fmt.Sprintf("verify failed: %s", err), fmt.Errorf("Failed to verify challenge: %s (and couldn't store challenge data: %s)", err, saveErr))
+		}
+		return nil, abandon(fmt.Sprintf("verify failed: %s", err), fmt.Errorf("Failed to verify challenge: %s", err))
+	}
+
+	attempt.LocalResult = "ok"
+	if err = auth.sauth.SaveChallengeAttempt(attempt); nil != err {
+		presented.Cleanup(UI)
+		return nil, fmt.Errorf("Couldn't store challenge attempt: %s", err)
+	}
+
+	// update refreshes auth automatically (and records the result in the
+	// attempt once the server is done)
+	if err = auth.UpdateChallenge(chResp); nil != err {
+		attempt.Error = err.Error()
+		return nil, abandon("ok", fmt.Errorf("Failed to update challenge: %s", err))
+	}
+	return presented, nil
+}
+
+func (auth *authorization) WaitForChallenge(uri string, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		authData := auth.Authorization()
+		for _, challenge := range authData.Resource.Challenges {
+			if uri == challenge.GetURI() {
+				switch status := challenge.GetStatus(); status {
+				case "", "pending", "processing":
+				default:
+					return status, nil
+				}
+			}
+		}
+		if !authorizationPending(authData.Resource.Status) {
+			return "", nil
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("Server didn't validate challenge %s within %s", uri, timeout)
+		}
+		time.Sleep(2 * time.Second)
+		if err := auth.Refresh(); nil != err {
+			return "", err
+		}
+	}
+}
+
+func (auth *authorization) SolveCombination(UI ui.UserInterface, plan CombinationPlan, choices solver_interface.Choices, validationWait time.Duration) ([]PresentedChallenge, error) {
+	var presented []PresentedChallenge
+	for ndx, challengeIndex := range plan.Challenges {
+		if 0 == len(plan.SolverNames[ndx]) {
+			// already valid
+			continue
+		}
+		// solve with the solver the plan was made for
+		planChoices := solver_interface.Choices{}
+		for challengeType, name := range choices {
+			planChoices[challengeType] = name
+		}
+		planChoices[auth.Authorization().Resource.Challenges[challengeIndex].GetType()] = plan.SolverNames[ndx]
+
+		p, err := auth.SolveChallenge(UI, challengeIndex, planChoices)
+		if nil != err {
+			return presented, fmt.Errorf("Challenge %d: %s", challengeIndex, err)
+		}
+		presented = append(presented, *p)
+
+		challenge := p.Response.Challenge()
+		status, err := auth.WaitForChallenge(challenge.GetURI(), validationWait)
+		if nil != err {
+			return presented, fmt.Errorf("Challenge %d: %s", challengeIndex, err)
+		} else if "invalid" == status {
+			challenge := auth.Authorization().Resource.Challenges[challengeIndex]
+			msg := fmt.Sprintf("Challenge %d: server failed to validate the response", challengeIndex)
+			for _, line := range challenge.ValidationDetails() {
+				msg += "\n\t" + line
+			}
+			return presented, fmt.Errorf("%s", msg)
+		}
+	}
+	return presented, nil
+}
+
+// whether one of the challenges needs the operator
+func (plan CombinationPlan) NeedsManualSolver() bool {
+	for _, solverName := range plan.SolverNames {
+		if solver_interface.ManualSolverName == solverName {
+			return true
+		}
+	}
+	return false
+}
+
+func (auth *authorization) SolveAutomatically(UI ui.UserInterface, choices solver_interface.Choices, preference []string, validationWait time.Duration) (*CombinationPlan, error) {
+	allPlans := PlanCombinations(auth.Authorization(), choices, preference)
+	if 0 == len(allPlans) {
+		return nil, fmt.Errorf("No combination of challenges can be solved")
+	}
+	// nobody is there to answer prompts
+	var plans []CombinationPlan
+	for _, plan := range allPlans {
+		if !plan.NeedsManualSolver() {
+			plans = append(plans, plan)
+		}
+	}
+	if 0 == len(plans) {
+		return nil, fmt.Errorf("No combination of challenges can be solved without the manual solver")
+	}
+
+	for _, plan := range plans {
+		UI.Messagef("Trying combination %s", plan)
+		presented, err := auth.SolveCombination(UI, plan, choices, validationWait)
+		for _, p := range presented {
+			p.Cleanup(UI)
+		}
+		if nil != err {
+			if !authorizationPending(auth.Authorization().Resource.Status) {
+				// the server rejected a response, other combinations won't help
+				return nil, err
+			}
+			UI.Messagef("Combination %v failed: %s", plan.Challenges, err)
+			continue
+		}
+		if err := auth.Refresh(); nil != err {
+			return nil, err
+		}
+		if status := auth.Authorization().Resource.Status; "valid" != status {
+			return nil, fmt.Errorf("Authorization is %#v after solving combination %v", status, plan.Challenges)
+		}
+		return &plan, nil
+	}
+	return nil, fmt.Errorf("All combinations failed")
+}
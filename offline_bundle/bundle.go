@@ -0,0 +1,136 @@
+package offline_bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/types"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const (
+	// waiting for a signature
+	StatusPrepared = "prepared"
+	// signed, waiting to be submitted (detached signatures stay here)
+	StatusSigned    = "signed"
+	StatusSubmitted = "submitted"
+	StatusFailed    = "failed"
+)
+
+// a request prepared on the online host, signed on the offline host
+type Entry struct {
+	ID int `json:"id"`
+	// empty for detached signatures (challenge validation objects), which
+	// aren't sent by themselves
+	URL       string    `json:"url,omitempty"`
+	Payload   string    `json:"payload"`
+	Nonce     string    `json:"nonce,omitempty"`
+	Prepared  time.Time `json:"prepared"`
+	Signature string    `json:"signature,omitempty"`
+	Status    string    `json:"status"`
+	Result    string    `json:"result,omitempty"`
+}
+
+// the "resource" field of the payload
+func (entry *Entry) Resource() string {
+	var payload struct {
+		Resource string `json:"resource"`
+	}
+	json.Unmarshal([]byte(entry.Payload), &payload)
+	return payload.Resource
+}
+
+func (entry *Entry) Detached() bool {
+	return 0 == len(entry.URL)
+}
+
+// bundle file carrying requests between the online and the offline host;
+// all entries belong to one registration
+type Bundle struct {
+	path          string
+	Registration  string   `json:"registration"`
+	KeyThumbprint string   `json:"keyThumbprint"`
+	Entries       []*Entry `json:"entries"`
+}
+
+func (bundle *Bundle) Path() string {
+	return bundle.path
+}
+
+// Load reads an existing bundle file
+func Load(path string) (*Bundle, error) {
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, err
+	}
+	bundle := &Bundle{path: path}
+	if err := json.Unmarshal(data, bundle); nil != err {
+		return nil, fmt.Errorf("Couldn't parse bundle %s: %s", path, err)
+	}
+	return bundle, nil
+}
+
+// Open loads the bundle file for the registration, or starts a new one
+// if it doesn't exist yet
+func Open(path string, registration types.Registration) (*Bundle, error) {
+	bundle, err := Load(path)
+	if os.IsNotExist(err) {
+		thumbprint, err := registration.SigningKey.Thumbprint()
+		if nil != err {
+			return nil, err
+		}
+		return &Bundle{
+			path:          path,
+			Registration:  registration.Location,
+			KeyThumbprint: thumbprint,
+		}, nil
+	} else if nil != err {
+		return nil, err
+	}
+	if err := bundle.CheckKey(registration.SigningKey); nil != err {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// CheckKey makes sure the bundle was prepared for the key
+func (bundle *Bundle) CheckKey(signingKey types.SigningKey) error {
+	thumbprint, err := signingKey.Thumbprint()
+	if nil != err {
+		return err
+	}
+	if thumbprint != bundle.KeyThumbprint {
+		return fmt.Errorf("Bundle %s was prepared for a different registration key (%s)", bundle.path, bundle.Registration)
+	}
+	return nil
+}
+
+func (bundle *Bundle) Save() error {
+	data, err := json.MarshalIndent(bundle, "", "\t")
+	if nil != err {
+		return err
+	}
+	return ioutil.WriteFile(bundle.path, append(data, '\n'), 0600)
+}
+
+// entries with the given status
+func (bundle *Bundle) WithStatus(status string) []*Entry {
+	var entries []*Entry
+	for _, entry := range bundle.Entries {
+		if status == entry.Status {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (bundle *Bundle) add(entry *Entry) {
+	entry.ID = 1
+	for _, other := range bundle.Entries {
+		if other.ID >= entry.ID {
+			entry.ID = other.ID + 1
+		}
+	}
+	bundle.Entries = append(bundle.Entries, entry)
+}
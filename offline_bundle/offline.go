@@ -0,0 +1,75 @@
+package offline_bundle
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	"strings"
+	"time"
+)
+
+type describedPayload struct {
+	Resource   string           `json:"resource"`
+	Type       string           `json:"type"`
+	Token      string           `json:"token"`
+	Identifier types.Identifier `json:"identifier"`
+	CSR        string           `json:"csr"`
+	Contact    []string         `json:"contact"`
+	Agreement  string           `json:"agreement"`
+}
+
+func describeCSR(csr string) string {
+	der, err := utils.Base64UrlDecode(csr)
+	if nil != err {
+		return fmt.Sprintf("invalid CSR encoding: %s", err)
+	}
+	req, err := x509.ParseCertificateRequest(der)
+	if nil != err {
+		return fmt.Sprintf("invalid CSR: %s", err)
+	}
+	names := append(append([]string{}, req.DNSNames...), req.EmailAddresses...)
+	return fmt.Sprintf("names %s (CN %s)", strings.Join(names, ", "), req.Subject.CommonName)
+}
+
+// Describe summarizes what signing the entry allows the online host to do
+func (entry *Entry) Describe() string {
+	var payload describedPayload
+	if err := json.Unmarshal([]byte(entry.Payload), &payload); nil != err {
+		return fmt.Sprintf("Unparsable payload %s", entry.Payload)
+	}
+	if entry.Detached() {
+		return fmt.Sprintf("Validation object for a %s challenge (token %s): %s", payload.Type, payload.Token, entry.Payload)
+	}
+	var what string
+	switch payload.Resource {
+	case types.Resource_NewAuthorization.String():
+		what = fmt.Sprintf("New authorization for %s %s", payload.Identifier.Type, payload.Identifier.Value)
+	case types.Resource_Challenge.String():
+		what = fmt.Sprintf("Response to %s challenge: %s", payload.Type, entry.Payload)
+	case types.Resource_NewCertificate.String():
+		what = fmt.Sprintf("New certificate for %s", describeCSR(payload.CSR))
+	case types.Resource_NewRegistration.String(), types.Resource_Registration.String():
+		what = fmt.Sprintf("Registration update: contact %v, agreement %#v", payload.Contact, payload.Agreement)
+	default:
+		what = fmt.Sprintf("Unknown request %s", entry.Payload)
+	}
+	return fmt.Sprintf("%s\n\t\tPOST %s (nonce from %s ago)", what, entry.URL, time.Since(entry.Prepared)/time.Second*time.Second)
+}
+
+// Sign signs all prepared entries
+func (bundle *Bundle) Sign(signingKey types.SigningKey) error {
+	if err := bundle.CheckKey(signingKey); nil != err {
+		return err
+	}
+	for _, entry := range bundle.WithStatus(StatusPrepared) {
+		sig, err := signingKey.Sign([]byte(entry.Payload), entry.Nonce)
+		if nil != err {
+			return fmt.Errorf("Couldn't sign entry %d: %s", entry.ID, err)
+		}
+		entry.Signature = sig.FullSerialize()
+		entry.Status = StatusSigned
+	}
+	return nil
+}
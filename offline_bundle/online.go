@@ -0,0 +1,143 @@
+package offline_bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/requests"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	"time"
+)
+
+const problemBadNonce = "urn:acme:error:badNonce"
+
+// the server didn't accept the nonce anymore; the entry needs to be
+// prepared and signed again
+var StaleNonce = errors.New("Nonce expired")
+
+// returned instead of a response for requests added to the bundle
+type QueuedError struct {
+	Bundle *Bundle
+	Entry  *Entry
+}
+
+func (err *QueuedError) Error() string {
+	return fmt.Sprintf("Queued as entry %d in %s; sign it with sign-bundle and run submit-bundle", err.Entry.ID, err.Bundle.path)
+}
+
+// Activate routes everything which needs the (offline) private key into
+// the bundle
+func Activate(bundle *Bundle) {
+	requests.OfflineQueue = bundle.queue
+	types.DetachedSigner = bundle.detached
+}
+
+func (bundle *Bundle) find(url string, payload []byte) *Entry {
+	for _, entry := range bundle.Entries {
+		if url == entry.URL && string(payload) == entry.Payload && StatusSubmitted != entry.Status && StatusFailed != entry.Status {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (bundle *Bundle) queue(signingKey types.SigningKey, req *utils.HttpRequest, payloadJson []byte) (*utils.HttpResponse, error) {
+	if entry := bundle.find(req.URL, payloadJson); nil != entry {
+		return nil, &QueuedError{Bundle: bundle, Entry: entry}
+	}
+	nonce, err := requests.FetchNonce(req.URL)
+	if nil != err {
+		return nil, err
+	}
+	entry := &Entry{
+		URL:      req.URL,
+		Payload:  string(payloadJson),
+		Nonce:    nonce,
+		Prepared: time.Now(),
+		Status:   StatusPrepared,
+	}
+	bundle.add(entry)
+	if err := bundle.Save(); nil != err {
+		return nil, err
+	}
+	utils.Infof("Added request to %s as entry %d", req.URL, entry.ID)
+	return nil, &QueuedError{Bundle: bundle, Entry: entry}
+}
+
+// detached signatures are used once the offline host signed them
+func (bundle *Bundle) detached(signingKey types.SigningKey, payload []byte) (*types.JsonWebSignature, error) {
+	if entry := bundle.find("", payload); nil != entry {
+		if StatusSigned != entry.Status {
+			return nil, &QueuedError{Bundle: bundle, Entry: entry}
+		}
+		var signedPayload []byte
+		if err := signingKey.Verify(entry.Signature, &signedPayload, nil); nil != err {
+			return nil, fmt.Errorf("Invalid signature in bundle entry %d: %s", entry.ID, err)
+		} else if string(signedPayload) != entry.Payload {
+			return nil, fmt.Errorf("Bundle entry %d signed a different payload", entry.ID)
+		}
+		return types.ParseJWS(entry.Signature)
+	}
+	entry := &Entry{
+		Payload:  string(payload),
+		Prepared: time.Now(),
+		Status:   StatusPrepared,
+	}
+	bundle.add(entry)
+	if err := bundle.Save(); nil != err {
+		return nil, err
+	}
+	utils.Infof("Added detached signature as entry %d", entry.ID)
+	return nil, &QueuedError{Bundle: bundle, Entry: entry}
+}
+
+// Reprepare fetches a fresh nonce for the entry; the old signature is
+// dropped
+func (bundle *Bundle) Reprepare(entry *Entry) error {
+	if entry.Detached() {
+		return nil
+	}
+	nonce, err := requests.FetchNonce(entry.URL)
+	if nil != err {
+		return err
+	}
+	entry.Nonce = nonce
+	entry.Prepared = time.Now()
+	entry.Signature = ""
+	entry.Status = StatusPrepared
+	entry.Result = ""
+	return nil
+}
+
+// Submit sends the signed request of the entry; returns StaleNonce if the
+// server rejected the nonce, and the response for all other HTTP errors
+func (bundle *Bundle) Submit(entry *Entry) (*utils.HttpResponse, error) {
+	if entry.Detached() || StatusSigned != entry.Status {
+		return nil, fmt.Errorf("Bundle entry %d is not a signed request", entry.ID)
+	}
+	req := utils.HttpRequest{
+		Method: "POST",
+		URL:    entry.URL,
+		Body:   []byte(entry.Signature),
+		Headers: utils.HttpRequestHeader{
+			ContentType: "application/json",
+		},
+	}
+	if types.Resource_NewCertificate.String() == entry.Resource() {
+		req.Headers.Accept = "application/pkix-cert"
+	}
+	utils.Debugf("sending to %s signed payload from bundle: %s\n", entry.URL, entry.Payload)
+	resp, err := req.Run()
+	if nil == resp {
+		return nil, err
+	}
+	// HTTP errors are left to the caller (apart from stale nonces)
+	if 400 == resp.StatusCode {
+		var problem types.Problem
+		if err := json.Unmarshal(resp.Body, &problem); nil == err && problemBadNonce == problem.Type {
+			return nil, StaleNonce
+		}
+	}
+	return resp, nil
+}
@@ -7,19 +7,32 @@ import (
 	"net/http"
 )
 
-func RunSignedRequest(signingKey types.SigningKey, req *utils.HttpRequest, payloadJson []byte) (*utils.HttpResponse, error) {
-	var nonce string
-	{
-		nonceResp, err := http.Head(req.URL)
-		if nil != err {
-			return nil, err
-		}
-		defer nonceResp.Body.Close()
-
-		nonce = nonceResp.Header.Get("Replay-Nonce")
+// requests for keys kept offline are handed to OfflineQueue instead of
+// being signed and sent; set by the offline bundle workflow
+var OfflineQueue func(signingKey types.SigningKey, req *utils.HttpRequest, payloadJson []byte) (*utils.HttpResponse, error)
+
+func FetchNonce(url string) (string, error) {
+	nonceResp, err := http.Head(url)
+	if nil != err {
+		return "", err
 	}
+	defer nonceResp.Body.Close()
+
+	nonce := nonceResp.Header.Get("Replay-Nonce")
 	if 0 == len(nonce) {
-		return nil, fmt.Errorf("Didn't get a Replay-Nonce header")
+		return "", fmt.Errorf("Didn't get a Replay-Nonce header")
+	}
+	return nonce, nil
+}
+
+func RunSignedRequest(signingKey types.SigningKey, req *utils.HttpRequest, payloadJson []byte) (*utils.HttpResponse, error) {
+	if !signingKey.CanSign() && nil != OfflineQueue {
+		return OfflineQueue(signingKey, req, payloadJson)
+	}
+
+	nonce, err := FetchNonce(req.URL)
+	if nil != err {
+		return nil, err
 	}
 
 	sig, err := signingKey.Sign(payloadJson, nonce)
@@ -8,13 +8,13 @@ import (
 )
 
 type newAuthorization struct {
-	Resource      types.ResourceNewAuthorizationTag `json:"resource"`
-	DNSIdentifier types.DNSIdentifier               `json:"identifier,omitempty"`
+	Resource   types.ResourceNewAuthorizationTag `json:"resource"`
+	Identifier types.Identifier                  `json:"identifier,omitempty"`
 }
 
-func NewDNSAuthorization(directory *types.Directory, signingKey types.SigningKey, domain string) (*types.Authorization, error) {
+func NewAuthorization(directory *types.Directory, signingKey types.SigningKey, identifier types.Identifier) (*types.Authorization, error) {
 	payload := newAuthorization{
-		DNSIdentifier: types.DNSIdentifier(domain),
+		Identifier: identifier,
 	}
 
 	payloadJson, err := json.Marshal(payload)
@@ -36,9 +36,18 @@ func NewDNSAuthorization(directory *types.Directory, signingKey types.SigningKey
 		return nil, fmt.Errorf("POST authorization %s to %s failed: %s", string(payloadJson), url, err)
 	}
 
+	auth, err := AuthorizationFromResponse(resp)
+	if nil != err {
+		return nil, fmt.Errorf("POST %s to %s failed: %s", string(payloadJson), url, err)
+	}
+	return auth, nil
+}
+
+// AuthorizationFromResponse decodes the response to a new-authz request
+func AuthorizationFromResponse(resp *utils.HttpResponse) (*types.Authorization, error) {
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		utils.DebugLogHttpResponse(resp)
-		return nil, fmt.Errorf("POST %s to %s failed: %s", string(payloadJson), url, resp.Status)
+		return nil, fmt.Errorf("%s", resp.Status)
 	}
 
 	if 0 == len(resp.Location) {
@@ -46,9 +55,8 @@ func NewDNSAuthorization(directory *types.Directory, signingKey types.SigningKey
 	}
 
 	var response types.Authorization
-	err = json.Unmarshal(resp.Body, &response.Resource)
-	if nil != err {
-		return nil, fmt.Errorf("Failed decoding response from POST %s to %s: %s", string(payloadJson), url, err)
+	if err := json.Unmarshal(resp.Body, &response.Resource); nil != err {
+		return nil, fmt.Errorf("Failed decoding response: %s", err)
 	}
 	response.Location = resp.Location
 
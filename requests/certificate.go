@@ -37,8 +37,17 @@ func NewCertificate(directory *types.Directory, signingKey types.SigningKey, csr
 		return nil, fmt.Errorf("POST certificate request %s to %s failed: %s", string(payloadJson), url, err)
 	}
 
+	cert, err := CertificateFromResponse(resp)
+	if nil != err {
+		return nil, fmt.Errorf("POST certificate request %s to %s failed: %s", string(payloadJson), url, err)
+	}
+	return cert, nil
+}
+
+// CertificateFromResponse decodes the response to a new-cert request
+func CertificateFromResponse(resp *utils.HttpResponse) (*types.Certificate, error) {
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("POST certificate request %s to %s failed: %s", string(payloadJson), url, resp.Status)
+		return nil, fmt.Errorf("%s", resp.Status)
 	}
 
 	if 0 == len(resp.Location) {
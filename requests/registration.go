@@ -32,16 +32,24 @@ func sendRegistration(url string, signingKey types.SigningKey, payload interface
 		return nil, fmt.Errorf("POSTing registration %s to %s failed: %s", string(payloadJson), url, err)
 	}
 
+	reg, err := RegistrationFromResponse(resp, url, signingKey, old)
+	if nil != err {
+		return nil, fmt.Errorf("POST %s to %s failed: %s", string(payloadJson), url, err)
+	}
+	return reg, nil
+}
+
+// RegistrationFromResponse decodes the response to a registration request
+// sent to url; fields not sent by the server are taken from old
+func RegistrationFromResponse(resp *utils.HttpResponse, url string, signingKey types.SigningKey, old *types.Registration) (*types.Registration, error) {
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("POST %s to %s failed: %s", string(payloadJson), url, resp.Status)
+		return nil, fmt.Errorf("%s", resp.Status)
 	}
 
 	var registration types.Registration
-	err = json.Unmarshal(resp.Body, &registration.Resource)
-	if nil != err {
-		return nil, fmt.Errorf("Failed decoding response from POST %s to %s: %s", string(payloadJson), url, err)
+	if err := json.Unmarshal(resp.Body, &registration.Resource); nil != err {
+		return nil, fmt.Errorf("Failed decoding response: %s", err)
 	}
-
 	registration.SigningKey = signingKey
 	if 0 == len(resp.Location) || old.Location == url {
 		registration.Location = old.Location
@@ -0,0 +1,192 @@
+package solver_dns
+
+import (
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"strings"
+	"time"
+)
+
+const maxCNAMEs = 8
+const queryTimeout = 5 * time.Second
+
+// recursive resolver used to find the authoritative nameservers; empty for
+// the first nameserver from /etc/resolv.conf
+var resolverAddress string
+
+// nameservers responsible for a record
+type authority struct {
+	// record name after following CNAMEs
+	name    string
+	zone    string
+	servers []string
+}
+
+func resolver() (string, error) {
+	if 0 != len(resolverAddress) {
+		return withDefaultPort(resolverAddress), nil
+	}
+	config, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if nil != err {
+		return "", fmt.Errorf("Couldn't read resolver configuration: %s", err)
+	}
+	if 0 == len(config.Servers) {
+		return "", fmt.Errorf("No nameserver in resolver configuration")
+	}
+	return net.JoinHostPort(config.Servers[0], config.Port), nil
+}
+
+func withDefaultPort(server string) string {
+	if _, _, err := net.SplitHostPort(server); nil != err {
+		return net.JoinHostPort(server, "53")
+	}
+	return server
+}
+
+func query(server string, name string, qtype uint16, recursive bool) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	msg.RecursionDesired = recursive
+	client := &dns.Client{
+		Timeout: queryTimeout,
+	}
+	resp, _, err := client.Exchange(msg, server)
+	if nil == err && resp.Truncated {
+		client.Net = "tcp"
+		resp, _, err = client.Exchange(msg, server)
+	}
+	if nil != err {
+		return nil, fmt.Errorf("DNS query for %s %s to %s failed: %s", name, dns.TypeToString[qtype], server, err)
+	}
+	return resp, nil
+}
+
+// lookupAuthority follows CNAMEs starting at name and finds the zone (and
+// its nameserver addresses) containing the final name
+func lookupAuthority(name string) (*authority, error) {
+	recursor, err := resolver()
+	if nil != err {
+		return nil, err
+	}
+	target, err := followCNAMEs(recursor, name)
+	if nil != err {
+		return nil, err
+	}
+	zone, hosts, err := findZoneCut(recursor, target)
+	if nil != err {
+		return nil, err
+	}
+	auth := &authority{name: target, zone: zone}
+	for _, host := range hosts {
+		if addresses, err := lookupAddresses(recursor, host); nil != err {
+			return nil, err
+		} else if 0 == len(addresses) {
+			return nil, fmt.Errorf("Nameserver %s of zone %s has no address", host, zone)
+		} else {
+			auth.servers = append(auth.servers, addresses...)
+		}
+	}
+	return auth, nil
+}
+
+func followCNAMEs(recursor string, name string) (string, error) {
+	for i := 0; i < maxCNAMEs; i++ {
+		resp, err := query(recursor, name, dns.TypeCNAME, true)
+		if nil != err {
+			return "", err
+		}
+		target := ""
+		if dns.RcodeSuccess == resp.Rcode {
+			for _, rr := range resp.Answer {
+				if cname, ok := rr.(*dns.CNAME); ok && strings.EqualFold(cname.Hdr.Name, name) {
+					target = cname.Target
+					break
+				}
+			}
+		}
+		if 0 == len(target) {
+			return name, nil
+		}
+		name = target
+	}
+	return "", fmt.Errorf("Too many CNAMEs for %s", name)
+}
+
+// the closest enclosing name having NS records
+func findZoneCut(recursor string, name string) (string, []string, error) {
+	for candidate := name; ; {
+		resp, err := query(recursor, candidate, dns.TypeNS, true)
+		if nil != err {
+			return "", nil, err
+		}
+		var hosts []string
+		if dns.RcodeSuccess == resp.Rcode {
+			for _, rr := range resp.Answer {
+				if ns, ok := rr.(*dns.NS); ok && strings.EqualFold(ns.Hdr.Name, candidate) {
+					hosts = append(hosts, ns.Ns)
+				}
+			}
+		}
+		if 0 != len(hosts) {
+			return candidate, hosts, nil
+		}
+
+		ndx, end := dns.NextLabel(candidate, 0)
+		if end {
+			return "", nil, fmt.Errorf("Couldn't find authoritative nameservers for %s", name)
+		}
+		candidate = candidate[ndx:]
+	}
+}
+
+// host:port for all IPv4 and IPv6 addresses of host
+func lookupAddresses(recursor string, host string) ([]string, error) {
+	var addresses []string
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		resp, err := query(recursor, host, qtype, true)
+		if nil != err {
+			return nil, err
+		}
+		for _, rr := range resp.Answer {
+			switch rr := rr.(type) {
+			case *dns.A:
+				addresses = append(addresses, net.JoinHostPort(rr.A.String(), "53"))
+			case *dns.AAAA:
+				addresses = append(addresses, net.JoinHostPort(rr.AAAA.String(), "53"))
+			}
+		}
+	}
+	return addresses, nil
+}
+
+// checkAuthoritative asks every authoritative nameserver directly for the
+// record; fails unless all of them have it
+func checkAuthoritative(rec record) error {
+	auth, err := lookupAuthority(rec.name)
+	if nil != err {
+		return err
+	}
+	for _, server := range auth.servers {
+		if err := checkNameserver(server, auth.name, rec.value); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkNameserver(nameserver string, name string, value string) error {
+	resp, err := query(nameserver, name, dns.TypeTXT, false)
+	if nil != err {
+		return err
+	}
+	if dns.RcodeSuccess != resp.Rcode {
+		return fmt.Errorf("%s answered %s for %s", nameserver, dns.RcodeToString[resp.Rcode], name)
+	}
+	for _, rr := range resp.Answer {
+		if txt, ok := rr.(*dns.TXT); ok && strings.EqualFold(txt.Hdr.Name, name) && strings.Join(txt.Txt, "") == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s doesn't have the TXT record %s yet", nameserver, name)
+}
@@ -0,0 +1,66 @@
+package solver_dns
+
+import (
+	"fmt"
+	"github.com/miekg/dns"
+	"github.com/stbuehler/go-acme-client/types"
+	"sort"
+	"strings"
+)
+
+// maps domain names to the (fully qualified) name the TXT record of the
+// domain's challenge is delegated to
+type Delegations map[string]string
+
+// explicit delegations from the command line; without an entry CNAMEs of
+// the record name are followed
+var delegations Delegations
+
+func (delegations *Delegations) String() string {
+	var entries []string
+	for domain, target := range *delegations {
+		entries = append(entries, domain+"="+target)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// accepts "DOMAIN=NAME"
+func (delegations *Delegations) Set(v string) error {
+	ndx := strings.Index(v, "=")
+	if ndx <= 0 || ndx == len(v)-1 {
+		return fmt.Errorf("Expected DOMAIN=NAME, got %#v", v)
+	}
+	if nil == *delegations {
+		*delegations = make(Delegations)
+	}
+	(*delegations)[strings.TrimSuffix(v[:ndx], ".")] = dns.Fqdn(v[ndx+1:])
+	return nil
+}
+
+// delegation targets by domain, shared by all dns solvers (Configured is
+// asked for every domain by each of them); failed lookups aren't cached
+var delegationTargets = make(map[string]string)
+
+// delegationTarget returns the name the TXT record for domain has to be
+// placed at: the explicit delegation, or the end of the CNAME chain starting
+// at the record name
+func delegationTarget(domain string) (string, error) {
+	domain = strings.TrimSuffix(domain, ".")
+	if target, ok := delegations[domain]; ok {
+		return target, nil
+	}
+	if target, ok := delegationTargets[domain]; ok {
+		return target, nil
+	}
+	recursor, err := resolver()
+	if nil != err {
+		return "", err
+	}
+	target, err := followCNAMEs(recursor, types.DNSChallengeRecordName(domain))
+	if nil != err {
+		return "", err
+	}
+	delegationTargets[domain] = target
+	return target, nil
+}
@@ -0,0 +1,42 @@
+package solver_dns
+
+import (
+	"flag"
+)
+
+// A Provider manages TXT records for dns challenges.
+type Provider interface {
+	// whether the provider can manage records for the domain
+	Configured(domain string) bool
+
+	// name is fully qualified (with trailing dot)
+	AddTXT(name string, value string) error
+	RemoveTXT(name string, value string) error
+}
+
+// providers which need configuration from the command line
+type FlagProvider interface {
+	Provider
+
+	AddFlags(flags *flag.FlagSet)
+}
+
+// providers updating a (primary) nameserver which can be asked directly
+// whether a record was added
+type NameserverProvider interface {
+	Provider
+
+	// host:port
+	Nameserver() string
+}
+
+// SplitTXT splits a record value into the strings of a TXT record, as each
+// string is limited to 255 bytes (and validations are often longer)
+func SplitTXT(value string) []string {
+	var parts []string
+	for len(value) > 255 {
+		parts = append(parts, value[:255])
+		value = value[255:]
+	}
+	return append(parts, value)
+}
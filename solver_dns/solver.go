@@ -0,0 +1,150 @@
+package solver_dns
+
+import (
+	"flag"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/solver_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"strings"
+	"time"
+)
+
+var propagationTimeout time.Duration
+var propagationInterval time.Duration
+
+type record struct {
+	name  string
+	value string
+}
+
+// presents dns challenges through a Provider
+type dnsSolver struct {
+	provider Provider
+	// maps challenge uri to added record
+	added map[string]record
+}
+
+// NewSolver wraps a Provider as solver for dns challenges; Verify waits
+// until the record is visible.
+func NewSolver(provider Provider) solver_interface.Solver {
+	return &dnsSolver{
+		provider: provider,
+		added:    make(map[string]record),
+	}
+}
+
+func (solver *dnsSolver) AddFlags(flags *flag.FlagSet) {
+	// shared by all dns solvers
+	if nil == flags.Lookup("dns-propagation-timeout") {
+		flags.DurationVar(&propagationTimeout, "dns-propagation-timeout", 2*time.Minute, "How long to wait for a TXT record to become visible")
+		flags.DurationVar(&propagationInterval, "dns-propagation-interval", 5*time.Second, "Delay between checks whether a TXT record is visible")
+		flags.Var(&delegations, "dns-delegate", "Place the TXT record for DOMAIN at NAME instead of _acme-challenge.DOMAIN, given as DOMAIN=NAME (default: follow CNAMEs); can be used multiple times")
+		flags.StringVar(&resolverAddress, "dns-resolver", "", "Recursive nameserver (HOST or HOST:PORT) used to find the authoritative nameservers (default: first nameserver in /etc/resolv.conf)")
+	}
+	if flagProvider, ok := solver.provider.(FlagProvider); ok {
+		flagProvider.AddFlags(flags)
+	}
+}
+
+// domains the delegation check failed for (only reported once)
+var delegationUnknown = make(map[string]bool)
+
+// the provider must be able to manage the delegation target (if the record
+// is delegated)
+func (solver *dnsSolver) Configured(domain string) bool {
+	target, err := delegationTarget(domain)
+	if nil != err {
+		if !delegationUnknown[domain] {
+			delegationUnknown[domain] = true
+			utils.Warningf("Couldn't check delegation of %s, assuming the TXT record isn't delegated: %s", domain, err)
+		}
+		return solver.provider.Configured(domain)
+	}
+	return solver.provider.Configured(strings.TrimSuffix(target, "."))
+}
+
+func (solver *dnsSolver) Present(UI ui.UserInterface, response types.ChallengeResponding) error {
+	dnsResp, ok := response.(types.ChallengeDNSResponding)
+	if !ok {
+		return fmt.Errorf("Challenge response %T isn't a DNS challenge", response)
+	}
+	value, err := dnsResp.RecordValue()
+	if nil != err {
+		return err
+	}
+
+	// replace record from an earlier attempt
+	if err := solver.Cleanup(UI, response); nil != err {
+		return err
+	}
+
+	name := dnsResp.RecordName()
+	target, err := delegationTarget(response.DNSIdentifier())
+	if nil != err {
+		return fmt.Errorf("Couldn't check delegation of %s: %s", name, err)
+	}
+	if target != name {
+		UI.Messagef("TXT record %s is delegated to %s", name, target)
+	}
+
+	rec := record{name: target, value: value}
+	if err := solver.provider.AddTXT(rec.name, rec.value); nil != err {
+		return fmt.Errorf("Couldn't add TXT record %s: %s", rec.name, err)
+	}
+	challenge := response.Challenge()
+	solver.added[challenge.GetURI()] = rec
+	UI.Messagef("Added TXT record %s %#v", rec.name, rec.value)
+	return nil
+}
+
+// waits until the primary nameserver (if the provider has one) and all
+// authoritative nameservers serve the record
+func (solver *dnsSolver) Verify(UI ui.UserInterface, response types.ChallengeResponding) error {
+	challenge := response.Challenge()
+	rec, ok := solver.added[challenge.GetURI()]
+	if !ok {
+		return response.Verify()
+	}
+	nsProvider, hasNameserver := solver.provider.(NameserverProvider)
+	UI.Messagef("Waiting for TXT record %s on the authoritative nameservers", rec.name)
+	return poll(rec, func() error {
+		if hasNameserver {
+			if err := checkNameserver(nsProvider.Nameserver(), rec.name, rec.value); nil != err {
+				return err
+			}
+		}
+		return checkAuthoritative(rec)
+	})
+}
+
+func (solver *dnsSolver) Cleanup(UI ui.UserInterface, response types.ChallengeResponding) error {
+	challenge := response.Challenge()
+	rec, ok := solver.added[challenge.GetURI()]
+	if !ok {
+		return nil
+	}
+	delete(solver.added, challenge.GetURI())
+	if err := solver.provider.RemoveTXT(rec.name, rec.value); nil != err {
+		return fmt.Errorf("Couldn't remove TXT record %s: %s", rec.name, err)
+	}
+	utils.Infof("Removed TXT record %s %#v", rec.name, rec.value)
+	return nil
+}
+
+// repeat check until it succeeds or the propagation timeout is reached
+func poll(rec record, check func() error) error {
+	deadline := time.Now().Add(propagationTimeout)
+	for {
+		err := check()
+		if nil == err {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("TXT record %s not visible within %s: %s", rec.name, propagationTimeout, err)
+		}
+		utils.Debugf("TXT record %s not visible yet: %s", rec.name, err)
+		time.Sleep(propagationInterval)
+	}
+}
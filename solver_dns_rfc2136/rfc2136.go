@@ -0,0 +1,174 @@
+package solver_dns_rfc2136
+
+import (
+	"flag"
+	"fmt"
+	"github.com/miekg/dns"
+	"github.com/stbuehler/go-acme-client/solver_dns"
+	"github.com/stbuehler/go-acme-client/solver_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+)
+
+const SolverName = "rfc2136"
+
+// adds and removes TXT records with dynamic updates (RFC 2136), optionally
+// authenticated with TSIG (RFC 2845)
+type rfc2136Provider struct {
+	server         string
+	zone           string
+	ttl            uint
+	timeout        time.Duration
+	tsigName       string
+	tsigSecret     string
+	tsigSecretFile string
+	tsigAlgorithm  string
+}
+
+var Provider = &rfc2136Provider{}
+
+func init() {
+	solver_interface.Register(types.DNSChallengeIdentifier, SolverName, solver_dns.NewSolver(Provider))
+}
+
+func (provider *rfc2136Provider) AddFlags(flags *flag.FlagSet) {
+	flags.StringVar(&provider.server, "rfc2136-server", "", "Primary nameserver (HOST or HOST:PORT) accepting dynamic updates")
+	flags.StringVar(&provider.zone, "rfc2136-zone", "", "Zone to update (default: find the zone by asking the nameserver for the SOA)")
+	flags.UintVar(&provider.ttl, "rfc2136-ttl", 60, "TTL of added TXT records")
+	flags.DurationVar(&provider.timeout, "rfc2136-timeout", 10*time.Second, "Timeout for DNS requests to the nameserver")
+	flags.StringVar(&provider.tsigName, "rfc2136-tsig-name", "", "TSIG key name (empty for unsigned updates)")
+	flags.StringVar(&provider.tsigSecret, "rfc2136-tsig-secret", "", "TSIG secret (base64)")
+	flags.StringVar(&provider.tsigSecretFile, "rfc2136-tsig-secret-file", "", "File containing the TSIG secret (base64)")
+	flags.StringVar(&provider.tsigAlgorithm, "rfc2136-tsig-algorithm", "hmac-sha256", "TSIG algorithm, one of hmac-sha1, hmac-sha224, hmac-sha256, hmac-sha384, hmac-sha512")
+}
+
+func (provider *rfc2136Provider) Configured(domain string) bool {
+	if 0 == len(provider.server) {
+		return false
+	}
+	if 0 != len(provider.zone) {
+		return dns.IsSubDomain(dns.Fqdn(provider.zone), dns.Fqdn(domain))
+	}
+	return true
+}
+
+func (provider *rfc2136Provider) Nameserver() string {
+	if _, _, err := net.SplitHostPort(provider.server); nil != err {
+		return net.JoinHostPort(provider.server, "53")
+	}
+	return provider.server
+}
+
+func (provider *rfc2136Provider) AddTXT(name string, value string) error {
+	return provider.update(name, value, true)
+}
+
+func (provider *rfc2136Provider) RemoveTXT(name string, value string) error {
+	return provider.update(name, value, false)
+}
+
+func (provider *rfc2136Provider) update(name string, value string, add bool) error {
+	zone, err := provider.findZone(name)
+	if nil != err {
+		return err
+	}
+
+	rr := &dns.TXT{
+		Hdr: dns.RR_Header{
+			Name:   name,
+			Rrtype: dns.TypeTXT,
+			Class:  dns.ClassINET,
+			Ttl:    uint32(provider.ttl),
+		},
+		Txt: solver_dns.SplitTXT(value),
+	}
+	msg := new(dns.Msg)
+	msg.SetUpdate(zone)
+	if add {
+		msg.Insert([]dns.RR{rr})
+	} else {
+		msg.Remove([]dns.RR{rr})
+	}
+
+	resp, err := provider.exchange(msg, true)
+	if nil != err {
+		return err
+	}
+	if dns.RcodeSuccess != resp.Rcode {
+		return fmt.Errorf("Update of zone %s rejected by %s: %s", zone, provider.Nameserver(), dns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
+
+// the configured zone, or the zone the nameserver returns a SOA for
+func (provider *rfc2136Provider) findZone(name string) (string, error) {
+	if 0 != len(provider.zone) {
+		return dns.Fqdn(provider.zone), nil
+	}
+
+	for candidate := name; ; {
+		msg := new(dns.Msg)
+		msg.SetQuestion(candidate, dns.TypeSOA)
+		msg.RecursionDesired = false
+		resp, err := provider.exchange(msg, false)
+		if nil != err {
+			return "", err
+		}
+		if dns.RcodeSuccess == resp.Rcode || dns.RcodeNameError == resp.Rcode {
+			// SOA either as answer or in the authority section
+			for _, rr := range append(resp.Answer, resp.Ns...) {
+				if soa, ok := rr.(*dns.SOA); ok && dns.IsSubDomain(soa.Hdr.Name, name) {
+					return soa.Hdr.Name, nil
+				}
+			}
+		}
+
+		ndx, end := dns.NextLabel(candidate, 0)
+		if end {
+			return "", fmt.Errorf("Couldn't find zone for %s on %s", name, provider.Nameserver())
+		}
+		candidate = candidate[ndx:]
+	}
+}
+
+func (provider *rfc2136Provider) exchange(msg *dns.Msg, sign bool) (*dns.Msg, error) {
+	client := &dns.Client{
+		Timeout: provider.timeout,
+	}
+	if sign && 0 != len(provider.tsigName) {
+		secret, err := provider.secret()
+		if nil != err {
+			return nil, err
+		}
+		keyName := dns.Fqdn(provider.tsigName)
+		client.TsigSecret = map[string]string{keyName: secret}
+		msg.SetTsig(keyName, dns.Fqdn(provider.tsigAlgorithm), 300, time.Now().Unix())
+	}
+
+	resp, _, err := client.Exchange(msg, provider.Nameserver())
+	if nil == err && resp.Truncated {
+		client.Net = "tcp"
+		resp, _, err = client.Exchange(msg, provider.Nameserver())
+	}
+	if nil != err {
+		return nil, fmt.Errorf("DNS request to %s failed: %s", provider.Nameserver(), err)
+	}
+	return resp, nil
+}
+
+func (provider *rfc2136Provider) secret() (string, error) {
+	if 0 != len(provider.tsigSecretFile) {
+		if data, err := ioutil.ReadFile(provider.tsigSecretFile); nil != err {
+			return "", err
+		} else {
+			return strings.TrimSpace(string(data)), nil
+		}
+	}
+	if 0 == len(provider.tsigSecret) {
+		return "", fmt.Errorf("Missing TSIG secret for key %s", provider.tsigName)
+	}
+	return provider.tsigSecret, nil
+}
@@ -0,0 +1,227 @@
+package solver_dns_rfc2136
+
+import (
+	"github.com/miekg/dns"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testZone    = "example.com."
+	testName    = "_acme-challenge.www.example.com."
+	testKeyName = "acme-update."
+	// base64 of "0123456789abcdef0123456789abcdef"
+	testSecret = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+)
+
+// primary nameserver for testZone accepting updates signed with testKeyName
+type testNameserver struct {
+	// don't add the SOA to the authority section of negative answers
+	minimal bool
+
+	mutex      sync.Mutex
+	soaQueries []string
+	updates    []*dns.Msg
+}
+
+func (ns *testNameserver) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	msg := new(dns.Msg)
+	msg.SetReply(req)
+
+	ns.mutex.Lock()
+	defer ns.mutex.Unlock()
+	if dns.OpcodeUpdate == req.Opcode {
+		if nil == req.IsTsig() || nil != w.TsigStatus() {
+			// like BIND: unsigned NOTAUTH reply for bad signatures
+			msg.Rcode = dns.RcodeNotAuth
+		} else if 1 != len(req.Question) || testZone != req.Question[0].Name {
+			msg.Rcode = dns.RcodeNotZone
+		} else {
+			ns.updates = append(ns.updates, req)
+			tsig := req.IsTsig()
+			msg.SetTsig(tsig.Hdr.Name, tsig.Algorithm, 300, time.Now().Unix())
+		}
+		w.WriteMsg(msg)
+		return
+	}
+
+	question := req.Question[0]
+	ns.soaQueries = append(ns.soaQueries, question.Name)
+	soa := &dns.SOA{
+		Hdr:     dns.RR_Header{Name: testZone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 300},
+		Ns:      "ns." + testZone,
+		Mbox:    "hostmaster." + testZone,
+		Serial:  1,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  300,
+	}
+	msg.Authoritative = true
+	if !dns.IsSubDomain(testZone, question.Name) {
+		msg.Authoritative = false
+		msg.Rcode = dns.RcodeRefused
+	} else if testZone == strings.ToLower(question.Name) {
+		msg.Answer = append(msg.Answer, soa)
+	} else {
+		if "www."+testZone != strings.ToLower(question.Name) {
+			msg.Rcode = dns.RcodeNameError
+		}
+		if !ns.minimal {
+			msg.Ns = append(msg.Ns, soa)
+		}
+	}
+	w.WriteMsg(msg)
+}
+
+func (ns *testNameserver) reset() ([]string, []*dns.Msg) {
+	ns.mutex.Lock()
+	defer ns.mutex.Unlock()
+	soaQueries, updates := ns.soaQueries, ns.updates
+	ns.soaQueries, ns.updates = nil, nil
+	return soaQueries, updates
+}
+
+func startNameserver(t *testing.T) (*testNameserver, string) {
+	ns := &testNameserver{}
+	started := make(chan error, 1)
+	server := &dns.Server{
+		Addr:              "127.0.0.1:0",
+		Net:               "udp",
+		Handler:           ns,
+		TsigSecret:        map[string]string{testKeyName: testSecret},
+		NotifyStartedFunc: func() { started <- nil },
+		// the default refuses UPDATE messages
+		MsgAcceptFunc: func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+	}
+	go func() {
+		if err := server.ListenAndServe(); nil != err {
+			started <- err
+		}
+	}()
+	if err := <-started; nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Shutdown() })
+	return ns, server.PacketConn.LocalAddr().String()
+}
+
+func testProvider(address string) *rfc2136Provider {
+	return &rfc2136Provider{
+		server:        address,
+		ttl:           60,
+		timeout:       2 * time.Second,
+		tsigName:      "acme-update",
+		tsigSecret:    testSecret,
+		tsigAlgorithm: "hmac-sha256",
+	}
+}
+
+// checks the update adds (class IN) or removes (class NONE) the TXT record
+func checkUpdate(t *testing.T, update *dns.Msg, class uint16, ttl uint32) {
+	if 1 != len(update.Ns) {
+		t.Fatalf("Expected a single record in the update section, got %v", update.Ns)
+	}
+	txt, ok := update.Ns[0].(*dns.TXT)
+	if !ok {
+		t.Fatalf("Unexpected record %s", update.Ns[0])
+	}
+	if testName != txt.Hdr.Name || class != txt.Hdr.Class || ttl != txt.Hdr.Ttl || "value" != strings.Join(txt.Txt, "") {
+		t.Fatalf("Unexpected record %s", txt)
+	}
+	if tsig := update.IsTsig(); nil == tsig || testKeyName != tsig.Hdr.Name || dns.HmacSHA256 != tsig.Algorithm {
+		t.Fatalf("Update not signed with %s", testKeyName)
+	}
+}
+
+func TestAddRemove(t *testing.T) {
+	ns, address := startNameserver(t)
+	provider := testProvider(address)
+
+	if err := provider.AddTXT(testName, "value"); nil != err {
+		t.Fatal(err)
+	}
+	soaQueries, updates := ns.reset()
+	if 1 != len(soaQueries) || 1 != len(updates) {
+		t.Fatalf("Unexpected requests: SOA queries %v, %d updates", soaQueries, len(updates))
+	}
+	checkUpdate(t, updates[0], dns.ClassINET, 60)
+
+	if err := provider.RemoveTXT(testName, "value"); nil != err {
+		t.Fatal(err)
+	}
+	_, updates = ns.reset()
+	if 1 != len(updates) {
+		t.Fatalf("Expected a single update, got %d", len(updates))
+	}
+	checkUpdate(t, updates[0], dns.ClassNONE, 0)
+
+	// configured zone doesn't need a SOA lookup
+	provider.zone = "example.com"
+	if err := provider.AddTXT(testName, "value"); nil != err {
+		t.Fatal(err)
+	}
+	if soaQueries, updates := ns.reset(); 0 != len(soaQueries) || 1 != len(updates) {
+		t.Fatalf("Unexpected requests: SOA queries %v, %d updates", soaQueries, len(updates))
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	ns, address := startNameserver(t)
+
+	provider := testProvider(address)
+	provider.tsigSecret = "d3Jvbmcgc2VjcmV0"
+	if err := provider.AddTXT(testName, "value"); nil == err || !strings.Contains(err.Error(), "NOTAUTH") {
+		t.Fatalf("Expected update to be rejected, got %v", err)
+	}
+
+	provider = testProvider(address)
+	provider.tsigName = "other-key"
+	if err := provider.AddTXT(testName, "value"); nil == err || !strings.Contains(err.Error(), "NOTAUTH") {
+		t.Fatalf("Expected update to be rejected, got %v", err)
+	}
+
+	provider = testProvider(address)
+	provider.tsigName = ""
+	if err := provider.AddTXT(testName, "value"); nil == err || !strings.Contains(err.Error(), "NOTAUTH") {
+		t.Fatalf("Expected unsigned update to be rejected, got %v", err)
+	}
+
+	if _, updates := ns.reset(); 0 != len(updates) {
+		t.Fatalf("Accepted %d updates with wrong key", len(updates))
+	}
+}
+
+func TestFindZone(t *testing.T) {
+	ns, address := startNameserver(t)
+	provider := testProvider(address)
+
+	// SOA in the authority section of the NXDOMAIN answer
+	if zone, err := provider.findZone(testName); nil != err {
+		t.Fatal(err)
+	} else if testZone != zone {
+		t.Fatalf("Expected zone %s, got %s", testZone, zone)
+	}
+
+	// without SOA in negative answers the parent names are tried until the
+	// apex returns the SOA
+	ns.mutex.Lock()
+	ns.minimal = true
+	ns.mutex.Unlock()
+	ns.reset()
+	if zone, err := provider.findZone(testName); nil != err {
+		t.Fatal(err)
+	} else if testZone != zone {
+		t.Fatalf("Expected zone %s, got %s", testZone, zone)
+	}
+	expected := []string{testName, "www." + testZone, testZone}
+	if soaQueries, _ := ns.reset(); strings.Join(expected, " ") != strings.Join(soaQueries, " ") {
+		t.Fatalf("Expected SOA queries %v, got %v", expected, soaQueries)
+	}
+
+	if _, err := provider.findZone("_acme-challenge.example.net."); nil == err {
+		t.Fatal("Found zone for a name the nameserver isn't authoritative for")
+	}
+}
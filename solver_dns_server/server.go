@@ -0,0 +1,255 @@
+package solver_dns_server
+
+import (
+	"flag"
+	"fmt"
+	"github.com/miekg/dns"
+	"github.com/stbuehler/go-acme-client/solver_dns"
+	"github.com/stbuehler/go-acme-client/solver_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const SolverName = "dns-server"
+
+// minimal authoritative nameserver answering TXT queries for the pending
+// challenges; needs the _acme-challenge names delegated to it (NS or CNAME).
+// Each record name is served as zone of its own (with SOA and NS records),
+// so resolvers looking for the zone cut find it. It only runs while records
+// are present.
+type dnsServer struct {
+	listen   string
+	ttl      uint
+	hostname string
+
+	// protects records and serial
+	mutex sync.Mutex
+	// maps lowercase fully qualified name to TXT values
+	records map[string][]string
+	serial  uint32
+
+	servers []*dns.Server
+}
+
+var Provider = &dnsServer{
+	records: make(map[string][]string),
+}
+
+func init() {
+	solver_interface.Register(types.DNSChallengeIdentifier, SolverName, solver_dns.NewSolver(Provider))
+}
+
+func (server *dnsServer) AddFlags(flags *flag.FlagSet) {
+	flags.StringVar(&server.listen, "dns-server-listen", "", "Run a nameserver for dns challenges on ADDRESS:PORT (UDP and TCP), e.g. :53")
+	flags.UintVar(&server.ttl, "dns-server-ttl", 0, "TTL of the TXT records served")
+	flags.StringVar(&server.hostname, "dns-server-hostname", "", "Name of this nameserver as used in the NS delegation, returned in NS and SOA records (default: the system host name)")
+}
+
+func (server *dnsServer) Configured(domain string) bool {
+	return 0 != len(server.listen)
+}
+
+// address to check whether the records are served
+func (server *dnsServer) Nameserver() string {
+	host, port, err := net.SplitHostPort(server.listen)
+	if nil != err {
+		return server.listen
+	}
+	if ip := net.ParseIP(host); 0 == len(host) || (nil != ip && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+func (server *dnsServer) AddTXT(name string, value string) error {
+	if 0 == len(server.servers) {
+		if err := server.start(); nil != err {
+			return err
+		}
+	}
+	name = strings.ToLower(name)
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.records[name] = append(server.records[name], value)
+	server.bumpSerial()
+	return nil
+}
+
+func (server *dnsServer) RemoveTXT(name string, value string) error {
+	name = strings.ToLower(name)
+	server.mutex.Lock()
+	values := server.records[name]
+	for i, v := range values {
+		if v == value {
+			values = append(values[:i], values[i+1:]...)
+			break
+		}
+	}
+	if 0 == len(values) {
+		delete(server.records, name)
+	} else {
+		server.records[name] = values
+	}
+	server.bumpSerial()
+	empty := 0 == len(server.records)
+	server.mutex.Unlock()
+
+	if empty {
+		server.stop()
+	}
+	return nil
+}
+
+func (server *dnsServer) start() error {
+	for _, network := range []string{"udp", "tcp"} {
+		started := make(chan error, 1)
+		listener := &dns.Server{
+			Addr:              server.listen,
+			Net:               network,
+			Handler:           server,
+			NotifyStartedFunc: func() { started <- nil },
+		}
+		go func() {
+			if err := listener.ListenAndServe(); nil != err {
+				started <- err
+			}
+		}()
+		if err := <-started; nil != err {
+			server.stop()
+			return fmt.Errorf("Couldn't start nameserver on %s/%s: %s", server.listen, network, err)
+		}
+		server.servers = append(server.servers, listener)
+	}
+	utils.Infof("Started nameserver on %s", server.listen)
+	return nil
+}
+
+func (server *dnsServer) stop() {
+	for _, listener := range server.servers {
+		if err := listener.Shutdown(); nil != err {
+			utils.Errorf("Couldn't stop nameserver on %s/%s: %s", server.listen, listener.Net, err)
+		}
+	}
+	if 0 != len(server.servers) {
+		utils.Infof("Stopped nameserver on %s", server.listen)
+	}
+	server.servers = nil
+}
+
+// unix time, but always increasing; needs the mutex
+func (server *dnsServer) bumpSerial() {
+	if now := uint32(time.Now().Unix()); now > server.serial {
+		server.serial = now
+	} else {
+		server.serial++
+	}
+}
+
+// fully qualified name for NS and SOA records
+func (server *dnsServer) nameserverName() string {
+	if 0 != len(server.hostname) {
+		return dns.Fqdn(server.hostname)
+	}
+	if hostname, err := os.Hostname(); nil == err && 0 != len(hostname) {
+		return dns.Fqdn(hostname)
+	}
+	return "localhost."
+}
+
+// the record name (zone) containing name, with its TXT values and the
+// current serial
+func (server *dnsServer) lookup(name string) (string, []string, uint32, bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	name = strings.ToLower(name)
+	zone := ""
+	for recordName := range server.records {
+		if dns.IsSubDomain(recordName, name) && len(recordName) > len(zone) {
+			zone = recordName
+		}
+	}
+	if 0 == len(zone) {
+		return "", nil, 0, false
+	}
+	return zone, append([]string(nil), server.records[zone]...), server.serial, true
+}
+
+func (server *dnsServer) header(name string, rrtype uint16) dns.RR_Header {
+	return dns.RR_Header{
+		Name:   name,
+		Rrtype: rrtype,
+		Class:  dns.ClassINET,
+		Ttl:    uint32(server.ttl),
+	}
+}
+
+// TXT, SOA and NS records for pending challenge names (each its own zone),
+// NXDOMAIN or empty answers with the SOA of the zone below them and
+// NXDOMAIN for all other names
+func (server *dnsServer) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	msg := new(dns.Msg)
+	msg.SetReply(req)
+	msg.Authoritative = true
+
+	if 1 != len(req.Question) {
+		msg.Rcode = dns.RcodeFormatError
+	} else if question := req.Question[0]; dns.ClassINET != question.Qclass && dns.ClassANY != question.Qclass {
+		msg.Rcode = dns.RcodeRefused
+	} else if zone, values, serial, found := server.lookup(question.Name); !found {
+		msg.Rcode = dns.RcodeNameError
+	} else {
+		// zone apex as spelled in the question
+		apex := question.Name[len(question.Name)-len(zone):]
+		soa := &dns.SOA{
+			Hdr:     server.header(apex, dns.TypeSOA),
+			Ns:      server.nameserverName(),
+			Mbox:    "hostmaster." + server.nameserverName(),
+			Serial:  serial,
+			Refresh: 60,
+			Retry:   60,
+			Expire:  600,
+			Minttl:  uint32(server.ttl),
+		}
+		if len(question.Name) != len(zone) {
+			// below the zone apex nothing exists
+			msg.Rcode = dns.RcodeNameError
+		} else {
+			if dns.TypeTXT == question.Qtype || dns.TypeANY == question.Qtype {
+				for _, value := range values {
+					msg.Answer = append(msg.Answer, &dns.TXT{
+						Hdr: server.header(question.Name, dns.TypeTXT),
+						Txt: solver_dns.SplitTXT(value),
+					})
+				}
+			}
+			if dns.TypeSOA == question.Qtype || dns.TypeANY == question.Qtype {
+				msg.Answer = append(msg.Answer, soa)
+			}
+			if dns.TypeNS == question.Qtype || dns.TypeANY == question.Qtype {
+				msg.Answer = append(msg.Answer, &dns.NS{
+					Hdr: server.header(question.Name, dns.TypeNS),
+					Ns:  server.nameserverName(),
+				})
+			}
+		}
+		if 0 == len(msg.Answer) {
+			msg.Ns = append(msg.Ns, soa)
+		}
+	}
+
+	if _, udp := w.LocalAddr().(*net.UDPAddr); udp {
+		size := dns.MinMsgSize
+		if opt := req.IsEdns0(); nil != opt {
+			size = int(opt.UDPSize())
+		}
+		msg.Truncate(size)
+	}
+	if err := w.WriteMsg(msg); nil != err {
+		utils.Debugf("Couldn't send DNS reply to %s: %s", w.RemoteAddr(), err)
+	}
+}
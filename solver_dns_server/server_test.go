@@ -0,0 +1,249 @@
+package solver_dns_server
+
+import (
+	"github.com/miekg/dns"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testName = "_acme-challenge.example.com."
+
+func newTestServer(t *testing.T) *dnsServer {
+	server := &dnsServer{
+		listen:   "127.0.0.1:0",
+		ttl:      30,
+		hostname: "acme-ns.example.net",
+		records:  make(map[string][]string),
+	}
+	t.Cleanup(server.stop)
+	return server
+}
+
+// addresses of the running listeners by network; with port 0 UDP and TCP
+// get different ports
+func listenerAddresses(t *testing.T, server *dnsServer) map[string]string {
+	addresses := make(map[string]string)
+	for _, listener := range server.servers {
+		if nil != listener.PacketConn {
+			addresses[listener.Net] = listener.PacketConn.LocalAddr().String()
+		} else if nil != listener.Listener {
+			addresses[listener.Net] = listener.Listener.Addr().String()
+		}
+	}
+	if 2 != len(addresses) {
+		t.Fatalf("Expected UDP and TCP listener, got %v", addresses)
+	}
+	return addresses
+}
+
+func query(network string, address string, name string, qtype uint16) (*dns.Msg, error) {
+	client := &dns.Client{Net: network, Timeout: time.Second}
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	resp, _, err := client.Exchange(msg, address)
+	return resp, err
+}
+
+func hasSOA(rrs []dns.RR) bool {
+	for _, rr := range rrs {
+		if soa, ok := rr.(*dns.SOA); ok && testName == soa.Hdr.Name && "acme-ns.example.net." == soa.Ns {
+			return true
+		}
+	}
+	return false
+}
+
+func TestServeTXT(t *testing.T) {
+	server := newTestServer(t)
+	longValue := strings.Repeat("x", 300)
+	if err := server.AddTXT(testName, "value"); nil != err {
+		t.Fatal(err)
+	}
+	if err := server.AddTXT(testName, longValue); nil != err {
+		t.Fatal(err)
+	}
+
+	for network, address := range listenerAddresses(t, server) {
+		resp, err := query(network, address, "_ACME-Challenge.Example.COM.", dns.TypeTXT)
+		if nil != err {
+			t.Fatalf("%s: %s", network, err)
+		}
+		if dns.RcodeSuccess != resp.Rcode || !resp.Authoritative {
+			t.Fatalf("%s: unexpected reply %s", network, resp)
+		}
+		var values []string
+		for _, rr := range resp.Answer {
+			txt, ok := rr.(*dns.TXT)
+			if !ok {
+				t.Fatalf("%s: unexpected record %s", network, rr)
+			}
+			if 30 != txt.Hdr.Ttl {
+				t.Fatalf("%s: unexpected TTL %d", network, txt.Hdr.Ttl)
+			}
+			values = append(values, strings.Join(txt.Txt, ""))
+		}
+		if 2 != len(values) || "value" != values[0] || longValue != values[1] {
+			t.Fatalf("%s: unexpected TXT values %v", network, values)
+		}
+
+		// other names don't exist
+		if resp, err := query(network, address, "example.com.", dns.TypeTXT); nil != err {
+			t.Fatalf("%s: %s", network, err)
+		} else if dns.RcodeNameError != resp.Rcode || 0 != len(resp.Answer) {
+			t.Fatalf("%s: expected NXDOMAIN, got %s", network, resp)
+		}
+		// other types get an empty answer, names below NXDOMAIN; both with
+		// the SOA of the zone
+		if resp, err := query(network, address, testName, dns.TypeA); nil != err {
+			t.Fatalf("%s: %s", network, err)
+		} else if dns.RcodeSuccess != resp.Rcode || 0 != len(resp.Answer) || !hasSOA(resp.Ns) {
+			t.Fatalf("%s: expected empty answer, got %s", network, resp)
+		}
+		if resp, err := query(network, address, "sub."+testName, dns.TypeTXT); nil != err {
+			t.Fatalf("%s: %s", network, err)
+		} else if dns.RcodeNameError != resp.Rcode || 0 != len(resp.Answer) || !hasSOA(resp.Ns) {
+			t.Fatalf("%s: expected NXDOMAIN, got %s", network, resp)
+		}
+		// the record name is a zone apex
+		if resp, err := query(network, address, testName, dns.TypeSOA); nil != err {
+			t.Fatalf("%s: %s", network, err)
+		} else if !hasSOA(resp.Answer) {
+			t.Fatalf("%s: expected SOA, got %s", network, resp)
+		}
+		if resp, err := query(network, address, testName, dns.TypeNS); nil != err {
+			t.Fatalf("%s: %s", network, err)
+		} else if 1 != len(resp.Answer) || "acme-ns.example.net." != resp.Answer[0].(*dns.NS).Ns {
+			t.Fatalf("%s: expected NS, got %s", network, resp)
+		}
+	}
+}
+
+func TestStopWithLastRecord(t *testing.T) {
+	server := newTestServer(t)
+	if err := server.AddTXT(testName, "first"); nil != err {
+		t.Fatal(err)
+	}
+	if err := server.AddTXT("_acme-challenge.example.org.", "second"); nil != err {
+		t.Fatal(err)
+	}
+	addresses := listenerAddresses(t, server)
+
+	if err := server.RemoveTXT(testName, "first"); nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(server.servers) {
+		t.Fatal("Nameserver stopped while records are left")
+	}
+	for network, address := range addresses {
+		if resp, err := query(network, address, testName, dns.TypeTXT); nil != err {
+			t.Fatalf("%s: %s", network, err)
+		} else if dns.RcodeNameError != resp.Rcode {
+			t.Fatalf("%s: removed record still served: %s", network, resp)
+		}
+	}
+
+	if err := server.RemoveTXT("_acme-challenge.example.org.", "second"); nil != err {
+		t.Fatal(err)
+	}
+	if 0 != len(server.servers) {
+		t.Fatal("Nameserver still running without records")
+	}
+	if conn, err := net.DialTimeout("tcp", addresses["tcp"], time.Second); nil == err {
+		conn.Close()
+		t.Fatal("TCP listener still accepts connections")
+	}
+	if _, err := query("udp", addresses["udp"], testName, dns.TypeTXT); nil == err {
+		t.Fatal("UDP listener still answers")
+	}
+}
+
+// recursive resolver for a parent zone example.com which delegates
+// _acme-challenge.example.com to the embedded server (queries below the
+// delegation are forwarded to it, like a resolver following the referral)
+type testResolver struct {
+	delegatedTo string
+}
+
+func (resolver testResolver) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	msg := new(dns.Msg)
+	msg.SetReply(req)
+	msg.RecursionAvailable = true
+	question := req.Question[0]
+	if dns.IsSubDomain(testName, question.Name) {
+		if resp, err := query("udp", resolver.delegatedTo, question.Name, question.Qtype); nil != err {
+			msg.Rcode = dns.RcodeServerFailure
+		} else {
+			msg.Rcode = resp.Rcode
+			msg.Answer, msg.Ns = resp.Answer, resp.Ns
+		}
+	} else if "example.com." == question.Name && dns.TypeNS == question.Qtype {
+		msg.Answer = append(msg.Answer, &dns.NS{
+			Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 300},
+			Ns:  "ns1.example.net.",
+		})
+	} else {
+		msg.Ns = append(msg.Ns, &dns.SOA{
+			Hdr:    dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 300},
+			Ns:     "ns1.example.net.",
+			Mbox:   "hostmaster.example.com.",
+			Minttl: 300,
+		})
+	}
+	w.WriteMsg(msg)
+}
+
+func TestResolveThroughDelegation(t *testing.T) {
+	server := newTestServer(t)
+	if err := server.AddTXT(testName, "value"); nil != err {
+		t.Fatal(err)
+	}
+
+	started := make(chan error, 1)
+	resolver := &dns.Server{
+		Addr:              "127.0.0.1:0",
+		Net:               "udp",
+		Handler:           testResolver{delegatedTo: listenerAddresses(t, server)["udp"]},
+		NotifyStartedFunc: func() { started <- nil },
+	}
+	go func() {
+		if err := resolver.ListenAndServe(); nil != err {
+			started <- err
+		}
+	}()
+	if err := <-started; nil != err {
+		t.Fatal(err)
+	}
+	defer resolver.Shutdown()
+	address := resolver.PacketConn.LocalAddr().String()
+
+	// closest enclosing name with NS records, as the dns solver looks for
+	// the authoritative nameservers
+	zone, nameservers := "", []string(nil)
+	for candidate := testName; 0 == len(nameservers); {
+		resp, err := query("udp", address, candidate, dns.TypeNS)
+		if nil != err {
+			t.Fatal(err)
+		}
+		for _, rr := range resp.Answer {
+			if ns, ok := rr.(*dns.NS); ok && candidate == ns.Hdr.Name {
+				zone, nameservers = candidate, append(nameservers, ns.Ns)
+			}
+		}
+		ndx, end := dns.NextLabel(candidate, 0)
+		if end {
+			break
+		}
+		candidate = candidate[ndx:]
+	}
+	if testName != zone || 1 != len(nameservers) || "acme-ns.example.net." != nameservers[0] {
+		t.Fatalf("Expected zone cut at %s served by acme-ns.example.net., got %s %v", testName, zone, nameservers)
+	}
+
+	if resp, err := query("udp", address, testName, dns.TypeTXT); nil != err {
+		t.Fatal(err)
+	} else if 1 != len(resp.Answer) || "value" != strings.Join(resp.Answer[0].(*dns.TXT).Txt, "") {
+		t.Fatalf("Unexpected TXT answer %s", resp)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package solver_dns_zonefile
+
+import (
+	"os"
+	"syscall"
+)
+
+// gives the replacement file the owner of the original zone file (only
+// works as root, otherwise the file belongs to the current user)
+func copyOwner(file *os.File, info os.FileInfo) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		file.Chown(int(stat.Uid), int(stat.Gid))
+	}
+}
@@ -0,0 +1,167 @@
+package solver_dns_zonefile
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// position of a token (outside comments) in a zone file
+type token struct {
+	start int
+	end   int
+}
+
+// tokens splits zone file content into words, quoted strings and
+// parentheses, skipping comments
+func tokens(content []byte) []token {
+	var result []token
+	for i := 0; i < len(content); {
+		switch c := content[i]; {
+		case ';' == c:
+			for i < len(content) && '\n' != content[i] {
+				i++
+			}
+		case ' ' == c || '\t' == c || '\r' == c || '\n' == c:
+			i++
+		case '(' == c || ')' == c:
+			result = append(result, token{i, i + 1})
+			i++
+		case '"' == c:
+			start := i
+			for i++; i < len(content) && '"' != content[i]; i++ {
+				if '\\' == content[i] {
+					i++
+				}
+			}
+			i++
+			if i > len(content) {
+				i = len(content)
+			}
+			result = append(result, token{start, i})
+		default:
+			start := i
+			for ; i < len(content) && !strings.ContainsRune(" \t\r\n;()\"", rune(content[i])); i++ {
+				if '\\' == content[i] {
+					i++
+				}
+			}
+			if i > len(content) {
+				i = len(content)
+			}
+			result = append(result, token{start, i})
+		}
+	}
+	return result
+}
+
+var ttlPattern = regexp.MustCompile(`^(?i)[0-9]+([smhdw][0-9]*)*$`)
+
+// TTLs and classes may come (in any order) between owner and type
+func isTTLOrClass(text string) bool {
+	switch strings.ToUpper(text) {
+	case "IN", "CH", "HS", "CS":
+		return true
+	}
+	return ttlPattern.MatchString(text)
+}
+
+// findSerial returns the position of the SOA serial; "SOA" only counts in
+// the type field of a record (not as owner name or in other data)
+func findSerial(content []byte) (token, error) {
+	const (
+		beforeType = iota
+		inSOA
+		skipRecord
+	)
+	state, depth, fields, previousEnd := skipRecord, 0, 0, 0
+	for _, tok := range tokens(content) {
+		text := string(content[tok.start:tok.end])
+		// records end at the end of the line, unless in parentheses
+		if 0 == depth && (0 == tok.start || -1 != bytes.IndexByte(content[previousEnd:tok.start], '\n')) {
+			state = beforeType
+			if strings.HasPrefix(text, "$") {
+				// $ORIGIN, $TTL, $INCLUDE, ...
+				state = skipRecord
+			} else if 0 == tok.start || '\n' == content[tok.start-1] {
+				// owner name, only present if the line doesn't start with
+				// whitespace
+				previousEnd = tok.end
+				continue
+			}
+		}
+		previousEnd = tok.end
+		switch text {
+		case "(":
+			depth++
+			continue
+		case ")":
+			depth--
+			continue
+		}
+
+		switch state {
+		case beforeType:
+			if isTTLOrClass(text) {
+				continue
+			}
+			if strings.EqualFold("SOA", text) {
+				state, fields = inSOA, 0
+			} else {
+				state = skipRecord
+			}
+		case inSOA:
+			// MNAME and RNAME come before the serial
+			if 2 == fields {
+				return tok, nil
+			}
+			fields++
+		}
+	}
+	return token{}, fmt.Errorf("No SOA record found")
+}
+
+// date based serials look like YYYYMMDDnn
+func isDateSerial(serial string) bool {
+	if 10 != len(serial) {
+		return false
+	}
+	_, err := time.Parse("20060102", serial[:8])
+	return nil == err
+}
+
+// nextSerial keeps the scheme of the old serial: date based serials jump to
+// the current date if possible, all others are incremented
+func nextSerial(serial string, now time.Time) (string, error) {
+	old, err := strconv.ParseUint(serial, 10, 32)
+	if nil != err {
+		return "", fmt.Errorf("Invalid SOA serial %#v", serial)
+	}
+	if isDateSerial(serial) {
+		today, _ := strconv.ParseUint(now.Format("20060102"), 10, 32)
+		if today*100 > old {
+			return strconv.FormatUint(today*100, 10), nil
+		}
+	}
+	return strconv.FormatUint((old+1)%(1<<32), 10), nil
+}
+
+// bumpSerial replaces the SOA serial, leaving everything else as it is
+func bumpSerial(content []byte, now time.Time) ([]byte, string, error) {
+	tok, err := findSerial(content)
+	if nil != err {
+		return nil, "", err
+	}
+	serial, err := nextSerial(string(content[tok.start:tok.end]), now)
+	if nil != err {
+		return nil, "", err
+	}
+	result := make([]byte, 0, len(content)+len(serial))
+	result = append(result, content[:tok.start]...)
+	result = append(result, serial...)
+	result = append(result, content[tok.end:]...)
+	return result, serial, nil
+}
@@ -0,0 +1,102 @@
+package solver_dns_zonefile
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testZone = `$ORIGIN example.com.
+$TTL 3600 ; soa in a comment
+soa	IN	A	192.0.2.1
+@	IN	TXT	"SOA 1 2 3" ( "x" )
+	3600 IN SOA ns1.example.com. hostmaster.example.com. (
+		2026101703 ; serial
+		7200 3600 1209600 300 )
+@	IN	NS	ns1.example.com.
+`
+
+func TestTokens(t *testing.T) {
+	content := []byte("@ IN TXT \"a \\\" b;c\" ; comment\n\t(x\\ y) \"unterminated")
+	var texts []string
+	for _, tok := range tokens(content) {
+		texts = append(texts, string(content[tok.start:tok.end]))
+	}
+	expected := []string{"@", "IN", "TXT", `"a \" b;c"`, "(", `x\ y`, ")", `"unterminated`}
+	if strings.Join(expected, "|") != strings.Join(texts, "|") {
+		t.Fatalf("Expected tokens %q, got %q", expected, texts)
+	}
+}
+
+func TestFindSerial(t *testing.T) {
+	for _, test := range []struct {
+		zone   string
+		serial string
+	}{
+		{testZone, "2026101703"},
+		{"@ SOA ns. host. 7 1 2 3 4\n", "7"},
+		{"@ 1h IN SOA ns. host. (8 1 2 3 4)\n", "8"},
+		{"example.com. IN 60 SOA (\n ns.\n host.\n 9\n 1 2 3 4 )\n", "9"},
+		{"$INCLUDE soa\n@ IN SOA ns. host. 10 1 2 3 4\n", "10"},
+		// "soa" as owner and in the TXT data only
+		{"soa IN A 192.0.2.1\n@ IN TXT SOA 1 2 3\n@ IN CNAME soa\n", ""},
+		{"; @ IN SOA ns. host. 1 2 3 4 5\n", ""},
+	} {
+		tok, err := findSerial([]byte(test.zone))
+		if 0 == len(test.serial) {
+			if nil == err {
+				t.Errorf("Found serial %q in %q", test.zone[tok.start:tok.end], test.zone)
+			}
+			continue
+		}
+		if nil != err {
+			t.Errorf("%q: %s", test.zone, err)
+		} else if serial := test.zone[tok.start:tok.end]; test.serial != serial {
+			t.Errorf("%q: expected serial %s, got %s", test.zone, test.serial, serial)
+		}
+	}
+}
+
+func TestNextSerial(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		serial, next string
+	}{
+		// date based: jump to today, or count up
+		{"2026101703", "2026101800"},
+		{"2026101800", "2026101801"},
+		{"2026101899", "2026101900"},
+		{"2027010100", "2027010101"},
+		// incremented
+		{"1", "2"},
+		{"1760000000", "1760000001"},
+		{"2026133100", "2026133101"},
+		// wraps around (RFC 1982 serial arithmetic)
+		{"4294967295", "0"},
+	} {
+		next, err := nextSerial(test.serial, now)
+		if nil != err {
+			t.Fatalf("%s: %s", test.serial, err)
+		} else if test.next != next {
+			t.Errorf("Expected %s after %s, got %s", test.next, test.serial, next)
+		}
+	}
+	for _, invalid := range []string{"", "x", "-1", "4294967296"} {
+		if _, err := nextSerial(invalid, now); nil == err {
+			t.Errorf("Accepted invalid serial %#v", invalid)
+		}
+	}
+}
+
+func TestBumpSerial(t *testing.T) {
+	content, serial, err := bumpSerial([]byte(testZone), time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "2026101800" != serial {
+		t.Fatalf("Unexpected serial %s", serial)
+	}
+	if expected := strings.Replace(testZone, "2026101703", "2026101800", 1); expected != string(content) {
+		t.Fatalf("Expected\n%s\ngot\n%s", expected, content)
+	}
+}
@@ -0,0 +1,190 @@
+package solver_dns_zonefile
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/miekg/dns"
+	"github.com/stbuehler/go-acme-client/solver_dns"
+	"github.com/stbuehler/go-acme-client/solver_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SolverName = "zonefile"
+
+// appended to the lines added to zone files
+const lineMarker = "; acme-client challenge"
+
+// maps (fully qualified) zone names to zone files
+type ZoneFiles map[string]string
+
+func (files *ZoneFiles) String() string {
+	var entries []string
+	for zone, path := range *files {
+		entries = append(entries, zone+"="+path)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// accepts "ZONE=PATH"
+func (files *ZoneFiles) Set(v string) error {
+	ndx := strings.Index(v, "=")
+	if ndx <= 0 || ndx == len(v)-1 {
+		return fmt.Errorf("Expected ZONE=PATH, got %#v", v)
+	}
+	if nil == *files {
+		*files = make(ZoneFiles)
+	}
+	(*files)[strings.ToLower(dns.Fqdn(v[:ndx]))] = v[ndx+1:]
+	return nil
+}
+
+// adds TXT records to zone files of a (hidden) primary nameserver and
+// reloads it; the SOA serial is bumped on every change
+type zonefileProvider struct {
+	files         ZoneFiles
+	reloadCommand string
+	ttl           uint
+}
+
+var Provider = &zonefileProvider{}
+
+func init() {
+	solver_interface.Register(types.DNSChallengeIdentifier, SolverName, solver_dns.NewSolver(Provider))
+}
+
+func (provider *zonefileProvider) AddFlags(flags *flag.FlagSet) {
+	flags.Var(&provider.files, "zonefile", "Zone file to add TXT records to, given as ZONE=PATH; can be used multiple times")
+	flags.StringVar(&provider.reloadCommand, "zonefile-reload", "", "Shell command reloading a zone after a change, e.g. 'rndc reload \"$ACME_ZONE\"' (ACME_ZONE and ACME_ZONE_FILE are set)")
+	flags.UintVar(&provider.ttl, "zonefile-ttl", 60, "TTL of added TXT records")
+}
+
+// the zone file for the longest zone containing name
+func (provider *zonefileProvider) lookup(name string) (string, string, bool) {
+	name = strings.ToLower(dns.Fqdn(name))
+	var bestZone, bestPath string
+	for zone, path := range provider.files {
+		if dns.IsSubDomain(zone, name) && len(zone) > len(bestZone) {
+			bestZone, bestPath = zone, path
+		}
+	}
+	return bestZone, bestPath, 0 != len(bestZone)
+}
+
+func (provider *zonefileProvider) Configured(domain string) bool {
+	_, _, ok := provider.lookup(domain)
+	return ok
+}
+
+func (provider *zonefileProvider) line(name string, value string) string {
+	var quoted []string
+	for _, part := range solver_dns.SplitTXT(value) {
+		quoted = append(quoted, strconv.Quote(part))
+	}
+	return fmt.Sprintf("%s %d IN TXT %s %s", dns.Fqdn(name), provider.ttl, strings.Join(quoted, " "), lineMarker)
+}
+
+func (provider *zonefileProvider) AddTXT(name string, value string) error {
+	line := provider.line(name, value)
+	return provider.modify(name, func(content []byte) ([]byte, error) {
+		if 0 != len(content) && '\n' != content[len(content)-1] {
+			content = append(content, '\n')
+		}
+		return append(content, line+"\n"...), nil
+	})
+}
+
+func (provider *zonefileProvider) RemoveTXT(name string, value string) error {
+	line := []byte(provider.line(name, value) + "\n")
+	return provider.modify(name, func(content []byte) ([]byte, error) {
+		ndx := bytes.Index(content, line)
+		if -1 == ndx || (0 != ndx && '\n' != content[ndx-1]) {
+			return nil, fmt.Errorf("Added line not found anymore")
+		}
+		return append(content[:ndx:ndx], content[ndx+len(line):]...), nil
+	})
+}
+
+// modify changes the zone file for name, bumps the serial and reloads the
+// zone
+func (provider *zonefileProvider) modify(name string, change func(content []byte) ([]byte, error)) error {
+	zone, path, ok := provider.lookup(name)
+	if !ok {
+		return fmt.Errorf("No zone file configured for %s", name)
+	}
+	content, err := ioutil.ReadFile(path)
+	if nil != err {
+		return err
+	}
+	if content, err = change(content); nil != err {
+		return fmt.Errorf("Couldn't update %s: %s", path, err)
+	}
+	content, serial, err := bumpSerial(content, time.Now())
+	if nil != err {
+		return fmt.Errorf("Couldn't update serial in %s: %s", path, err)
+	}
+	if err := replaceFile(path, content); nil != err {
+		return fmt.Errorf("Couldn't write %s: %s", path, err)
+	}
+	utils.Infof("Updated zone file %s (serial %s)", path, serial)
+	return provider.reload(zone, path)
+}
+
+// replaceFile writes content to a temporary file next to path and renames
+// it over path, so the nameserver never reads a partially written zone;
+// permissions (and the owner if possible) are kept
+func replaceFile(path string, content []byte) error {
+	// replace the target of a symlink, not the link
+	if resolved, err := filepath.EvalSymlinks(path); nil == err {
+		path = resolved
+	}
+	info, err := os.Stat(path)
+	if nil != err {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if nil != err {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	copyOwner(file, info)
+	if err := file.Chmod(info.Mode().Perm()); nil != err {
+		return err
+	}
+	if _, err := file.Write(content); nil != err {
+		return err
+	}
+	if err := file.Sync(); nil != err {
+		return err
+	}
+	if err := file.Close(); nil != err {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func (provider *zonefileProvider) reload(zone string, path string) error {
+	if 0 == len(provider.reloadCommand) {
+		return nil
+	}
+	cmd := exec.Command("/bin/sh", "-c", provider.reloadCommand)
+	cmd.Env = append(os.Environ(),
+		"ACME_ZONE="+strings.TrimSuffix(zone, "."),
+		"ACME_ZONE_FILE="+path)
+	if output, err := cmd.CombinedOutput(); nil != err {
+		return fmt.Errorf("Reloading zone %s failed: %s\n%s", zone, err, output)
+	}
+	return nil
+}
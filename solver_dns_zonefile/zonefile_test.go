@@ -0,0 +1,77 @@
+package solver_dns_zonefile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddRemoveTXT(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.example.com")
+	if err := ioutil.WriteFile(path, []byte(testZone), 0640); nil != err {
+		t.Fatal(err)
+	}
+	// the configured path may be a symlink to the zone file
+	link := filepath.Join(dir, "zone")
+	if err := os.Symlink(path, link); nil != err {
+		t.Fatal(err)
+	}
+	provider := &zonefileProvider{
+		files:         ZoneFiles{"example.com.": link},
+		reloadCommand: `echo "$ACME_ZONE $ACME_ZONE_FILE" >> ` + filepath.Join(dir, "reloads"),
+		ttl:           60,
+	}
+
+	if err := provider.AddTXT("_acme-challenge.www.example.com.", "value"); nil != err {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), testZone[:strings.Index(testZone, "2026")]) || !strings.HasSuffix(string(content), "_acme-challenge.www.example.com. 60 IN TXT \"value\" "+lineMarker+"\n") {
+		t.Fatalf("Unexpected zone file\n%s", content)
+	}
+	if info, err := os.Lstat(path); nil != err {
+		t.Fatal(err)
+	} else if 0640 != info.Mode() {
+		t.Fatalf("Zone file mode changed to %s", info.Mode())
+	}
+	if info, err := os.Lstat(link); nil != err {
+		t.Fatal(err)
+	} else if 0 == info.Mode()&os.ModeSymlink {
+		t.Fatal("Symlink replaced by the zone file")
+	}
+
+	if err := provider.RemoveTXT("_acme-challenge.www.example.com.", "value"); nil != err {
+		t.Fatal(err)
+	}
+	content, err = ioutil.ReadFile(path)
+	if nil != err {
+		t.Fatal(err)
+	}
+	// bumped twice: to the current date, then counted up
+	tok, err := findSerial(content)
+	if nil != err {
+		t.Fatal(err)
+	}
+	serial := string(content[tok.start:tok.end])
+	if !strings.HasSuffix(serial, "01") || strings.Replace(testZone, "2026101703", serial, 1) != string(content) {
+		t.Fatalf("Unexpected zone file after cleanup\n%s", content)
+	}
+
+	// no temporary files left
+	if files, err := filepath.Glob(filepath.Join(dir, ".*")); nil != err || 0 != len(files) {
+		t.Fatalf("Temporary files left: %v", files)
+	}
+	reloads, err := ioutil.ReadFile(filepath.Join(dir, "reloads"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if expected := strings.Repeat("example.com "+link+"\n", 2); expected != string(reloads) {
+		t.Fatalf("Expected reloads %q, got %q", expected, reloads)
+	}
+}
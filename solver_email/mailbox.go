@@ -0,0 +1,136 @@
+package solver_email
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// message found in a local mailbox
+type mailboxMessage struct {
+	source   string
+	received time.Time
+	// position in an mbox file (later messages are newer)
+	order   int
+	content []byte
+}
+
+// newest first
+type messagesByAge []mailboxMessage
+
+func (messages messagesByAge) Len() int {
+	return len(messages)
+}
+
+func (messages messagesByAge) Less(i, j int) bool {
+	if !messages[i].received.Equal(messages[j].received) {
+		return messages[i].received.After(messages[j].received)
+	}
+	return messages[i].order > messages[j].order
+}
+
+func (messages messagesByAge) Swap(i, j int) {
+	messages[i], messages[j] = messages[j], messages[i]
+}
+
+// readMaildir returns the messages in the new and cur folders of a maildir
+func readMaildir(path string) ([]mailboxMessage, error) {
+	var messages []mailboxMessage
+	for _, folder := range []string{"new", "cur"} {
+		entries, err := ioutil.ReadDir(filepath.Join(path, folder))
+		if nil != err {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.Mode().IsRegular() {
+				continue
+			}
+			source := filepath.Join(path, folder, entry.Name())
+			content, err := ioutil.ReadFile(source)
+			if nil != err {
+				if os.IsNotExist(err) {
+					// moved by the mail client in the meantime
+					continue
+				}
+				return nil, err
+			}
+			messages = append(messages, mailboxMessage{
+				source:   source,
+				received: entry.ModTime(),
+				content:  content,
+			})
+		}
+	}
+	sort.Stable(messagesByAge(messages))
+	return messages, nil
+}
+
+var mboxQuotedFrom = regexp.MustCompile(`(?m)^>(>*From )`)
+
+// delivery time from the "From sender Sat Oct 17 12:00:00 2026" separator
+// line (local time), or the Date header; zero if neither can be parsed
+func mboxReceived(separator []byte, message []byte) time.Time {
+	if fields := strings.Fields(string(separator)); len(fields) >= 7 {
+		if received, err := time.ParseInLocation("Mon Jan _2 15:04:05 2006", strings.Join(fields[2:7], " "), time.Local); nil == err {
+			return received
+		}
+	}
+	if msg, err := mail.ReadMessage(bytes.NewReader(message)); nil == err {
+		if date, err := msg.Header.Date(); nil == err {
+			return date
+		}
+	}
+	return time.Time{}
+}
+
+// readMbox splits an mbox file into messages, ordered by the delivery time
+// (and their position in the file)
+func readMbox(path string) ([]mailboxMessage, error) {
+	content, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, err
+	}
+	var messages []mailboxMessage
+	var separator []byte
+	appendMessage := func(message []byte) {
+		if 0 == len(bytes.TrimSpace(message)) {
+			return
+		}
+		message = mboxQuotedFrom.ReplaceAll(message, []byte("$1"))
+		messages = append(messages, mailboxMessage{
+			source:   path,
+			received: mboxReceived(separator, message),
+			order:    len(messages),
+			content:  message,
+		})
+	}
+	start := -1
+	for pos := 0; pos < len(content); {
+		end := bytes.IndexByte(content[pos:], '\n')
+		if -1 == end {
+			end = len(content)
+		} else {
+			end += pos + 1
+		}
+		if bytes.HasPrefix(content[pos:end], []byte("From ")) && (0 == pos || (pos >= 2 && '\n' == content[pos-2])) {
+			if start >= 0 {
+				appendMessage(content[start:pos])
+			}
+			// skip the separator line
+			separator = content[pos:end]
+			start = end
+		}
+		pos = end
+	}
+	if start >= 0 {
+		appendMessage(content[start:])
+	}
+	sort.Stable(messagesByAge(messages))
+	return messages, nil
+}
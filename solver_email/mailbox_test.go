@@ -0,0 +1,126 @@
+package solver_email
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// challenge email as sent by the server (RFC 8823 section 3)
+func challengeEmail(tokenPart1 string, messageID string) string {
+	return strings.Join([]string{
+		"From: ACME <" + testSender + ">",
+		"To: " + testAddress,
+		"Subject: ACME: " + tokenPart1,
+		"Date: Sat, 17 Oct 2026 12:00:00 +0000",
+		"Message-ID: " + messageID,
+		"Auto-Submitted: auto-generated; type=acme",
+		"Content-Type: text/plain",
+		"",
+		"This is an automatically generated ACME challenge for email address",
+		testAddress + ".",
+		"",
+	}, "\n")
+}
+
+func writeMaildir(t *testing.T, messages map[string]string, ages map[string]time.Duration) string {
+	dir := t.TempDir()
+	for _, folder := range []string{"new", "cur", "tmp"} {
+		if err := os.Mkdir(filepath.Join(dir, folder), 0700); nil != err {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range messages {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); nil != err {
+			t.Fatal(err)
+		}
+		modified := time.Now().Add(-ages[name])
+		if err := os.Chtimes(path, modified, modified); nil != err {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReadMaildir(t *testing.T) {
+	dir := writeMaildir(t, map[string]string{
+		"new/1.host":     challengeEmail("newest", "<1@ca.example>"),
+		"cur/2.host:2,S": challengeEmail("older", "<2@ca.example>"),
+		"tmp/3.host":     challengeEmail("incomplete", "<3@ca.example>"),
+	}, map[string]time.Duration{
+		"new/1.host":     time.Minute,
+		"cur/2.host:2,S": time.Hour,
+	})
+
+	messages, err := readMaildir(dir)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(messages) {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	if !strings.Contains(string(messages[0].content), "ACME: newest") || filepath.Join(dir, "new/1.host") != messages[0].source {
+		t.Fatalf("Expected newest message first, got %s", messages[0].source)
+	}
+
+	if _, err := readMaildir(filepath.Join(dir, "missing")); nil == err {
+		t.Fatal("Reading a missing maildir should fail")
+	}
+}
+
+// mbox separator line for a message delivered at received
+func mboxSeparator(received time.Time) string {
+	return "From acme@ca.example " + received.Format("Mon Jan _2 15:04:05 2006")
+}
+
+func TestReadMbox(t *testing.T) {
+	newer := time.Date(2026, 10, 17, 12, 5, 0, 0, time.Local)
+	older := time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local)
+	mbox := strings.Join([]string{
+		mboxSeparator(newer),
+		strings.Replace(challengeEmail("first", "<1@ca.example>"), "This is", ">From the server: this is", 1),
+		mboxSeparator(older),
+		challengeEmail("second", "<2@ca.example>"),
+		// without date in the separator the Date header is used
+		"From MAILER-DAEMON",
+		strings.Replace(challengeEmail("third", "<3@ca.example>"), "Sat, 17 Oct", "Thu, 15 Oct", 1),
+		"From MAILER-DAEMON",
+		strings.Replace(challengeEmail("fourth", "<4@ca.example>"), "Date: ", "X-Date: ", 1),
+	}, "\n")
+	path := filepath.Join(t.TempDir(), "mbox")
+	if err := ioutil.WriteFile(path, []byte(mbox), 0600); nil != err {
+		t.Fatal(err)
+	}
+
+	messages, err := readMbox(path)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 4 != len(messages) {
+		t.Fatalf("Expected 4 messages, got %d", len(messages))
+	}
+	for ndx, expected := range []struct {
+		token    string
+		received time.Time
+	}{
+		{"first", newer},
+		{"second", older},
+		{"third", time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)},
+		{"fourth", time.Time{}},
+	} {
+		message := messages[ndx]
+		if !strings.HasPrefix(string(message.content), "From: ACME") || !strings.Contains(string(message.content), "ACME: "+expected.token+"\n") {
+			t.Fatalf("Expected message %s at %d, got %q", expected.token, ndx, message.content)
+		}
+		if !expected.received.Equal(message.received) {
+			t.Fatalf("Message %s: expected received %s, got %s", expected.token, expected.received, message.received)
+		}
+	}
+	if !strings.Contains(string(messages[0].content), "\nFrom the server: this is") {
+		t.Fatalf("Quoted From line not restored: %q", messages[0].content)
+	}
+}
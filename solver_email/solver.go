@@ -0,0 +1,151 @@
+package solver_email
+
+import (
+	"flag"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/solver_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+const SolverName = "email"
+
+// answers email-reply-00 challenges: the challenge email is taken from a
+// local maildir or mbox (or pasted by the operator), the reply is sent
+// through an SMTP server
+type emailSolver struct {
+	maildir      string
+	mbox         string
+	wait         time.Duration
+	maxAge       time.Duration
+	smtpServer   string
+	smtpUser     string
+	smtpPassword string
+}
+
+var Solver = &emailSolver{}
+
+func init() {
+	solver_interface.Register(types.EmailReplyIdentifier, SolverName, Solver)
+}
+
+func (solver *emailSolver) AddFlags(flags *flag.FlagSet) {
+	flags.StringVar(&solver.maildir, "email-maildir", "", "Maildir receiving the challenge emails")
+	flags.StringVar(&solver.mbox, "email-mbox", "", "Mbox file receiving the challenge emails")
+	flags.DurationVar(&solver.wait, "email-wait", 2*time.Minute, "How long to wait for the challenge email to show up in the maildir or mbox")
+	flags.DurationVar(&solver.maxAge, "email-max-age", 0, "Also accept challenge emails which arrived up to this long before the solver started (e.g. for an authorization requested earlier); older emails are left over from other attempts")
+	flags.StringVar(&solver.smtpServer, "email-smtp", "", "SMTP server (HOST:PORT) to send challenge replies through; STARTTLS is used if offered")
+	flags.StringVar(&solver.smtpUser, "email-smtp-user", "", "Username for SMTP authentication (the password is prompted for)")
+}
+
+func (solver *emailSolver) Configured(address string) bool {
+	return 0 != len(solver.smtpServer)
+}
+
+func emailResponding(response types.ChallengeResponding) (types.ChallengeEmailResponding, error) {
+	if emailResp, ok := response.(types.ChallengeEmailResponding); ok {
+		return emailResp, nil
+	}
+	challenge := response.Challenge()
+	return nil, fmt.Errorf("Solver %s can't handle challenge type %s", SolverName, challenge.GetType())
+}
+
+// findChallengeMessage polls the mailbox for the newest message passing
+// the checks of the challenge which arrived after notBefore
+func (solver *emailSolver) findChallengeMessage(emailResp types.ChallengeEmailResponding, notBefore time.Time) error {
+	// mbox delivery times only have seconds
+	notBefore = notBefore.Truncate(time.Second)
+	deadline := time.Now().Add(solver.wait)
+	for {
+		var messages []mailboxMessage
+		var err error
+		if 0 != len(solver.maildir) {
+			messages, err = readMaildir(solver.maildir)
+		} else {
+			messages, err = readMbox(solver.mbox)
+		}
+		if nil != err {
+			return fmt.Errorf("Couldn't read mailbox: %s", err)
+		}
+		for _, message := range messages {
+			if message.received.Before(notBefore) {
+				utils.Debugf("Skipping %s: received %s, before this attempt", message.source, message.received)
+				continue
+			}
+			if err := emailResp.SetChallengeMessage(message.content); nil != err {
+				utils.Debugf("Skipping %s: %s", message.source, err)
+				continue
+			}
+			utils.Infof("Using challenge email %s", message.source)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("No challenge email from %s for %s arrived within %s (emails received before the attempt are ignored, see -email-max-age)", emailResp.ChallengeSender(), emailResp.DNSIdentifier(), solver.wait)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func (solver *emailSolver) send(UI ui.UserInterface, from string, to string, message []byte) error {
+	var auth smtp.Auth
+	if 0 != len(solver.smtpUser) {
+		if 0 == len(solver.smtpPassword) {
+			password, err := UI.PasswordPrompt(fmt.Sprintf("Enter SMTP password for %s", solver.smtpUser))
+			if nil != err {
+				return err
+			}
+			solver.smtpPassword = password
+		}
+		host, _, err := net.SplitHostPort(solver.smtpServer)
+		if nil != err {
+			return fmt.Errorf("Invalid SMTP server %#v: %s", solver.smtpServer, err)
+		}
+		auth = smtp.PlainAuth("", solver.smtpUser, solver.smtpPassword, host)
+	}
+	if err := smtp.SendMail(solver.smtpServer, auth, from, []string{to}, message); nil != err {
+		return fmt.Errorf("Couldn't send reply through %s: %s", solver.smtpServer, err)
+	}
+	return nil
+}
+
+func (solver *emailSolver) Present(UI ui.UserInterface, response types.ChallengeResponding) error {
+	emailResp, err := emailResponding(response)
+	if nil != err {
+		return err
+	}
+	if 0 == len(solver.smtpServer) {
+		return fmt.Errorf("No SMTP server configured")
+	}
+	started := time.Now()
+
+	if 0 != len(solver.maildir) || 0 != len(solver.mbox) {
+		if err := solver.findChallengeMessage(emailResp, started.Add(-solver.maxAge)); nil != err {
+			return err
+		}
+	} else if err := emailResp.PromptChallengeMessage(UI); nil != err {
+		return err
+	}
+
+	message, err := emailResp.ResponseMessage()
+	if nil != err {
+		return err
+	}
+	if err := solver.send(UI, emailResp.DNSIdentifier(), emailResp.ChallengeSender(), message); nil != err {
+		return err
+	}
+	utils.Infof("Sent challenge reply from %s to %s", emailResp.DNSIdentifier(), emailResp.ChallengeSender())
+	return nil
+}
+
+func (solver *emailSolver) Verify(UI ui.UserInterface, response types.ChallengeResponding) error {
+	return response.Verify()
+}
+
+// a sent email can't be taken back
+func (solver *emailSolver) Cleanup(UI ui.UserInterface, response types.ChallengeResponding) error {
+	return nil
+}
@@ -0,0 +1,302 @@
+package solver_email
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"io/ioutil"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testAddress = "user@example.org"
+	testSender  = "acme@ca.example"
+	testToken   = "part2-token"
+	// RFC 8037 appendix A.1 key and its thumbprint (appendix A.3)
+	testKeySeed    = "nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A"
+	testThumbprint = "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"
+)
+
+func testResponding(t *testing.T) types.ChallengeEmailResponding {
+	seed, err := utils.Base64UrlDecode(testKeySeed)
+	if nil != err {
+		t.Fatal(err)
+	}
+	skey, err := types.NewSigningKey(ed25519.NewKeyFromSeed(seed))
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	var resource types.AuthorizationResource
+	if err := json.Unmarshal([]byte(`{
+		"identifier": {"type": "email", "value": "`+testAddress+`"},
+		"challenges": [{
+			"type": "email-reply-00",
+			"url": "https://ca.example/chall/1",
+			"from": "`+testSender+`",
+			"token": "`+testToken+`"
+		}]
+	}`), &resource); nil != err {
+		t.Fatal(err)
+	}
+	auth := types.Authorization{Resource: resource}
+	response, err := auth.Respond(types.Registration{SigningKey: skey}, 0)
+	if nil != err {
+		t.Fatal(err)
+	}
+	emailResp, err := emailResponding(response)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return emailResp
+}
+
+// digest of the RFC 8823 key authorization tokenPart1 || token2 "." thumbprint
+func expectedResponse(tokenPart1 string) string {
+	digest := sha256.Sum256([]byte(tokenPart1 + testToken + "." + testThumbprint))
+	return utils.Base64UrlEncode(digest[:])
+}
+
+type sentMail struct {
+	from       string
+	recipients []string
+	data       string
+}
+
+// in-process SMTP server accepting a single message (PLAIN auth with
+// user/secret, no STARTTLS)
+type testSMTP struct {
+	listener net.Listener
+	auth     string
+	sent     chan sentMail
+}
+
+func startSMTP(t *testing.T) *testSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	server := &testSMTP{listener: listener, sent: make(chan sentMail, 1)}
+	t.Cleanup(func() { listener.Close() })
+	go server.serve()
+	return server
+}
+
+func (server *testSMTP) serve() {
+	conn, err := server.listener.Accept()
+	if nil != err {
+		return
+	}
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 smtp.example.org ESMTP")
+
+	var mail sentMail
+	for {
+		line, err := text.ReadLine()
+		if nil != err {
+			return
+		}
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO "):
+			text.PrintfLine("250-smtp.example.org")
+			text.PrintfLine("250 AUTH PLAIN")
+		case strings.HasPrefix(command, "AUTH PLAIN "):
+			credentials, _ := base64.StdEncoding.DecodeString(line[len("AUTH PLAIN "):])
+			server.auth = string(credentials)
+			if "\x00user\x00secret" == server.auth {
+				text.PrintfLine("235 2.7.0 Authentication successful")
+			} else {
+				text.PrintfLine("535 5.7.8 Authentication credentials invalid")
+			}
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			mail.from = line[len("MAIL FROM:"):]
+			text.PrintfLine("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			mail.recipients = append(mail.recipients, line[len("RCPT TO:"):])
+			text.PrintfLine("250 OK")
+		case "DATA" == command:
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if nil != err {
+				return
+			}
+			mail.data = string(data)
+			text.PrintfLine("250 OK")
+			server.sent <- mail
+		case "QUIT" == command:
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// answers the SMTP password prompt
+type passwordUI struct {
+	ui.UserInterface
+	password string
+}
+
+func (p passwordUI) PasswordPrompt(prompt string) (string, error) {
+	return p.password, nil
+}
+
+func TestKeyAuthorization(t *testing.T) {
+	emailResp := testResponding(t)
+	if _, err := emailResp.ResponseMessage(); nil == err {
+		t.Fatal("Built a response without challenge email")
+	}
+	if err := emailResp.SetChallengeMessage([]byte(strings.Replace(challengeEmail("part1", "<1@ca.example>"), "type=acme", "type=other", 1))); nil == err {
+		t.Fatal("Accepted challenge email without Auto-Submitted type=acme")
+	}
+	if err := emailResp.SetChallengeMessage([]byte(strings.Replace(challengeEmail("part1", "<1@ca.example>"), testSender, "other@ca.example", 1))); nil == err {
+		t.Fatal("Accepted challenge email from wrong sender")
+	}
+	if err := emailResp.SetChallengeMessage([]byte(challengeEmail("part1", "<1@ca.example>"))); nil != err {
+		t.Fatal(err)
+	}
+
+	message, err := emailResp.ResponseMessage()
+	if nil != err {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(string(message)))
+	if nil != err {
+		t.Fatal(err)
+	}
+	for header, expected := range map[string]string{
+		"From":           "<" + testAddress + ">",
+		"To":             "<" + testSender + ">",
+		"Subject":        "Re: ACME: part1",
+		"In-Reply-To":    "<1@ca.example>",
+		"Auto-Submitted": "auto-replied; type=acme",
+	} {
+		if actual := msg.Header.Get(header); expected != actual {
+			t.Fatalf("Expected %s %#v, got %#v", header, expected, actual)
+		}
+	}
+	body, err := ioutil.ReadAll(msg.Body)
+	if nil != err {
+		t.Fatal(err)
+	}
+	expectedBody := "-----BEGIN ACME RESPONSE-----\r\n" + expectedResponse("part1") + "\r\n-----END ACME RESPONSE-----\r\n"
+	if expectedBody != string(body) {
+		t.Fatalf("Expected body %q, got %q", expectedBody, body)
+	}
+}
+
+// checks the mail sent through the test server answers the challenge
+// email with tokenPart1
+func checkSent(t *testing.T, server *testSMTP, tokenPart1 string, messageID string) {
+	var sent sentMail
+	select {
+	case sent = <-server.sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("No mail sent")
+	}
+	if "\x00user\x00secret" != server.auth {
+		t.Fatalf("Unexpected credentials %q", server.auth)
+	}
+	if "<"+testAddress+">" != sent.from || 1 != len(sent.recipients) || "<"+testSender+">" != sent.recipients[0] {
+		t.Fatalf("Unexpected envelope from %s to %v", sent.from, sent.recipients)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(sent.data))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if messageID != msg.Header.Get("In-Reply-To") {
+		t.Fatalf("Reply to wrong message %s", msg.Header.Get("In-Reply-To"))
+	}
+	if body, _ := ioutil.ReadAll(msg.Body); !strings.Contains(string(body), "\n"+expectedResponse(tokenPart1)+"\n") {
+		t.Fatalf("Unexpected reply body %q", body)
+	}
+}
+
+func testSolver(server *testSMTP) *emailSolver {
+	return &emailSolver{
+		smtpServer: server.listener.Addr().String(),
+		smtpUser:   "user",
+	}
+}
+
+func TestPresentFromMaildir(t *testing.T) {
+	server := startSMTP(t)
+	solver := testSolver(server)
+	// delivery times relative to the start of Present
+	solver.maildir = writeMaildir(t, map[string]string{
+		"new/1.host":     strings.Replace(challengeEmail("unrelated", "<1@ca.example>"), testSender, "spam@example.net", 1),
+		"new/2.host":     challengeEmail("current", "<2@ca.example>"),
+		"cur/3.host:2,S": challengeEmail("stale", "<3@ca.example>"),
+	}, map[string]time.Duration{
+		"new/1.host":     -2 * time.Minute,
+		"new/2.host":     -time.Minute,
+		"cur/3.host:2,S": time.Hour,
+	})
+
+	if err := solver.Present(passwordUI{ui.CLI, "secret"}, testResponding(t)); nil != err {
+		t.Fatal(err)
+	}
+	checkSent(t, server, "current", "<2@ca.example>")
+}
+
+func TestPresentFromMbox(t *testing.T) {
+	server := startSMTP(t)
+	solver := testSolver(server)
+	solver.mbox = filepath.Join(t.TempDir(), "mbox")
+	mbox := strings.Join([]string{
+		mboxSeparator(time.Now().Add(-time.Hour)),
+		challengeEmail("stale", "<1@ca.example>"),
+		mboxSeparator(time.Now().Add(time.Minute)),
+		challengeEmail("current", "<2@ca.example>"),
+	}, "\n")
+	if err := ioutil.WriteFile(solver.mbox, []byte(mbox), 0600); nil != err {
+		t.Fatal(err)
+	}
+
+	if err := solver.Present(passwordUI{ui.CLI, "secret"}, testResponding(t)); nil != err {
+		t.Fatal(err)
+	}
+	checkSent(t, server, "current", "<2@ca.example>")
+}
+
+func TestPresentIgnoresStaleEmail(t *testing.T) {
+	server := startSMTP(t)
+	solver := testSolver(server)
+	solver.maildir = writeMaildir(t, map[string]string{
+		"cur/1.host:2,S": challengeEmail("stale", "<1@ca.example>"),
+	}, map[string]time.Duration{
+		"cur/1.host:2,S": time.Hour,
+	})
+	if err := solver.Present(passwordUI{ui.CLI, "secret"}, testResponding(t)); nil == err || !strings.Contains(err.Error(), "No challenge email") {
+		t.Fatalf("Expected stale challenge email to be ignored, got %v", err)
+	}
+
+	// unless it arrived within -email-max-age
+	solver.maxAge = 2 * time.Hour
+	if err := solver.Present(passwordUI{ui.CLI, "secret"}, testResponding(t)); nil != err {
+		t.Fatal(err)
+	}
+	checkSent(t, server, "stale", "<1@ca.example>")
+}
+
+func TestPresentWithoutChallengeEmail(t *testing.T) {
+	server := startSMTP(t)
+	solver := testSolver(server)
+	solver.maildir = writeMaildir(t, nil, nil)
+	if err := solver.Present(passwordUI{ui.CLI, "secret"}, testResponding(t)); nil == err || !strings.Contains(err.Error(), "No challenge email") {
+		t.Fatalf("Expected missing challenge email error, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package solver_exec
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/solver_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const SolverName = "exec"
+
+// runs user supplied commands to present and clean up challenge responses;
+// the details are passed in ACME_* environment variables
+type execSolver struct {
+	presentCommand string
+	cleanupCommand string
+	timeout        time.Duration
+	// maps challenge uri to the environment passed to the present hook
+	presented map[string][]string
+}
+
+var Solver = &execSolver{
+	presented: make(map[string][]string),
+}
+
+func init() {
+	solver_interface.Register(solver_interface.AnyChallengeType, SolverName, Solver)
+}
+
+func (solver *execSolver) AddFlags(flags *flag.FlagSet) {
+	flags.StringVar(&solver.presentCommand, "hook-present", "", "Shell command presenting a challenge response (details in ACME_* environment variables)")
+	flags.StringVar(&solver.cleanupCommand, "hook-cleanup", "", "Shell command removing a challenge response again")
+	flags.DurationVar(&solver.timeout, "hook-timeout", time.Minute, "Maximum run time of a hook command")
+}
+
+func (solver *execSolver) Configured(domain string) bool {
+	return 0 != len(solver.presentCommand)
+}
+
+func (solver *execSolver) Present(UI ui.UserInterface, response types.ChallengeResponding) error {
+	if 0 == len(solver.presentCommand) {
+		return fmt.Errorf("No present hook configured")
+	}
+	env, err := environment(response)
+	if nil != err {
+		return err
+	}
+	challenge := response.Challenge()
+	// remember before running, the cleanup hook should also undo partial work
+	solver.presented[challenge.GetURI()] = env
+	return solver.run("present", solver.presentCommand, env)
+}
+
+func (solver *execSolver) Verify(UI ui.UserInterface, response types.ChallengeResponding) error {
+	return response.Verify()
+}
+
+func (solver *execSolver) Cleanup(UI ui.UserInterface, response types.ChallengeResponding) error {
+	challenge := response.Challenge()
+	env, ok := solver.presented[challenge.GetURI()]
+	if !ok {
+		return nil
+	}
+	delete(solver.presented, challenge.GetURI())
+	if 0 == len(solver.cleanupCommand) {
+		return nil
+	}
+	return solver.run("cleanup", solver.cleanupCommand, env)
+}
+
+func environment(response types.ChallengeResponding) ([]string, error) {
+	challenge := response.Challenge()
+	env := []string{
+		"ACME_DOMAIN=" + response.DNSIdentifier(),
+		"ACME_CHALLENGE_TYPE=" + challenge.GetType(),
+		"ACME_CHALLENGE_URI=" + challenge.GetURI(),
+	}
+	if tokenImpl, ok := challenge.Implementation().(types.ChallengeTokenImplementation); ok {
+		env = append(env, "ACME_TOKEN="+tokenImpl.GetToken())
+	}
+	if fileResp, ok := response.(types.ChallengeFileResponding); ok {
+		if content, err := fileResp.VerificationFile(); nil != err {
+			return nil, err
+		} else {
+			env = append(env,
+				"ACME_FILE_PATH="+fileResp.WellKnownPath(),
+				"ACME_FILE_CONTENT="+content)
+		}
+	}
+	if sniResp, ok := response.(types.ChallengeSNIResponding); ok {
+		if cert, err := sniResp.Certificate(); nil != err {
+			return nil, err
+		} else {
+			env = append(env,
+				"ACME_SNI_NAME="+sniResp.SNIName(),
+				"ACME_CERTIFICATE="+cert)
+		}
+	}
+	if dnsResp, ok := response.(types.ChallengeDNSResponding); ok {
+		if value, err := dnsResp.RecordValue(); nil != err {
+			return nil, err
+		} else {
+			env = append(env,
+				"ACME_RECORD_NAME="+dnsResp.RecordName(),
+				"ACME_RECORD_VALUE="+value)
+		}
+	}
+	return env, nil
+}
+
+func (solver *execSolver) run(phase string, command string, env []string) error {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Env = append(append(os.Environ(), env...), "ACME_PHASE="+phase)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	setProcessGroup(cmd)
+
+	utils.Infof("Running %s hook: %s", phase, command)
+	if err := cmd.Start(); nil != err {
+		return fmt.Errorf("Couldn't start %s hook: %s", phase, err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var timeout <-chan time.Time
+	if solver.timeout > 0 {
+		timer := time.NewTimer(solver.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case err := <-done:
+		if nil != err {
+			return fmt.Errorf("%s hook failed: %s\n%s", phase, err, strings.TrimSpace(output.String()))
+		}
+		utils.Infof("%s hook output:\n%s", phase, output.String())
+		return nil
+	case <-timeout:
+		// kill the commands started by the shell too; don't wait for the
+		// process, others might still keep the output open
+		if err := killProcessGroup(cmd); nil != err {
+			utils.Warningf("Couldn't kill %s hook: %s", phase, err)
+		}
+		return fmt.Errorf("%s hook didn't finish within %s and was killed", phase, solver.timeout)
+	}
+}
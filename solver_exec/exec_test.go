@@ -0,0 +1,74 @@
+package solver_exec
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	solver := &execSolver{timeout: 10 * time.Second}
+	if err := solver.run("present", `test "$ACME_PHASE $ACME_DOMAIN" = "present example.com"`, []string{"ACME_DOMAIN=example.com"}); nil != err {
+		t.Fatal(err)
+	}
+}
+
+func TestRunExitStatus(t *testing.T) {
+	solver := &execSolver{timeout: 10 * time.Second}
+	err := solver.run("cleanup", "echo something went wrong >&2; exit 3", nil)
+	if nil == err {
+		t.Fatal("Failing hook reported success")
+	}
+	for _, expected := range []string{"cleanup hook failed", "exit status 3", "something went wrong"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected %#v in error, got %s", expected, err)
+		}
+	}
+}
+
+// whether the process is gone (or only waits to be reaped)
+func processGone(pid int) bool {
+	stat, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if nil != err {
+		return true
+	}
+	// state follows the command name in parentheses
+	fields := strings.Fields(string(stat[strings.LastIndex(string(stat), ")")+1:]))
+	return 0 != len(fields) && "Z" == fields[0]
+}
+
+func TestRunTimeout(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); nil != err {
+		t.Skip("Needs /proc to check the hook was killed")
+	}
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	solver := &execSolver{timeout: 500 * time.Millisecond}
+
+	start := time.Now()
+	// the background command keeps the output open after the shell is gone
+	err := solver.run("present", "sleep 30 & echo $! > "+pidFile+"; wait", nil)
+	if nil == err || !strings.Contains(err.Error(), "was killed") {
+		t.Fatalf("Expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("Hook timeout took %s", elapsed)
+	}
+
+	content, err := ioutil.ReadFile(pidFile)
+	if nil != err {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if nil != err {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); !processGone(pid); time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Command %d started by the hook is still running", pid)
+		}
+	}
+}
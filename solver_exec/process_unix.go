@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package solver_exec
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// hooks run in their own process group, so the timeout can kill the
+// commands started by the shell too
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	// the process group id is the pid of the shell (Setpgid)
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,13 @@
+package solver_exec
+
+import (
+	"os/exec"
+)
+
+// no process groups; only the shell itself is killed
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,29 @@
+package solver_interface
+
+import (
+	"flag"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+)
+
+// A Solver makes challenge responses available to the ACME server.
+//
+// Present is called after the response was initialized, Verify before the
+// response is sent to the server, and Cleanup once the server is done with
+// the challenge (or the response was abandoned); Cleanup must be safe to
+// call even if Present failed.
+type Solver interface {
+	// whether the solver is set up to handle challenges for the domain
+	Configured(domain string) bool
+
+	Present(UI ui.UserInterface, response types.ChallengeResponding) error
+	Verify(UI ui.UserInterface, response types.ChallengeResponding) error
+	Cleanup(UI ui.UserInterface, response types.ChallengeResponding) error
+}
+
+// solvers which need configuration from the command line
+type FlagSolver interface {
+	Solver
+
+	AddFlags(flags *flag.FlagSet)
+}
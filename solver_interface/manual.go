@@ -0,0 +1,29 @@
+package solver_interface
+
+import (
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+)
+
+const ManualSolverName = "manual"
+
+// shows the instructions of the challenge response to the operator
+var ManualSolver Solver = manualSolver{}
+
+type manualSolver struct{}
+
+func (manualSolver) Configured(domain string) bool {
+	return true
+}
+
+func (manualSolver) Present(UI ui.UserInterface, response types.ChallengeResponding) error {
+	return response.ShowInstructions(UI)
+}
+
+func (manualSolver) Verify(UI ui.UserInterface, response types.ChallengeResponding) error {
+	return response.Verify()
+}
+
+func (manualSolver) Cleanup(UI ui.UserInterface, response types.ChallengeResponding) error {
+	return nil
+}
@@ -0,0 +1,138 @@
+package solver_interface
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// register a solver with this challenge type to handle all challenge types
+const AnyChallengeType = "*"
+
+type registeredSolver struct {
+	name   string
+	solver Solver
+}
+
+// maps challenge type to solvers in registration order
+var solvers = make(map[string][]registeredSolver)
+
+// solvers for a challenge type, followed by the solvers for all types
+func solversFor(challengeType string) []registeredSolver {
+	if AnyChallengeType == challengeType {
+		return solvers[AnyChallengeType]
+	}
+	return append(append([]registeredSolver{}, solvers[challengeType]...), solvers[AnyChallengeType]...)
+}
+
+// Register makes a solver available for a challenge type (or all of them
+// with AnyChallengeType); the same solver can be registered for multiple
+// types. Should be called from init() functions; panics if the name is
+// already taken for the type.
+func Register(challengeType string, name string, solver Solver) {
+	if nil == solver {
+		panic("Register solver is nil")
+	}
+	if ManualSolverName == name || nil != Lookup(challengeType, name) || nil != Lookup(AnyChallengeType, name) {
+		panic(fmt.Sprintf("Solver %#v already registered for challenge type %#v", name, challengeType))
+	}
+	solvers[challengeType] = append(solvers[challengeType], registeredSolver{
+		name:   name,
+		solver: solver,
+	})
+}
+
+// find solver by name; the manual solver is available for every challenge
+// type
+func Lookup(challengeType string, name string) Solver {
+	if ManualSolverName == name {
+		return ManualSolver
+	}
+	for _, entry := range solversFor(challengeType) {
+		if entry.name == name {
+			return entry.solver
+		}
+	}
+	return nil
+}
+
+// names of all solvers for a challenge type, including the manual solver
+func Names(challengeType string) []string {
+	var names []string
+	for _, entry := range solversFor(challengeType) {
+		names = append(names, entry.name)
+	}
+	return append(names, ManualSolverName)
+}
+
+// first registered solver configured for the domain, falling back to the
+// manual solver
+func Select(challengeType string, domain string) (string, Solver) {
+	for _, entry := range solversFor(challengeType) {
+		if entry.solver.Configured(domain) {
+			return entry.name, entry.solver
+		}
+	}
+	return ManualSolverName, ManualSolver
+}
+
+// AddFlags registers the flags of all registered solvers; call it before
+// parsing the flags (not in init(), as solvers from other packages might
+// not be registered yet)
+func AddFlags(flags *flag.FlagSet) {
+	seen := make(map[Solver]bool)
+	var challengeTypes []string
+	for challengeType, _ := range solvers {
+		challengeTypes = append(challengeTypes, challengeType)
+	}
+	sort.Strings(challengeTypes)
+	for _, challengeType := range challengeTypes {
+		for _, entry := range solvers[challengeType] {
+			if seen[entry.solver] {
+				continue
+			}
+			seen[entry.solver] = true
+			if flagSolver, ok := entry.solver.(FlagSolver); ok {
+				flagSolver.AddFlags(flags)
+			}
+		}
+	}
+}
+
+// explicit solver selection per challenge type (maps challenge type to
+// solver name); usable as flag with values "TYPE=NAME"
+type Choices map[string]string
+
+func (choices *Choices) String() string {
+	var entries []string
+	for challengeType, name := range *choices {
+		entries = append(entries, challengeType+"="+name)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+func (choices *Choices) Set(v string) error {
+	ndx := strings.Index(v, "=")
+	if ndx <= 0 || ndx == len(v)-1 {
+		return fmt.Errorf("Expected TYPE=NAME, got %#v", v)
+	}
+	if nil == *choices {
+		*choices = make(Choices)
+	}
+	(*choices)[v[:ndx]] = v[ndx+1:]
+	return nil
+}
+
+// solver for a challenge type: the explicitly chosen one, or Select()
+func (choices Choices) Solver(challengeType string, domain string) (string, Solver, error) {
+	if name, ok := choices[challengeType]; ok {
+		if solver := Lookup(challengeType, name); nil != solver {
+			return name, solver, nil
+		}
+		return "", nil, fmt.Errorf("Unknown solver %#v for challenge type %#v, available: %v", name, challengeType, Names(challengeType))
+	}
+	name, solver := Select(challengeType, domain)
+	return name, solver, nil
+}
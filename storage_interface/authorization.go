@@ -10,5 +10,10 @@ type StorageAuthorization interface {
 	Authorization() *types.Authorization
 	SetAuthorization(authorization types.Authorization) error
 
+	// inserts new attempts (ID 0) and updates existing ones
+	SaveChallengeAttempt(attempt *ChallengeAttempt) error
+	// sets status and error of all unfinished attempts for the challenge
+	FinishChallengeAttempts(challengeURI string, status string, errorText string) error
+
 	Delete() error
 }
@@ -0,0 +1,27 @@
+package storage_interface
+
+import (
+	"time"
+)
+
+// a single attempt to solve a challenge, kept for auditing
+type ChallengeAttempt struct {
+	// 0 for attempts not stored yet
+	ID                    int64
+	DNSIdentifier         string
+	AuthorizationLocation string
+	ChallengeURI          string
+	ChallengeType         string
+	Started               time.Time
+	Solver                string
+	// response payload sent to the server (JSON)
+	Parameters string
+	// outcome of presenting and verifying the response locally ("ok" or the
+	// error)
+	LocalResult string
+	// final status from the server, empty while unknown
+	Status string
+	// server problem or the error sending the response
+	Error    string
+	Finished *time.Time
+}
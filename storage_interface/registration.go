@@ -18,6 +18,9 @@ type AuthorizationInfos map[string][]AuthorizationInfo
 type CertificateInfo struct {
 	Location   string
 	LinkIssuer string
+	KeySpec    string
+	Group      string
+	ReplacedBy string
 }
 
 type StorageRegistrationComponent interface {
@@ -39,6 +42,8 @@ type StorageRegistration interface {
 	LoadAuthorizationByURL(authorizationURL string) (StorageAuthorization, error)
 	// finds only newest not expired, valid, processing or pending authorization
 	LoadAuthorizationByDNS(dnsIdentifier string) (StorageAuthorization, error)
+	// newest first
+	ChallengeAttempts(dnsIdentifier string) ([]ChallengeAttempt, error)
 
 	NewCertificate(cert types.Certificate) (StorageCertificate, error)
 	CertificateInfos() ([]CertificateInfo, error)
@@ -40,6 +40,10 @@ func (sauth *sqlStorageAuthorization) SetAuthorization(authorization types.Autho
 	return nil
 }
 
+// in challenge_attempt.go:
+// func (sauth *sqlStorageAuthorization) SaveChallengeAttempt(attempt *i.ChallengeAttempt) error
+// func (sauth *sqlStorageAuthorization) FinishChallengeAttempts(challengeURI string, status string, errorText string) error
+
 func (sauth *sqlStorageAuthorization) Delete() error {
 	if _, err := sauth.storage.db.Exec(`DELETE FROM authorization WHERE id = $1`, sauth.id); nil != err {
 		return err
@@ -66,7 +70,7 @@ func (sreg *sqlStorageRegistration) NewAuthorization(auth types.Authorization) (
 	_, err = sreg.storage.db.Exec(
 		`INSERT INTO authorization (registration_id, dnsName, location, status, expires, jsonPem) VALUES
 			($1, $2, $3, $4, $5, $6)`,
-		sreg.id, string(auth.Resource.DNSIdentifier), auth.Location,
+		sreg.id, auth.Resource.Identifier.Value, auth.Location,
 		string(auth.Resource.Status), auth.Resource.Expires, export.JsonPem)
 	if nil != err {
 		return nil, err
@@ -283,7 +287,7 @@ func (storage *sqlStorage) saveAuthorization(id int64, registration_id int64, au
 			status = $4, expires = $5, jsonPem = $6
 		WHERE id = $7`,
 		registration_id,
-		string(auth.Resource.DNSIdentifier), auth.Location,
+		auth.Resource.Identifier.Value, auth.Location,
 		string(auth.Resource.Status), auth.Resource.Expires, export.JsonPem,
 		id)
 
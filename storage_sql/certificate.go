@@ -62,10 +62,12 @@ func (sreg *sqlStorageRegistration) NewCertificate(cert types.Certificate) (i.St
 	}
 
 	_, err = sreg.storage.db.Exec(
-		`INSERT INTO certificate (registration_id, location, linkIssuer, certificatePem, privateKeyPem) VALUES
-			($1, $2, $3, $4, $5)`,
+		`INSERT INTO certificate (registration_id, location, linkIssuer, certificatePem, privateKeyPem, keySpec, certGroup,
+			keyPolicy, keyUses, replacedBy, replaced) VALUES
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
 		sreg.id, cert.Location, cert.LinkIssuer,
-		export.CertificatePem, export.PrivateKeyPem)
+		export.CertificatePem, export.PrivateKeyPem, export.KeySpec, export.Group,
+		export.KeyPolicy, export.KeyUses, export.ReplacedBy, export.Replaced)
 	if nil != err {
 		return nil, err
 	}
@@ -75,7 +77,7 @@ func (sreg *sqlStorageRegistration) NewCertificate(cert types.Certificate) (i.St
 
 func (sreg *sqlStorageRegistration) CertificateInfos() ([]i.CertificateInfo, error) {
 	rows, err := sreg.storage.db.Query(
-		`SELECT location, linkIssuer FROM certificate WHERE registration_id = $1`,
+		`SELECT location, linkIssuer, keySpec, certGroup, replacedBy FROM certificate WHERE registration_id = $1`,
 		sreg.id)
 	if nil != err {
 		return nil, err
@@ -86,7 +88,8 @@ func (sreg *sqlStorageRegistration) CertificateInfos() ([]i.CertificateInfo, err
 
 func (sreg *sqlStorageRegistration) Certificates() ([]i.StorageCertificate, error) {
 	if rows, err := sreg.storage.db.Query(
-		`SELECT id, registration_id, location, linkIssuer, certificatePem, privateKeyPem
+		`SELECT id, registration_id, location, linkIssuer, certificatePem, privateKeyPem, keySpec, certGroup,
+			keyPolicy, keyUses, replacedBy, strftime('%Y-%m-%dT%H:%M:%fZ', replaced)
 		FROM certificate
 		WHERE registration_id = $1`, sreg.id); nil != err {
 		return nil, err
@@ -107,7 +110,8 @@ func (sreg *sqlStorageRegistration) Certificates() ([]i.StorageCertificate, erro
 
 func (sreg *sqlStorageRegistration) LoadCertificate(location string) (i.StorageCertificate, error) {
 	if rows, err := sreg.storage.db.Query(
-		`SELECT id, registration_id, location, linkIssuer, certificatePem, privateKeyPem
+		`SELECT id, registration_id, location, linkIssuer, certificatePem, privateKeyPem, keySpec, certGroup,
+			keyPolicy, keyUses, replacedBy, strftime('%Y-%m-%dT%H:%M:%fZ', replaced)
 		FROM certificate
 		WHERE registration_id = $1 AND location = $2`, sreg.id, location); nil != err {
 		return nil, err
@@ -142,10 +146,34 @@ func (storage *sqlStorage) checkCertificateTable() error {
 			linkIssuer TEXT NOT NULL,
 			certificatePem BLOB NOT NULL,
 			privateKeyPem BLOB,
+			keySpec TEXT NOT NULL DEFAULT '',
+			certGroup TEXT NOT NULL DEFAULT '',
+			keyPolicy TEXT NOT NULL DEFAULT '',
+			keyUses INT NOT NULL DEFAULT 0,
+			replacedBy TEXT NOT NULL DEFAULT '',
+			replaced TEXT,
 			FOREIGN KEY(registration_id) REFERENCES registration(id),
 			UNIQUE (registration_id, location)
 		)`)
-	return err
+	if nil != err {
+		return err
+	}
+	if err := storage.addColumn("certificate", "keySpec", "TEXT NOT NULL DEFAULT ''"); nil != err {
+		return err
+	}
+	if err := storage.addColumn("certificate", "certGroup", "TEXT NOT NULL DEFAULT ''"); nil != err {
+		return err
+	}
+	if err := storage.addColumn("certificate", "keyPolicy", "TEXT NOT NULL DEFAULT ''"); nil != err {
+		return err
+	}
+	if err := storage.addColumn("certificate", "keyUses", "INT NOT NULL DEFAULT 0"); nil != err {
+		return err
+	}
+	if err := storage.addColumn("certificate", "replacedBy", "TEXT NOT NULL DEFAULT ''"); nil != err {
+		return err
+	}
+	return storage.addColumn("certificate", "replaced", "TEXT")
 }
 
 func certInfoListFromRows(rows *sql.Rows) ([]i.CertificateInfo, error) {
@@ -153,12 +181,16 @@ func certInfoListFromRows(rows *sql.Rows) ([]i.CertificateInfo, error) {
 	for rows.Next() {
 		var location string
 		var linkIssuer string
-		if err := rows.Scan(&location, &linkIssuer); nil != err {
+		var keySpec, group, replacedBy string
+		if err := rows.Scan(&location, &linkIssuer, &keySpec, &group, &replacedBy); nil != err {
 			return nil, err
 		}
 		certs = append(certs, i.CertificateInfo{
 			Location:   location,
 			LinkIssuer: linkIssuer,
+			KeySpec:    keySpec,
+			Group:      group,
+			ReplacedBy: replacedBy,
 		})
 	}
 	return certs, nil
@@ -170,10 +202,16 @@ func (storage *sqlStorage) loadCertificateFromSql(rows *sql.Rows, sregHint *sqlS
 	}
 
 	var id, registration_id int64
-	var location, linkIssuer string
+	var location, linkIssuer, keySpec, group, keyPolicy, replacedBy string
+	var keyUses int
 	var certificatePem []byte
-	var privateKeyPem sql.NullString
-	if err := rows.Scan(&id, &registration_id, &location, &linkIssuer, &certificatePem, &privateKeyPem); nil != err {
+	var privateKeyPem, replacedString sql.NullString
+	if err := rows.Scan(&id, &registration_id, &location, &linkIssuer, &certificatePem, &privateKeyPem, &keySpec, &group,
+		&keyPolicy, &keyUses, &replacedBy, &replacedString); nil != err {
+		return nil, err
+	}
+	replaced, err := timeFromSql(replacedString)
+	if nil != err {
 		return nil, err
 	}
 
@@ -194,6 +232,12 @@ func (storage *sqlStorage) loadCertificateFromSql(rows *sql.Rows, sregHint *sqlS
 			PrivateKeyPem:  privKeyPem,
 			Location:       location,
 			LinkIssuer:     linkIssuer,
+			KeySpec:        keySpec,
+			Group:          group,
+			KeyPolicy:      keyPolicy,
+			KeyUses:        keyUses,
+			ReplacedBy:     replacedBy,
+			Replaced:       replaced,
 		}, storage.passwordPrompt); nil != err {
 		return nil, err
 	}
@@ -209,10 +253,12 @@ func (storage *sqlStorage) saveCertificate(id int64, registration_id int64, cert
 
 	_, err = storage.db.Exec(
 		`UPDATE certificate SET
-			registration_id = $1, location = $2, linkIssuer = $3, certificatePem = $4, privateKeyPem = $5
-		WHERE id = $6`,
+			registration_id = $1, location = $2, linkIssuer = $3, certificatePem = $4, privateKeyPem = $5, keySpec = $6, certGroup = $7,
+			keyPolicy = $8, keyUses = $9, replacedBy = $10, replaced = $11
+		WHERE id = $12`,
 		registration_id, cert.Location, cert.LinkIssuer,
-		export.CertificatePem, export.PrivateKeyPem, id)
+		export.CertificatePem, export.PrivateKeyPem, export.KeySpec, export.Group,
+		export.KeyPolicy, export.KeyUses, export.ReplacedBy, export.Replaced, id)
 
 	return err
 }
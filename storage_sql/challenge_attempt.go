@@ -0,0 +1,121 @@
+package storage_sql
+
+import (
+	"database/sql"
+	"fmt"
+	i "github.com/stbuehler/go-acme-client/storage_interface"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// implementations for i.StorageAuthorization
+// --------------------------------------------------------------------
+
+func (sauth *sqlStorageAuthorization) SaveChallengeAttempt(attempt *i.ChallengeAttempt) error {
+	if 0 == attempt.ID {
+		result, err := sauth.storage.db.Exec(
+			`INSERT INTO challenge_attempt (registration_id, dnsName, authorizationLocation, challengeURI, challengeType,
+				started, solver, parameters, localResult, status, error, finished) VALUES
+				($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			sauth.registration.id, attempt.DNSIdentifier, attempt.AuthorizationLocation, attempt.ChallengeURI, attempt.ChallengeType,
+			attempt.Started, attempt.Solver, attempt.Parameters, attempt.LocalResult, attempt.Status, attempt.Error, attempt.Finished)
+		if nil != err {
+			return err
+		}
+		if attempt.ID, err = result.LastInsertId(); nil != err {
+			return err
+		}
+		return nil
+	}
+
+	_, err := sauth.storage.db.Exec(
+		`UPDATE challenge_attempt SET
+			solver = $1, parameters = $2, localResult = $3, status = $4, error = $5, finished = $6
+		WHERE id = $7 AND registration_id = $8`,
+		attempt.Solver, attempt.Parameters, attempt.LocalResult, attempt.Status, attempt.Error, attempt.Finished,
+		attempt.ID, sauth.registration.id)
+	return err
+}
+
+func (sauth *sqlStorageAuthorization) FinishChallengeAttempts(challengeURI string, status string, errorText string) error {
+	_, err := sauth.storage.db.Exec(
+		`UPDATE challenge_attempt SET status = $1, error = $2, finished = $3
+		WHERE registration_id = $4 AND challengeURI = $5 AND finished IS NULL`,
+		status, errorText, time.Now(), sauth.registration.id, challengeURI)
+	return err
+}
+
+// --------------------------------------------------------------------
+// end [implementations for i.StorageAuthorization]
+// --------------------------------------------------------------------
+
+// --------------------------------------------------------------------
+// implementations for i.StorageRegistration
+// --------------------------------------------------------------------
+
+func (sreg *sqlStorageRegistration) ChallengeAttempts(dnsIdentifier string) ([]i.ChallengeAttempt, error) {
+	rows, err := sreg.storage.db.Query(
+		`SELECT id, dnsName, authorizationLocation, challengeURI, challengeType,
+			strftime('%Y-%m-%dT%H:%M:%fZ', started), solver, parameters, localResult, status, error,
+			strftime('%Y-%m-%dT%H:%M:%fZ', finished)
+		FROM challenge_attempt WHERE registration_id = $1 AND dnsName = $2 ORDER BY id DESC`,
+		sreg.id, dnsIdentifier)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+	return challengeAttemptsFromRows(rows)
+}
+
+// --------------------------------------------------------------------
+// end [implementations for i.StorageRegistration]
+// --------------------------------------------------------------------
+
+func (storage *sqlStorage) checkChallengeAttemptTable() error {
+	_, err := storage.db.Exec(
+		`CREATE TABLE IF NOT EXISTS challenge_attempt (
+			id INTEGER PRIMARY KEY,
+			registration_id INT NOT NULL,
+			dnsName TEXT NOT NULL,
+			authorizationLocation TEXT NOT NULL,
+			challengeURI TEXT NOT NULL,
+			challengeType TEXT NOT NULL,
+			started TEXT NOT NULL,
+			solver TEXT NOT NULL,
+			parameters TEXT NOT NULL,
+			localResult TEXT NOT NULL,
+			status TEXT NOT NULL,
+			error TEXT NOT NULL,
+			finished TEXT,
+			FOREIGN KEY(registration_id) REFERENCES registration(id)
+		)`)
+	return err
+}
+
+func challengeAttemptsFromRows(rows *sql.Rows) ([]i.ChallengeAttempt, error) {
+	var attempts []i.ChallengeAttempt
+	for rows.Next() {
+		var attempt i.ChallengeAttempt
+		var startedString, finishedString sql.NullString
+		if err := rows.Scan(&attempt.ID, &attempt.DNSIdentifier, &attempt.AuthorizationLocation,
+			&attempt.ChallengeURI, &attempt.ChallengeType, &startedString, &attempt.Solver,
+			&attempt.Parameters, &attempt.LocalResult, &attempt.Status, &attempt.Error,
+			&finishedString); nil != err {
+			return nil, err
+		}
+		if started, err := timeFromSql(startedString); nil != err {
+			return nil, err
+		} else if nil == started {
+			return nil, fmt.Errorf("Challenge attempt %d without start time", attempt.ID)
+		} else {
+			attempt.Started = *started
+		}
+		finished, err := timeFromSql(finishedString)
+		if nil != err {
+			return nil, err
+		}
+		attempt.Finished = finished
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
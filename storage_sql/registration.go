@@ -48,6 +48,9 @@ func (sreg *sqlStorageRegistration) SetRegistration(registration types.Registrat
 // func (sreg *sqlStorageRegistration) LoadAuthorizationByURL(authorizationURL string) (i.StorageAuthorization, error)
 // func (sreg *sqlStorageRegistration) LoadAuthorizationByDNS(dnsIdentifier string) (i.StorageAuthorization, error)
 
+// in challenge_attempt.go
+// func (sreg *sqlStorageRegistration) ChallengeAttempts(dnsIdentifier string) ([]i.ChallengeAttempt, error)
+
 // in certificate.go
 // func (sreg *sqlStorageRegistration) NewCertificate(cert types.Certificate) (i.StorageCertificate, error)
 // func (sreg *sqlStorageRegistration) CertificateInfos() ([]CertificateInfo, error)
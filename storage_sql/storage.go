@@ -38,6 +38,29 @@ type sqlStorage struct {
 	lastPassword   func() string
 }
 
+// adds a column to a table created by an older version
+func (storage *sqlStorage) addColumn(table string, column string, definition string) error {
+	rows, err := storage.db.Query("PRAGMA table_info(" + table + ")")
+	if nil != err {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); nil != err {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+	_, err = storage.db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err
+}
+
 func OpenSQLite(UI ui.UserInterface, filename string) (i.Storage, error) {
 	db, err := sql.Open("sqlite3", filename)
 	if nil != err {
@@ -65,6 +88,9 @@ func Open(UI ui.UserInterface, db *sql.DB) (i.Storage, error) {
 	if err := storage.checkCertificateTable(); nil != err {
 		return nil, err
 	}
+	if err := storage.checkChallengeAttemptTable(); nil != err {
+		return nil, err
+	}
 
 	return storage, nil
 }
@@ -5,12 +5,12 @@ import (
 )
 
 type AuthorizationResource struct {
-	Resource      ResourceAuthorizationTag `json:"resource"`
-	DNSIdentifier DNSIdentifier            `json:"identifier,omitempty"`
-	Status        AuthorizationStatus      `json:"status,omitempty"`
-	Challenges    []Challenge              `json:"challenges,omitempty"`
-	Combinations  [][]int                  `json:"combinations,omitempty"`
-	Expires       *time.Time               `json:"expires,omitempty"`
+	Resource     ResourceAuthorizationTag `json:"resource"`
+	Identifier   Identifier               `json:"identifier,omitempty"`
+	Status       AuthorizationStatus      `json:"status,omitempty"`
+	Challenges   []Challenge              `json:"challenges,omitempty"`
+	Combinations [][]int                  `json:"combinations,omitempty"`
+	Expires      *time.Time               `json:"expires,omitempty"`
 }
 
 type Authorization struct {
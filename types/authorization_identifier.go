@@ -0,0 +1,51 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const IdentifierDNS = "dns"
+const IdentifierEmail = "email"
+
+type Identifier struct {
+	Type  string
+	Value string
+}
+
+// email identifier for values containing an "@", otherwise dns
+func NewIdentifier(value string) Identifier {
+	if strings.Contains(value, "@") {
+		return Identifier{Type: IdentifierEmail, Value: value}
+	}
+	return Identifier{Type: IdentifierDNS, Value: value}
+}
+
+func (id Identifier) String() string {
+	return id.Value
+}
+
+type rawAuthorizationIdentifier struct {
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+func (id Identifier) MarshalJSON() (data []byte, err error) {
+	return json.Marshal(rawAuthorizationIdentifier{
+		Type:  id.Type,
+		Value: id.Value,
+	})
+}
+
+func (id *Identifier) UnmarshalJSON(data []byte) error {
+	var rawId rawAuthorizationIdentifier
+	if err := json.Unmarshal(data, &rawId); nil != err {
+		return err
+	}
+	if rawId.Type != IdentifierDNS && rawId.Type != IdentifierEmail {
+		return fmt.Errorf("Unknown identifier.type %s, expected \"dns\" or \"email\"", rawId.Type)
+	}
+	*id = Identifier{Type: rawId.Type, Value: rawId.Value}
+	return nil
+}
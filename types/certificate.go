@@ -2,6 +2,9 @@ package types
 
 import (
 	"encoding/pem"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/utils"
+	"time"
 )
 
 type Certificate struct {
@@ -9,4 +12,68 @@ type Certificate struct {
 	PrivateKey  *pem.Block
 	Location    string
 	LinkIssuer  string
+	// how the private key was generated (empty if unknown); used to
+	// generate the key for renewals
+	KeySpec utils.KeySpec
+	// certificates issued together for the same names (e.g. RSA and
+	// ECDSA) share the location of the first one; empty for single
+	// certificates
+	Group string
+	// what to do with the private key when reissuing the certificate
+	KeyPolicy KeyPolicy
+	// number of certificates issued with the private key up to this one
+	// (0 if unknown)
+	KeyUses int
+	// location of the certificate this one got replaced with; the private
+	// key is kept until the grace period after Replaced is over
+	ReplacedBy string
+	Replaced   *time.Time
+}
+
+// the backend name and reference if only a reference to the private key
+// is stored
+func (cert Certificate) KeyReference() (backend string, reference string, ok bool) {
+	if nil == cert.PrivateKey || pemTypeAcmeKeyReference != cert.PrivateKey.Type {
+		return "", "", false
+	}
+	ekey, err := decodeExternalKey(*cert.PrivateKey)
+	if nil != err {
+		return "", "", false
+	}
+	return ekey.backend, ekey.reference, true
+}
+
+// load the private key (from the key backend for references); nil if
+// there is no private key
+func (cert Certificate) LoadPrivateKey() (interface{}, error) {
+	if nil == cert.PrivateKey {
+		return nil, nil
+	} else if pemTypeAcmeKeyReference == cert.PrivateKey.Type {
+		if ekey, err := decodeExternalKey(*cert.PrivateKey); nil != err {
+			return nil, err
+		} else if signer, err := ekey.getSigner(); nil != err {
+			return nil, err
+		} else {
+			return signer, nil
+		}
+	} else if privateKey, err := utils.DecodePrivateKey(*cert.PrivateKey); nil != err {
+		return nil, fmt.Errorf("Couldn't decode private key of %s: %s", cert.Location, err)
+	} else {
+		return privateKey, nil
+	}
+}
+
+// the private key (unencrypted) in the given format; nil if there is no
+// private key, references to keys in key backends are always PEM
+func (cert Certificate) ExportPrivateKey(format utils.KeyFormat) ([]byte, error) {
+	if nil == cert.PrivateKey {
+		return nil, nil
+	} else if utils.KeyFormatPEM == format || pemTypeAcmeKeyReference == cert.PrivateKey.Type {
+		return pem.EncodeToMemory(cert.PrivateKey), nil
+	}
+	privateKey, err := utils.DecodePrivateKey(*cert.PrivateKey)
+	if nil != err {
+		return nil, err
+	}
+	return utils.ExportPrivateKey(privateKey, format, "")
 }
@@ -6,6 +6,7 @@ import (
 )
 
 type ChallengeResponding interface {
+	DNSIdentifier() string
 	ResetResponse() error
 	InitializeResponse(UI ui.UserInterface) error
 	ShowInstructions(UI ui.UserInterface) error
@@ -16,30 +17,113 @@ type ChallengeResponding interface {
 	Registration() *Registration
 }
 
+// challenges which are satisfied by serving a static file below the
+// document root of the domain
+type ChallengeFileResponding interface {
+	ChallengeResponding
+
+	// path relative to the document root, without leading slash
+	WellKnownPath() string
+	// exact content to serve (without trailing newline)
+	VerificationFile() (string, error)
+}
+
+// challenges which are satisfied by presenting a certificate for a special
+// SNI name on port 443
+type ChallengeSNIResponding interface {
+	ChallengeResponding
+
+	SNIName() string
+	// PEM encoded (self-signed) certificate and private key for the SNI name
+	Certificate() (string, error)
+}
+
+// challenges which are satisfied by a TXT record
+type ChallengeDNSResponding interface {
+	ChallengeResponding
+
+	// fully qualified name (with trailing dot)
+	RecordName() string
+	RecordValue() (string, error)
+}
+
+// challenges which are satisfied by replying to an email from the server
+type ChallengeEmailResponding interface {
+	ChallengeResponding
+
+	// address the challenge email comes from and the reply goes to
+	ChallengeSender() string
+	SetChallengeMessage(message []byte) error
+	PromptChallengeMessage(UI ui.UserInterface) error
+	// RFC 5322 message with CRLF line endings
+	ResponseMessage() ([]byte, error)
+}
+
+// challenges carrying a token chosen by the server
+type ChallengeTokenImplementation interface {
+	GetToken() string
+}
+
 type ChallengeImplementation interface {
 	GetType() string
 	GetStatus() string
 	GetValidated() string
 	GetURI() string
+	// nil unless the validation failed
+	GetError() *Problem
+	GetValidationRecords() []ValidationRecord
 
-	initializeResponse(registration *Registration, authorization *Authorization) (ChallengeResponding, error)
+	// returns nil if responding isn't supported
+	Respond(registration *Registration, authorization *Authorization) (ChallengeResponding, error)
 }
 
 type Challenge struct {
 	chImpl ChallengeImplementation
 }
 
-type rawChallengeBasic struct {
+// common challenge fields; embed in challenge implementations
+type ChallengeBasic struct {
 	Type      string `json:"type,omitempty"`
 	Status    string `json:"status,omitempty"`
 	Validated string `json:"validated,omitempty"`
 	URI       string `json:"uri,omitempty"`
+
+	Error            *Problem           `json:"error,omitempty"`
+	ValidationRecord []ValidationRecord `json:"validationRecord,omitempty"`
+}
+
+func (basic *ChallengeBasic) GetType() string {
+	return basic.Type
+}
+
+func (basic *ChallengeBasic) GetStatus() string {
+	return basic.Status
+}
+
+func (basic *ChallengeBasic) GetValidated() string {
+	return basic.Validated
+}
+
+func (basic *ChallengeBasic) GetURI() string {
+	return basic.URI
+}
+
+func (basic *ChallengeBasic) GetError() *Problem {
+	return basic.Error
+}
+
+func (basic *ChallengeBasic) GetValidationRecords() []ValidationRecord {
+	return basic.ValidationRecord
+}
+
+func MakeChallenge(chImpl ChallengeImplementation) Challenge {
+	return Challenge{chImpl: chImpl}
 }
 
 func (authorization *Authorization) Respond(registration Registration, challengeIndex int) (ChallengeResponding, error) {
 	challenge := &authorization.Resource.Challenges[challengeIndex]
 
-	return challenge.chImpl.initializeResponse(&registration, authorization)
+	return challenge.chImpl.Respond(&registration, authorization)
 }
 
 func (challenge *Challenge) UnmarshalJSON(data []byte) error {
@@ -51,12 +135,8 @@ func (challenge *Challenge) UnmarshalJSON(data []byte) error {
 	}
 
 	var newC ChallengeImplementation = &unknownChallenge{}
-
-	switch jsonType.Type {
-	case simpleHttpIdentifier:
-		newC = &challengeSimpleHttp{}
-	case dvsniIdentifier:
-		newC = &challengeDVSNI{}
+	if challengeType, ok := challengeTypes[jsonType.Type]; ok {
+		newC = challengeType.NewChallenge()
 	}
 
 	if err := json.Unmarshal(data, newC); nil != err {
@@ -70,6 +150,10 @@ func (challenge *Challenge) MarshalJSON() (data []byte, err error) {
 	return json.Marshal(challenge.chImpl)
 }
 
+func (challenge *Challenge) Implementation() ChallengeImplementation {
+	return challenge.chImpl
+}
+
 func (challenge *Challenge) GetType() string {
 	return challenge.chImpl.GetType()
 }
@@ -85,3 +169,11 @@ func (challenge *Challenge) GetValidated() string {
 func (challenge *Challenge) GetURI() string {
 	return challenge.chImpl.GetURI()
 }
+
+func (challenge *Challenge) GetError() *Problem {
+	return challenge.chImpl.GetError()
+}
+
+func (challenge *Challenge) GetValidationRecords() []ValidationRecord {
+	return challenge.chImpl.GetValidationRecords()
+}
@@ -2,7 +2,6 @@ package types
 
 import (
 	"encoding/json"
-	"fmt"
 )
 
 type ChallengeDataImplementation interface {
@@ -17,6 +16,15 @@ type rawChallengeDataBasic struct {
 	Type string `json:"type,omitempty"`
 }
 
+func MakeChallengeData(chDataImpl ChallengeDataImplementation) ChallengeData {
+	return ChallengeData{chDataImpl: chDataImpl}
+}
+
+// nil if there is no data
+func (cdata ChallengeData) Implementation() ChallengeDataImplementation {
+	return cdata.chDataImpl
+}
+
 func (cdata *ChallengeData) UnmarshalJSON(data []byte) error {
 	var jsonType struct {
 		Type string `json:"type,omitempty"`
@@ -27,18 +35,14 @@ func (cdata *ChallengeData) UnmarshalJSON(data []byte) error {
 
 	var newData ChallengeDataImplementation
 
-	switch jsonType.Type {
-	case "":
+	if 0 == len(jsonType.Type) {
 		cdata.chDataImpl = nil
 		return nil
-	case simpleHttpIdentifier:
-		newData = &challengeSimpleHttpData{}
-	case dvsniIdentifier:
-		newData = &challengeDVSNIData{}
-	}
-
-	if nil == newData {
-		return fmt.Errorf("Unknown challenge data type %#v", jsonType.Type)
+	} else if challengeType, ok := challengeTypes[jsonType.Type]; ok {
+		newData = challengeType.NewData()
+	} else {
+		// manually entered response for an unknown challenge type
+		newData = &unknownChallengeData{}
 	}
 
 	if err := json.Unmarshal(data, newData); nil != err {
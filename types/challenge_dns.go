@@ -0,0 +1,163 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/ui"
+	"net"
+	"strings"
+)
+
+const DNSChallengeIdentifier string = "dns"
+
+func init() {
+	RegisterChallengeType(DNSChallengeIdentifier, ChallengeType{
+		NewChallenge: func() ChallengeImplementation { return &challengeDNS{} },
+		NewData:      func() ChallengeDataImplementation { return &challengeDNSData{} },
+	})
+}
+
+const dnsRecordPrefix = "_acme-challenge."
+
+type challengeDNS struct {
+	Resource ResourceChallengeTag `json:"resource"`
+	ChallengeBasic
+	Token string `json:"token,omitempty"` // ASCII only
+}
+
+type challengeDNSData struct {
+	Resource   ResourceChallengeTag `json:"resource"`
+	Type       string               `json:"type"`
+	Validation JSONSignature        `json:"validation"`
+}
+
+type challengeDNSFileData struct {
+	Type  string `json:"type"`
+	Token string `json:"token"`
+}
+
+func (dnsData *challengeDNSData) GetType() string {
+	return dnsData.Type
+}
+
+func (dns *challengeDNS) GetToken() string {
+	return dns.Token
+}
+
+func (dns *challengeDNS) Respond(registration *Registration, authorization *Authorization) (ChallengeResponding, error) {
+	responding := challengeDNSResponding{
+		registration:  registration,
+		dnsIdentifier: authorization.Resource.Identifier.Value,
+		challenge:     *dns,
+		data: challengeDNSData{
+			Type: DNSChallengeIdentifier,
+		},
+	}
+
+	if oldData := authorization.ChallengesData[dns.GetURI()].chDataImpl; nil != oldData {
+		if oldDNSData, ok := oldData.(*challengeDNSData); ok {
+			responding.data = *oldDNSData
+		} else {
+			return nil, fmt.Errorf("Mismatching challenge data %#v", oldData)
+		}
+	}
+	if nil == responding.data.Validation.Signature {
+		if err := responding.ResetResponse(); nil != err {
+			return nil, err
+		}
+	}
+	return &responding, nil
+}
+
+type challengeDNSResponding struct {
+	registration  *Registration
+	dnsIdentifier string
+	challenge     challengeDNS
+	data          challengeDNSData
+}
+
+func (responding *challengeDNSResponding) DNSIdentifier() string {
+	return responding.dnsIdentifier
+}
+
+func (responding *challengeDNSResponding) ResetResponse() error {
+	if payload, err := json.Marshal(
+		challengeDNSFileData{
+			Type:  DNSChallengeIdentifier,
+			Token: responding.challenge.Token,
+		}); nil != err {
+		return err
+	} else if sig, err := responding.registration.SigningKey.Sign(payload, ""); nil != err {
+		return err
+	} else {
+		responding.data.Validation.Signature = sig
+		return nil
+	}
+}
+
+func (responding *challengeDNSResponding) InitializeResponse(UI ui.UserInterface) error {
+	return nil
+}
+
+// fully qualified name (with trailing dot) of the TXT record for a domain
+func DNSChallengeRecordName(domain string) string {
+	return dnsRecordPrefix + strings.TrimSuffix(domain, ".") + "."
+}
+
+func (responding *challengeDNSResponding) RecordName() string {
+	return DNSChallengeRecordName(responding.dnsIdentifier)
+}
+
+// content of the TXT record: the (base64url encoded) signature of the
+// validation
+func (responding *challengeDNSResponding) RecordValue() (string, error) {
+	if compSig, err := responding.data.Validation.Signature.CompactSerialize(); nil != err {
+		return "", err
+	} else {
+		return strings.Split(compSig, ".")[2], nil
+	}
+}
+
+func (responding *challengeDNSResponding) ShowInstructions(UI ui.UserInterface) error {
+	if value, err := responding.RecordValue(); nil != err {
+		return err
+	} else if _, err := UI.Prompt(fmt.Sprintf(
+		"Create a TXT record for %s with the text on the next line (without quotes)\n%s\nPress enter when done",
+		responding.RecordName(), value)); nil != err {
+		return err
+	}
+	return nil
+}
+
+func (responding *challengeDNSResponding) Verify() error {
+	value, err := responding.RecordValue()
+	if nil != err {
+		return err
+	}
+	records, err := net.LookupTXT(responding.RecordName())
+	if nil != err {
+		return fmt.Errorf("Couldn't lookup TXT record %s: %v", responding.RecordName(), err)
+	}
+	for _, record := range records {
+		if record == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("TXT record %s doesn't contain %#v (found %v)", responding.RecordName(), value, records)
+}
+
+func (responding *challengeDNSResponding) SendPayload() (interface{}, error) {
+	return responding.data, nil
+}
+
+func (responding *challengeDNSResponding) ChallengeData() ChallengeData {
+	return ChallengeData{chDataImpl: &responding.data}
+}
+
+func (responding *challengeDNSResponding) Challenge() Challenge {
+	return Challenge{chImpl: &responding.challenge}
+}
+
+func (responding *challengeDNSResponding) Registration() *Registration {
+	return responding.registration
+}
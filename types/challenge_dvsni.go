@@ -11,33 +11,25 @@ import (
 	"fmt"
 	"github.com/stbuehler/go-acme-client/ui"
 	"github.com/stbuehler/go-acme-client/utils"
+	"net"
 	"strings"
 )
 
-const dvsniIdentifier string = "dvsni"
+const DVSNIIdentifier string = "dvsni"
+
+func init() {
+	RegisterChallengeType(DVSNIIdentifier, ChallengeType{
+		NewChallenge: func() ChallengeImplementation { return &challengeDVSNI{} },
+		NewData:      func() ChallengeDataImplementation { return &challengeDVSNIData{} },
+	})
+}
 
 type challengeDVSNI struct {
 	Resource ResourceChallengeTag `json:"resource"`
-	rawChallengeBasic
+	ChallengeBasic
 	Token string `json:"token,omitempty"` // ASCII only
 }
 
-func (dvsni *challengeDVSNI) GetType() string {
-	return dvsni.Type
-}
-
-func (dvsni *challengeDVSNI) GetStatus() string {
-	return dvsni.Status
-}
-
-func (dvsni *challengeDVSNI) GetValidated() string {
-	return dvsni.Validated
-}
-
-func (dvsni *challengeDVSNI) GetURI() string {
-	return dvsni.URI
-}
-
 type challengeDVSNIData struct {
 	Resource   ResourceChallengeTag `json:"resource"`
 	Type       string               `json:"type"`
@@ -48,13 +40,17 @@ func (dvsniData *challengeDVSNIData) GetType() string {
 	return dvsniData.Type
 }
 
-func (dvsni *challengeDVSNI) initializeResponse(registration *Registration, authorization *Authorization) (ChallengeResponding, error) {
+func (dvsni *challengeDVSNI) GetToken() string {
+	return dvsni.Token
+}
+
+func (dvsni *challengeDVSNI) Respond(registration *Registration, authorization *Authorization) (ChallengeResponding, error) {
 	responding := challengeDVSNIResponding{
 		registration:  registration,
-		dnsIdentifier: string(authorization.Resource.DNSIdentifier),
+		dnsIdentifier: authorization.Resource.Identifier.Value,
 		challenge:     *dvsni,
 		data: challengeDVSNIData{
-			Type: dvsniIdentifier,
+			Type: DVSNIIdentifier,
 		},
 	}
 
@@ -84,10 +80,14 @@ type challengeDVSNIFileData struct {
 	Token string `json:"token"`
 }
 
+func (responding *challengeDVSNIResponding) DNSIdentifier() string {
+	return responding.dnsIdentifier
+}
+
 func (responding *challengeDVSNIResponding) ResetResponse() error {
 	if payload, err := json.Marshal(
 		challengeDVSNIFileData{
-			Type:  dvsniIdentifier,
+			Type:  DVSNIIdentifier,
 			Token: responding.challenge.Token,
 		}); nil != err {
 		return err
@@ -116,30 +116,37 @@ func (responding *challengeDVSNIResponding) ShowInstructions(UI ui.UserInterface
 }
 
 func (responding *challengeDVSNIResponding) Verify() error {
+	return verifyAddresses(responding.dnsIdentifier, "443", responding.verifyAt)
+}
+
+func (responding *challengeDVSNIResponding) verifyAt(address string) error {
 	sniName := responding.subjectAltName()
-	if conn, err := tls.Dial("tcp", responding.dnsIdentifier+":443", &tls.Config{
+	dialer := &net.Dialer{Timeout: verifyTimeout}
+	if conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
 		RootCAs:            x509.NewCertPool(),
 		ServerName:         sniName,
 		InsecureSkipVerify: true,
 	}); nil != err {
-		return fmt.Errorf("Failed to establish connection with %s:443: %v", responding.dnsIdentifier, err)
+		return fmt.Errorf("Failed to establish connection with %s: %v", address, err)
 	} else if err := conn.Handshake(); nil != err {
-		return fmt.Errorf("Failed TLS handshake with %s:443: %v", responding.dnsIdentifier, err)
+		conn.Close()
+		return fmt.Errorf("Failed TLS handshake with %s: %v", address, err)
 	} else {
+		defer conn.Close()
 		cState := conn.ConnectionState()
 		if 0 == len(cState.PeerCertificates) {
-			return fmt.Errorf("Server %s:443 returned no certificates", responding.dnsIdentifier)
+			return fmt.Errorf("Server %s returned no certificates", address)
 		}
 		cert := cState.PeerCertificates[0]
 		for _, name := range cert.DNSNames {
 			if name == sniName {
 				// found name, verifiation successful
-				return nil
+				return responding.checkCertificateAt(dialer, address)
 			}
 		}
 		return fmt.Errorf(
-			"Certificate on %s:443 for SNI name %s didn't contain the SNI name in SubjectAltName: CommonName=%s, DNSNames=%v",
-			responding.dnsIdentifier, sniName, cert.Subject.CommonName, cert.DNSNames)
+			"Certificate on %s for SNI name %s didn't contain the SNI name in SubjectAltName: CommonName=%s, DNSNames=%v",
+			address, sniName, cert.Subject.CommonName, cert.DNSNames)
 	}
 }
 
@@ -159,6 +166,14 @@ func (responding *challengeDVSNIResponding) Registration() *Registration {
 	return responding.registration
 }
 
+func (responding *challengeDVSNIResponding) SNIName() string {
+	return responding.subjectAltName()
+}
+
+func (responding *challengeDVSNIResponding) Certificate() (string, error) {
+	return responding.makeCertificate()
+}
+
 func (responding *challengeDVSNIResponding) makeCertificate() (string, error) {
 	var out bytes.Buffer
 
@@ -194,3 +209,21 @@ func (responding *challengeDVSNIResponding) subjectAltName() string {
 
 	return Z[0:32] + "." + Z[32:64] + dvsni_base_servername
 }
+
+// the challenge certificate is self-signed by design; check the certificate
+// served for the domain itself instead
+func (responding *challengeDVSNIResponding) checkCertificateAt(dialer *net.Dialer, address string) error {
+	if TLSTrustInsecure == VerifyTLS {
+		return nil
+	}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		ServerName:         responding.dnsIdentifier,
+		InsecureSkipVerify: true,
+	})
+	if nil != err {
+		return &CertificateWarning{Err: err}
+	}
+	defer conn.Close()
+	state := conn.ConnectionState()
+	return checkCertificate(&state, responding.dnsIdentifier)
+}
@@ -0,0 +1,299 @@
+package types
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const EmailReplyIdentifier string = "email-reply-00"
+
+func init() {
+	RegisterChallengeType(EmailReplyIdentifier, ChallengeType{
+		NewChallenge: func() ChallengeImplementation { return &challengeEmailReply{} },
+		NewData:      func() ChallengeDataImplementation { return &challengeEmailReplyData{} },
+	})
+}
+
+const emailSubjectPrefix = "ACME: "
+
+// RFC 8823: the server sends an email with the first half of the token in
+// the subject to the address; the response is a reply containing the
+// digest of the key authorization built from both halves
+type challengeEmailReply struct {
+	Resource ResourceChallengeTag `json:"resource"`
+	ChallengeBasic
+	URL   string `json:"url,omitempty"`
+	From  string `json:"from,omitempty"`
+	Token string `json:"token,omitempty"` // second half of the token
+}
+
+type challengeEmailReplyData struct {
+	Resource ResourceChallengeTag `json:"resource"`
+	Type     string               `json:"type"`
+	// from the challenge email; not sent to the server
+	TokenPart1 string `json:"tokenPart1,omitempty"`
+	MessageID  string `json:"messageId,omitempty"`
+}
+
+type challengeEmailReplyPayload struct {
+	Resource ResourceChallengeTag `json:"resource"`
+	Type     string               `json:"type"`
+}
+
+func (emailData *challengeEmailReplyData) GetType() string {
+	return emailData.Type
+}
+
+func (email *challengeEmailReply) GetToken() string {
+	return email.Token
+}
+
+// RFC 8823 servers use "url" instead of "uri"
+func (email *challengeEmailReply) GetURI() string {
+	if 0 == len(email.URI) {
+		return email.URL
+	}
+	return email.URI
+}
+
+func (email *challengeEmailReply) Respond(registration *Registration, authorization *Authorization) (ChallengeResponding, error) {
+	if IdentifierEmail != authorization.Resource.Identifier.Type {
+		return nil, fmt.Errorf("Challenge %s needs an email identifier", EmailReplyIdentifier)
+	}
+	responding := challengeEmailReplyResponding{
+		registration: registration,
+		address:      authorization.Resource.Identifier.Value,
+		challenge:    *email,
+		data: challengeEmailReplyData{
+			Type: EmailReplyIdentifier,
+		},
+	}
+
+	if oldData := authorization.ChallengesData[email.GetURI()].chDataImpl; nil != oldData {
+		if oldEmailData, ok := oldData.(*challengeEmailReplyData); ok {
+			responding.data = *oldEmailData
+		} else {
+			return nil, fmt.Errorf("Mismatching challenge data %#v", oldData)
+		}
+	}
+	return &responding, nil
+}
+
+type challengeEmailReplyResponding struct {
+	registration *Registration
+	address      string
+	challenge    challengeEmailReply
+	data         challengeEmailReplyData
+}
+
+func (responding *challengeEmailReplyResponding) DNSIdentifier() string {
+	return responding.address
+}
+
+func (responding *challengeEmailReplyResponding) ResetResponse() error {
+	responding.data.TokenPart1 = ""
+	responding.data.MessageID = ""
+	return nil
+}
+
+func (responding *challengeEmailReplyResponding) InitializeResponse(UI ui.UserInterface) error {
+	return nil
+}
+
+func (responding *challengeEmailReplyResponding) ChallengeSender() string {
+	return responding.challenge.From
+}
+
+func sameAddress(a string, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+func isBase64Url(s string) bool {
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || '-' == c || '_' == c) {
+			return false
+		}
+	}
+	return 0 != len(s)
+}
+
+// SetChallengeMessage checks the challenge email (RFC 5322 message) and
+// takes the first half of the token from its subject
+func (responding *challengeEmailReplyResponding) SetChallengeMessage(message []byte) error {
+	msg, err := mail.ReadMessage(bytes.NewReader(message))
+	if nil != err {
+		return fmt.Errorf("Couldn't parse email: %s", err)
+	}
+
+	if from, err := msg.Header.AddressList("From"); nil != err {
+		return fmt.Errorf("Couldn't parse From: %s", err)
+	} else if 1 != len(from) || !sameAddress(from[0].Address, responding.challenge.From) {
+		return fmt.Errorf("Email is not from %s", responding.challenge.From)
+	}
+
+	addressed := false
+	for _, field := range []string{"To", "Cc"} {
+		recipients, _ := msg.Header.AddressList(field)
+		for _, recipient := range recipients {
+			if sameAddress(recipient.Address, responding.address) {
+				addressed = true
+			}
+		}
+	}
+	if !addressed {
+		return fmt.Errorf("Email is not addressed to %s", responding.address)
+	}
+
+	if autoSubmitted, params, err := mime.ParseMediaType(msg.Header.Get("Auto-Submitted")); nil != err || "auto-generated" != autoSubmitted || "acme" != params["type"] {
+		return fmt.Errorf("Email is missing \"Auto-Submitted: auto-generated; type=acme\"")
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if nil != err {
+		return fmt.Errorf("Couldn't decode Subject: %s", err)
+	}
+	subject = strings.TrimSpace(subject)
+	if !strings.HasPrefix(subject, emailSubjectPrefix) {
+		return fmt.Errorf("Subject %#v doesn't start with %#v", subject, emailSubjectPrefix)
+	}
+	tokenPart1 := strings.TrimSpace(subject[len(emailSubjectPrefix):])
+	if !isBase64Url(tokenPart1) {
+		return fmt.Errorf("Invalid token in Subject %#v", subject)
+	}
+
+	responding.data.TokenPart1 = tokenPart1
+	responding.data.MessageID = strings.TrimSpace(msg.Header.Get("Message-ID"))
+	return nil
+}
+
+// PromptChallengeMessage reads the pasted challenge email
+func (responding *challengeEmailReplyResponding) PromptChallengeMessage(UI ui.UserInterface) error {
+	for {
+		UI.Messagef("Paste the challenge email from %s to %s including all headers, end with a line containing only a dot",
+			responding.challenge.From, responding.address)
+		var message []string
+		for {
+			line, err := UI.Prompt("")
+			if nil != err {
+				return err
+			}
+			if "." == line {
+				break
+			}
+			message = append(message, line)
+		}
+		if 0 == len(message) {
+			return fmt.Errorf("No challenge email entered")
+		}
+		if err := responding.SetChallengeMessage([]byte(strings.Join(message, "\n") + "\n")); nil != err {
+			UI.Messagef("%s, try again", err)
+			continue
+		}
+		return nil
+	}
+}
+
+func (responding *challengeEmailReplyResponding) KeyAuthorization() (string, error) {
+	if 0 == len(responding.data.TokenPart1) {
+		return "", fmt.Errorf("No challenge email processed yet")
+	}
+	thumbprint, err := responding.registration.SigningKey.Thumbprint()
+	if nil != err {
+		return "", err
+	}
+	return responding.data.TokenPart1 + responding.challenge.Token + "." + thumbprint, nil
+}
+
+func randomMessageID(address string) (string, error) {
+	random := make([]byte, 18)
+	if _, err := rand.Read(random); nil != err {
+		return "", err
+	}
+	domain := address[strings.LastIndex(address, "@")+1:]
+	return fmt.Sprintf("<%s@%s>", utils.Base64UrlEncode(random), domain), nil
+}
+
+// ResponseMessage composes the reply to the challenge email (with CRLF
+// line endings, ready to be sent from the identifier address to
+// ChallengeSender())
+func (responding *challengeEmailReplyResponding) ResponseMessage() ([]byte, error) {
+	keyAuthorization, err := responding.KeyAuthorization()
+	if nil != err {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(keyAuthorization))
+	messageID, err := randomMessageID(responding.address)
+	if nil != err {
+		return nil, err
+	}
+
+	headers := []string{
+		"From: " + (&mail.Address{Address: responding.address}).String(),
+		"To: " + (&mail.Address{Address: responding.challenge.From}).String(),
+		"Subject: Re: " + emailSubjectPrefix + responding.data.TokenPart1,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: " + messageID,
+	}
+	if 0 != len(responding.data.MessageID) {
+		headers = append(headers,
+			"In-Reply-To: "+responding.data.MessageID,
+			"References: "+responding.data.MessageID)
+	}
+	headers = append(headers,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=US-ASCII",
+		"Auto-Submitted: auto-replied; type=acme")
+
+	body := []string{
+		"-----BEGIN ACME RESPONSE-----",
+		utils.Base64UrlEncode(digest[:]),
+		"-----END ACME RESPONSE-----",
+	}
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + strings.Join(body, "\r\n") + "\r\n"), nil
+}
+
+func (responding *challengeEmailReplyResponding) ShowInstructions(UI ui.UserInterface) error {
+	if 0 == len(responding.data.TokenPart1) {
+		if err := responding.PromptChallengeMessage(UI); nil != err {
+			return err
+		}
+	}
+	if message, err := responding.ResponseMessage(); nil != err {
+		return err
+	} else if _, err := UI.Prompt(fmt.Sprintf(
+		"Send the following email from %s to %s (keep the headers)\n%s\nPress enter when done",
+		responding.address, responding.challenge.From, strings.Replace(string(message), "\r\n", "\n", -1))); nil != err {
+		return err
+	}
+	return nil
+}
+
+// the reply can't be checked locally; only make sure it could be built
+func (responding *challengeEmailReplyResponding) Verify() error {
+	_, err := responding.KeyAuthorization()
+	return err
+}
+
+func (responding *challengeEmailReplyResponding) SendPayload() (interface{}, error) {
+	return challengeEmailReplyPayload{Type: responding.data.Type}, nil
+}
+
+func (responding *challengeEmailReplyResponding) ChallengeData() ChallengeData {
+	return ChallengeData{chDataImpl: &responding.data}
+}
+
+func (responding *challengeEmailReplyResponding) Challenge() Challenge {
+	return Challenge{chImpl: &responding.challenge}
+}
+
+func (responding *challengeEmailReplyResponding) Registration() *Registration {
+	return responding.registration
+}
@@ -0,0 +1,39 @@
+package types
+
+import (
+	"fmt"
+)
+
+// constructors for a challenge type; the challenge implementation gets
+// filled by unmarshalling the server JSON, the data implementation by
+// unmarshalling stored response data
+type ChallengeType struct {
+	NewChallenge func() ChallengeImplementation
+	NewData      func() ChallengeDataImplementation
+}
+
+// maps challenge type identifier to implementation
+var challengeTypes = make(map[string]ChallengeType)
+
+// RegisterChallengeType makes a challenge type known to the JSON decoding of
+// challenges and challenge data; challenges of unknown type are kept as
+// opaque JSON. Should be called from init() functions; panics if the type
+// is registered twice.
+func RegisterChallengeType(identifier string, challengeType ChallengeType) {
+	if 0 == len(identifier) {
+		panic("Empty challenge type identifier")
+	}
+	if nil == challengeType.NewChallenge || nil == challengeType.NewData {
+		panic(fmt.Sprintf("Incomplete challenge type %#v", identifier))
+	}
+	if _, exists := challengeTypes[identifier]; exists {
+		panic(fmt.Sprintf("Challenge type %#v already registered", identifier))
+	}
+	challengeTypes[identifier] = challengeType
+}
+
+// whether a challenge type identifier was registered
+func IsKnownChallengeType(identifier string) bool {
+	_, ok := challengeTypes[identifier]
+	return ok
+}
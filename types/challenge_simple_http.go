@@ -1,39 +1,33 @@
 package types
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"github.com/stbuehler/go-acme-client/ui"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 )
 
-const simpleHttpIdentifier string = "simpleHttp"
+const SimpleHttpIdentifier string = "simpleHttp"
+
+func init() {
+	RegisterChallengeType(SimpleHttpIdentifier, ChallengeType{
+		NewChallenge: func() ChallengeImplementation { return &challengeSimpleHttp{} },
+		NewData:      func() ChallengeDataImplementation { return &challengeSimpleHttpData{} },
+	})
+}
 
 type challengeSimpleHttp struct {
 	Resource ResourceChallengeTag `json:"resource"`
-	rawChallengeBasic
+	ChallengeBasic
 	Token string `json:"token,omitempty"` // ASCII only
 }
 
-func (simpleHttps *challengeSimpleHttp) GetType() string {
-	return simpleHttps.Type
-}
-
-func (simpleHttps *challengeSimpleHttp) GetStatus() string {
-	return simpleHttps.Status
-}
-
-func (simpleHttps *challengeSimpleHttp) GetValidated() string {
-	return simpleHttps.Validated
-}
-
-func (simpleHttps *challengeSimpleHttp) GetURI() string {
-	return simpleHttps.URI
-}
-
 type challengeSimpleHttpData struct {
 	Resource ResourceChallengeTag `json:"resource"`
 	Type     string               `json:"type"`
@@ -50,13 +44,17 @@ func (simpleHttpData *challengeSimpleHttpData) GetType() string {
 	return simpleHttpData.Type
 }
 
-func (simpleHttps *challengeSimpleHttp) initializeResponse(registration *Registration, authorization *Authorization) (ChallengeResponding, error) {
+func (simpleHttps *challengeSimpleHttp) GetToken() string {
+	return simpleHttps.Token
+}
+
+func (simpleHttps *challengeSimpleHttp) Respond(registration *Registration, authorization *Authorization) (ChallengeResponding, error) {
 	responding := challengeSimpleHttpResponding{
 		registration:  registration,
-		dnsIdentifier: string(authorization.Resource.DNSIdentifier),
+		dnsIdentifier: authorization.Resource.Identifier.Value,
 		challenge:     *simpleHttps,
 		data: challengeSimpleHttpData{
-			Type: simpleHttpIdentifier,
+			Type: SimpleHttpIdentifier,
 			TLS:  true,
 		},
 	}
@@ -76,6 +74,10 @@ type challengeSimpleHttpResponding struct {
 	data          challengeSimpleHttpData
 }
 
+func (responding *challengeSimpleHttpResponding) WellKnownPath() string {
+	return ".well-known/acme-challenge/" + responding.challenge.Token
+}
+
 func (responding *challengeSimpleHttpResponding) WellKnownURL() string {
 	var proto string
 	if responding.data.TLS {
@@ -84,25 +86,56 @@ func (responding *challengeSimpleHttpResponding) WellKnownURL() string {
 		proto = "http"
 	}
 	return fmt.Sprintf(
-		"%s://%s/.well-known/acme-challenge/%s",
+		"%s://%s/%s",
 		proto,
 		responding.dnsIdentifier,
-		responding.challenge.Token)
-}
-
-var simpleHttpsClient *http.Client
-
-func getSimpleHttpsClient() *http.Client {
-	if nil == simpleHttpsClient {
-		simpleHttpsClient = &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: true,
-				},
+		responding.WellKnownPath())
+}
+
+const verifyTimeout = 30 * time.Second
+
+// client connecting to address (IP:PORT) for requests to domain, whatever
+// it resolves to; redirects to other hosts are refused. Certificates are
+// checked afterwards with checkCertificate.
+func getSimpleHttpClient(domain string, address string) *http.Client {
+	dialer := &net.Dialer{Timeout: verifyTimeout}
+	ip, pinnedPort, _ := net.SplitHostPort(address)
+	return &http.Client{
+		Timeout: verifyTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				host, port, err := net.SplitHostPort(addr)
+				if nil != err {
+					return nil, err
+				}
+				if !strings.EqualFold(domain, host) {
+					return nil, fmt.Errorf("Refusing to connect to %s, only checking %s", host, domain)
+				}
+				if port != pinnedPort {
+					// redirect to another port (e.g. to https) of the same
+					// host
+					return dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+				}
+				return dialer.DialContext(ctx, network, address)
 			},
-		}
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !strings.EqualFold(domain, req.URL.Hostname()) {
+				return fmt.Errorf("redirect to other host %s not followed", req.URL.Host)
+			}
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			return nil
+		},
 	}
-	return simpleHttpsClient
+}
+
+func (responding *challengeSimpleHttpResponding) DNSIdentifier() string {
+	return responding.dnsIdentifier
 }
 
 func (responding *challengeSimpleHttpResponding) ResetResponse() error {
@@ -121,7 +154,7 @@ func (responding *challengeSimpleHttpResponding) InitializeResponse(UI ui.UserIn
 
 func (responding *challengeSimpleHttpResponding) createVerificationFileData() challengeSimpleHttpFileData {
 	return challengeSimpleHttpFileData{
-		Type:  simpleHttpIdentifier,
+		Type:  SimpleHttpIdentifier,
 		TLS:   responding.data.TLS,
 		Token: responding.challenge.Token,
 	}
@@ -137,6 +170,10 @@ func (responding *challengeSimpleHttpResponding) createVerificationFile() (strin
 	}
 }
 
+func (responding *challengeSimpleHttpResponding) VerificationFile() (string, error) {
+	return responding.createVerificationFile()
+}
+
 func (responding *challengeSimpleHttpResponding) ShowInstructions(UI ui.UserInterface) error {
 	if file, err := responding.createVerificationFile(); nil != err {
 		return err
@@ -149,13 +186,18 @@ func (responding *challengeSimpleHttpResponding) ShowInstructions(UI ui.UserInte
 }
 
 func (responding *challengeSimpleHttpResponding) Verify() error {
-	var httpClient *http.Client
+	port := "80"
 	if responding.data.TLS {
-		httpClient = getSimpleHttpsClient()
-	} else {
-		httpClient = &http.Client{}
+		port = "443"
 	}
+	return verifyAddresses(responding.dnsIdentifier, port, func(address string) error {
+		return responding.verifyAt(getSimpleHttpClient(responding.dnsIdentifier, address))
+	})
+}
 
+// a content mismatch is an error, an untrusted certificate only a
+// *CertificateWarning
+func (responding *challengeSimpleHttpResponding) verifyAt(httpClient *http.Client) error {
 	url := responding.WellKnownURL()
 	resp, err := httpClient.Get(url)
 	if nil != err {
@@ -187,6 +229,9 @@ func (responding *challengeSimpleHttpResponding) Verify() error {
 		return fmt.Errorf("payload of signature of document at %s is not valid (expected %#v, not %#v)", url, expectedData, verifyData)
 	}
 
+	if nil != resp.TLS {
+		return checkCertificate(resp.TLS, responding.dnsIdentifier)
+	}
 	return nil
 }
 
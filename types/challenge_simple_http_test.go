@@ -0,0 +1,47 @@
+package types
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSimpleHttpClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/redirect":
+			http.Redirect(w, req, "/target", http.StatusFound)
+		case "/redirect-other":
+			http.Redirect(w, req, "http://other.example.net/target", http.StatusFound)
+		case "/target":
+			w.Write([]byte(req.Host))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer server.Close()
+	address := server.Listener.Addr().String()
+	port := address[strings.LastIndex(address, ":")+1:]
+	client := getSimpleHttpClient("www.example.com", address)
+
+	// connects to the pinned address, redirects on the same host are
+	// followed
+	resp, err := client.Get("http://www.example.com:" + port + "/redirect")
+	if nil != err {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if 200 != resp.StatusCode || "www.example.com:"+port != string(body) {
+		t.Fatalf("Unexpected response %s %q", resp.Status, body)
+	}
+
+	if _, err := client.Get("http://www.example.com:" + port + "/redirect-other"); nil == err || !strings.Contains(err.Error(), "other host other.example.net") {
+		t.Fatalf("Expected redirect to other host to be refused, got %v", err)
+	}
+	if _, err := client.Get("http://other.example.net:" + port + "/target"); nil == err || !strings.Contains(err.Error(), "Refusing to connect") {
+		t.Fatalf("Expected request to other host to be refused, got %v", err)
+	}
+}
@@ -2,10 +2,12 @@ package types
 
 import (
 	"encoding/json"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/ui"
 )
 
 type unknownChallenge struct {
-	basic rawChallengeBasic
+	basic ChallengeBasic
 	data  map[string]interface{}
 }
 
@@ -42,6 +44,154 @@ func (c *unknownChallenge) GetURI() string {
 	return c.basic.URI
 }
 
-func (*unknownChallenge) initializeResponse(registration *Registration, authorization *Authorization) (ChallengeResponding, error) {
-	return nil, nil
+func (c *unknownChallenge) GetError() *Problem {
+	return c.basic.Error
+}
+
+func (c *unknownChallenge) GetValidationRecords() []ValidationRecord {
+	return c.basic.ValidationRecord
+}
+
+// challenges of unknown type are answered with a payload the operator
+// enters manually
+func (c *unknownChallenge) Respond(registration *Registration, authorization *Authorization) (ChallengeResponding, error) {
+	responding := unknownChallengeResponding{
+		registration:  registration,
+		dnsIdentifier: authorization.Resource.Identifier.Value,
+		challenge:     *c,
+	}
+
+	if oldData := authorization.ChallengesData[c.GetURI()].chDataImpl; nil != oldData {
+		if oldUnknownData, ok := oldData.(*unknownChallengeData); ok && oldUnknownData.GetType() == c.GetType() {
+			responding.data = *oldUnknownData
+		}
+	}
+	return &responding, nil
+}
+
+// response payload for a challenge of unknown type, as entered by the
+// operator
+type unknownChallengeData struct {
+	data map[string]interface{}
+}
+
+func (d *unknownChallengeData) GetType() string {
+	if challengeType, ok := d.data["type"].(string); ok {
+		return challengeType
+	}
+	return ""
+}
+
+func (d *unknownChallengeData) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.data)
+}
+
+func (d *unknownChallengeData) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &d.data)
+}
+
+type unknownChallengeResponding struct {
+	registration  *Registration
+	dnsIdentifier string
+	challenge     unknownChallenge
+	data          unknownChallengeData
+}
+
+func (responding *unknownChallengeResponding) DNSIdentifier() string {
+	return responding.dnsIdentifier
+}
+
+func (responding *unknownChallengeResponding) ResetResponse() error {
+	responding.data.data = nil
+	return nil
+}
+
+func (responding *unknownChallengeResponding) rawChallenge() string {
+	if raw, err := json.MarshalIndent(responding.challenge.data, "", "  "); nil != err {
+		return fmt.Sprintf("%#v", responding.challenge.data)
+	} else {
+		return string(raw)
+	}
+}
+
+// shows the raw challenge and reads the response payload (a JSON object,
+// can span multiple lines)
+func (responding *unknownChallengeResponding) InitializeResponse(UI ui.UserInterface) error {
+	UI.Messagef("Unknown challenge type %#v:\n%s", responding.challenge.GetType(), responding.rawChallenge())
+	if nil != responding.data.data {
+		if keep, err := UI.YesNoDialog("", "", "Use the previously entered response payload?", true); nil != err {
+			return err
+		} else if keep {
+			return nil
+		}
+	}
+
+	input := ""
+	prompt := "Enter the response payload (JSON object, \"type\" and \"resource\" are added if missing)"
+	for {
+		line, err := UI.Prompt(prompt)
+		if nil != err {
+			return err
+		}
+		if 0 == len(input) && 0 == len(line) {
+			return fmt.Errorf("No response payload entered")
+		}
+		input += line + "\n"
+
+		var payload map[string]interface{}
+		if err := json.Unmarshal([]byte(input), &payload); nil == err {
+			if nil == payload {
+				UI.Message("The payload must be a JSON object, try again")
+				input = ""
+				prompt = "Enter the response payload"
+				continue
+			}
+			if _, ok := payload["type"]; !ok {
+				payload["type"] = responding.challenge.GetType()
+			}
+			payload["resource"] = Resource_Challenge.String()
+			responding.data.data = payload
+			return nil
+		} else if syntaxErr, ok := err.(*json.SyntaxError); ok && "unexpected end of JSON input" == syntaxErr.Error() {
+			// continue reading lines
+			prompt = ""
+		} else {
+			UI.Messagef("Invalid JSON (%s), try again", err)
+			input = ""
+			prompt = "Enter the response payload"
+		}
+	}
+}
+
+func (responding *unknownChallengeResponding) ShowInstructions(UI ui.UserInterface) error {
+	if _, err := UI.Prompt(fmt.Sprintf(
+		"Prepare whatever challenge type %#v needs for %s (see the challenge above)\nPress enter when done",
+		responding.challenge.GetType(), responding.dnsIdentifier)); nil != err {
+		return err
+	}
+	return nil
+}
+
+// unknown challenge types can't be verified locally
+func (responding *unknownChallengeResponding) Verify() error {
+	return nil
+}
+
+func (responding *unknownChallengeResponding) SendPayload() (interface{}, error) {
+	if nil == responding.data.data {
+		return nil, fmt.Errorf("No response payload entered")
+	}
+	return &responding.data, nil
+}
+
+func (responding *unknownChallengeResponding) ChallengeData() ChallengeData {
+	return ChallengeData{chDataImpl: &responding.data}
+}
+
+func (responding *unknownChallengeResponding) Challenge() Challenge {
+	return Challenge{chImpl: &responding.challenge}
+}
+
+func (responding *unknownChallengeResponding) Registration() *Registration {
+	return responding.registration
 }
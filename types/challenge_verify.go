@@ -0,0 +1,203 @@
+package types
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/utils"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strings"
+)
+
+const TLSTrustInsecure = "insecure"
+const TLSTrustSystem = "system"
+
+// how self-verification checks TLS certificates of the server:
+// TLSTrustInsecure, TLSTrustSystem or the path of a PEM CA bundle. The
+// response itself is always checked; certificate problems are reported as
+// warnings.
+type VerifyTLSTrust string
+
+var VerifyTLS = VerifyTLSTrust(TLSTrustInsecure)
+
+// roots loaded from the CA bundle
+var verifyTLSBundle *x509.CertPool
+
+func (trust *VerifyTLSTrust) String() string {
+	return string(*trust)
+}
+
+func (trust *VerifyTLSTrust) Set(v string) error {
+	if 0 == len(v) {
+		return fmt.Errorf("Expected %s, %s or the path of a CA bundle", TLSTrustInsecure, TLSTrustSystem)
+	}
+	*trust = VerifyTLSTrust(v)
+	verifyTLSBundle = nil
+	return nil
+}
+
+// certificate problem found by self-verification
+type CertificateWarning struct {
+	Err error
+}
+
+func (warning *CertificateWarning) Error() string {
+	return fmt.Sprintf("certificate not trusted: %v", warning.Err)
+}
+
+func verifyRoots() (*x509.CertPool, error) {
+	switch VerifyTLS {
+	case TLSTrustSystem:
+		// nil means system roots
+		return nil, nil
+	default:
+		if nil == verifyTLSBundle {
+			pemData, err := ioutil.ReadFile(string(VerifyTLS))
+			if nil != err {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pemData) {
+				return nil, fmt.Errorf("No certificates found in CA bundle %s", VerifyTLS)
+			}
+			verifyTLSBundle = pool
+		}
+		return verifyTLSBundle, nil
+	}
+}
+
+// checkCertificate verifies the chain the server presented for domain
+// against the configured roots; returns a *CertificateWarning for untrusted
+// certificates (and nil in insecure mode)
+func checkCertificate(state *tls.ConnectionState, domain string) error {
+	if TLSTrustInsecure == VerifyTLS {
+		return nil
+	}
+	roots, err := verifyRoots()
+	if nil != err {
+		return fmt.Errorf("Couldn't load CA bundle: %v", err)
+	}
+	if 0 == len(state.PeerCertificates) {
+		return &CertificateWarning{Err: fmt.Errorf("no certificate")}
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       domain,
+		Roots:         roots,
+		Intermediates: intermediates,
+	}); nil != err {
+		return &CertificateWarning{Err: err}
+	}
+	return nil
+}
+
+// maps domain names to the address (IP or IP:PORT) self-verification
+// connects to instead of the resolved addresses; the empty domain is the
+// fallback for all domains without an explicit entry
+type VerifyAddresses map[string]string
+
+// explicit addresses for the self-verification of challenge responses; by
+// default every A and AAAA address of the domain is checked
+var VerifyAddressOverrides VerifyAddresses
+
+func (addresses VerifyAddresses) Lookup(domain string) (string, bool) {
+	if address, ok := addresses[domain]; ok {
+		return address, true
+	}
+	address, ok := addresses[""]
+	return address, ok
+}
+
+func (addresses *VerifyAddresses) String() string {
+	var entries []string
+	for domain, address := range *addresses {
+		if 0 == len(domain) {
+			entries = append(entries, address)
+		} else {
+			entries = append(entries, domain+"="+address)
+		}
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// accepts either "ADDRESS" (fallback) or "DOMAIN=ADDRESS"
+func (addresses *VerifyAddresses) Set(v string) error {
+	var domain, address string
+	if ndx := strings.Index(v, "="); -1 != ndx {
+		domain, address = v[:ndx], v[ndx+1:]
+		if 0 == len(domain) {
+			return fmt.Errorf("Empty domain in address %#v", v)
+		}
+	} else {
+		address = v
+	}
+	if _, _, err := net.SplitHostPort(address); nil != err && nil == net.ParseIP(address) {
+		return fmt.Errorf("Expected IP or IP:PORT, got %#v", address)
+	}
+	if nil == *addresses {
+		*addresses = make(VerifyAddresses)
+	}
+	(*addresses)[domain] = address
+	return nil
+}
+
+// host:port addresses to check a domain on: the override or all resolved
+// addresses
+func verificationAddresses(domain string, port string) ([]string, error) {
+	if address, ok := VerifyAddressOverrides.Lookup(domain); ok {
+		if nil != net.ParseIP(address) {
+			return []string{net.JoinHostPort(address, port)}, nil
+		}
+		return []string{address}, nil
+	}
+	ips, err := net.LookupIP(domain)
+	if nil != err {
+		return nil, fmt.Errorf("Couldn't resolve %s: %v", domain, err)
+	}
+	var addresses []string
+	for _, ip := range ips {
+		addresses = append(addresses, net.JoinHostPort(ip.String(), port))
+	}
+	if 0 == len(addresses) {
+		return nil, fmt.Errorf("%s has no addresses", domain)
+	}
+	return addresses, nil
+}
+
+// verifyAddresses runs check for every address of domain separately, so a
+// single broken backend isn't hidden by the others; the error lists the
+// result for each address. A *CertificateWarning from check only gets
+// logged.
+func verifyAddresses(domain string, port string, check func(address string) error) error {
+	addresses, err := verificationAddresses(domain, port)
+	if nil != err {
+		return err
+	}
+	failed := 0
+	results := make([]string, len(addresses))
+	for ndx, address := range addresses {
+		if err := check(address); nil != err {
+			if warning, ok := err.(*CertificateWarning); ok {
+				utils.Warningf("Verification of %s on %s: %v", domain, address, warning)
+				results[ndx] = fmt.Sprintf("%s: ok, but %v", address, warning)
+				continue
+			}
+			failed++
+			utils.Infof("Verification of %s on %s failed: %v", domain, address, err)
+			results[ndx] = fmt.Sprintf("%s: %v", address, err)
+		} else {
+			utils.Infof("Verified %s on %s", domain, address)
+			results[ndx] = fmt.Sprintf("%s: ok", address)
+		}
+	}
+	if 0 != failed {
+		return fmt.Errorf("Verification of %s failed on %d of %d addresses:\n\t%s", domain, failed, len(addresses), strings.Join(results, "\n\t"))
+	}
+	return nil
+}
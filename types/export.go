@@ -13,7 +13,9 @@ var UnexpectedPemBlock = errors.New("Unexpected PEM block")
 
 const pemTypeEcPrivateKey = "EC PRIVATE KEY"
 const pemTypeRsaPrivateKey = "RSA PRIVATE KEY"
+const pemTypePrivateKey = "PRIVATE KEY" // PKCS#8
 const pemTypePublicKey = "PUBLIC KEY"
+const pemTypeAcmeKeyReference = "ACME KEY REFERENCE"
 const pemTypeCertificate = "CERTIFICATE"
 const pemTypeAcmeJsonRegistration = "ACME JSON REGISTRATION"
 const pemTypeAcmeJsonAuthorization = "ACME JSON AUTHORIZATION"
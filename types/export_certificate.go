@@ -3,6 +3,7 @@ package types
 import (
 	"encoding/pem"
 	"github.com/stbuehler/go-acme-client/utils"
+	"time"
 )
 
 type CertificateExport struct {
@@ -10,6 +11,12 @@ type CertificateExport struct {
 	PrivateKeyPem  []byte
 	Location       string
 	LinkIssuer     string
+	KeySpec        string
+	Group          string
+	KeyPolicy      string
+	KeyUses        int
+	ReplacedBy     string
+	Replaced       *time.Time
 }
 
 func (cert *Certificate) Import(export CertificateExport, prompt PasswordPrompt) error {
@@ -19,7 +26,7 @@ func (cert *Certificate) Import(export CertificateExport, prompt PasswordPrompt)
 	}
 	var privateKeyBlock *pem.Block
 	if nil != export.PrivateKeyPem {
-		privateKeyBlock, err = importPem(export.PrivateKeyPem, prompt, pemTypeEcPrivateKey, pemTypeRsaPrivateKey)
+		privateKeyBlock, err = importPem(export.PrivateKeyPem, prompt, pemTypeEcPrivateKey, pemTypeRsaPrivateKey, pemTypePrivateKey, pemTypeAcmeKeyReference)
 		if nil != err {
 			return err
 		}
@@ -29,6 +36,12 @@ func (cert *Certificate) Import(export CertificateExport, prompt PasswordPrompt)
 	cert.PrivateKey = privateKeyBlock
 	cert.Location = export.Location
 	cert.LinkIssuer = export.LinkIssuer
+	cert.KeySpec = utils.KeySpec(export.KeySpec)
+	cert.Group = export.Group
+	cert.KeyPolicy = KeyPolicy(export.KeyPolicy)
+	cert.KeyUses = export.KeyUses
+	cert.ReplacedBy = export.ReplacedBy
+	cert.Replaced = export.Replaced
 
 	return nil
 }
@@ -48,5 +61,11 @@ func (cert Certificate) Export(password string) (*CertificateExport, error) {
 		PrivateKeyPem:  privateKeyBlob,
 		Location:       cert.Location,
 		LinkIssuer:     cert.LinkIssuer,
+		KeySpec:        string(cert.KeySpec),
+		Group:          cert.Group,
+		KeyPolicy:      string(cert.KeyPolicy),
+		KeyUses:        cert.KeyUses,
+		ReplacedBy:     cert.ReplacedBy,
+		Replaced:       cert.Replaced,
 	}, nil
 }
@@ -3,6 +3,7 @@ package types
 import (
 	"encoding/json"
 	"encoding/pem"
+	"fmt"
 	"github.com/stbuehler/go-acme-client/utils"
 )
 
@@ -52,7 +53,7 @@ func (reg *Registration) Import(export RegistrationExport, prompt PasswordPrompt
 	if nil != err {
 		return err
 	}
-	keyBlock, err := importPem(export.SigningKeyPem, prompt, pemTypeEcPrivateKey, pemTypeRsaPrivateKey)
+	keyBlock, err := importPem(export.SigningKeyPem, prompt, pemTypeEcPrivateKey, pemTypeRsaPrivateKey, pemTypePrivateKey, pemTypePublicKey, pemTypeAcmeKeyReference)
 	if nil != err {
 		return err
 	}
@@ -75,3 +76,52 @@ func (reg *Registration) Import(export RegistrationExport, prompt PasswordPrompt
 
 	return nil
 }
+
+// ExportPublic exports the registration without private key (and without
+// encryption) for hosts preparing offline bundles; the directory URL is
+// needed to store it there
+func (reg Registration) ExportPublic(directoryURL string) ([]byte, error) {
+	export, err := Registration{
+		Resource:           reg.Resource,
+		SigningKey:         reg.SigningKey.PublicOnly(),
+		Location:           reg.Location,
+		LinkTermsOfService: reg.LinkTermsOfService,
+		Name:               reg.Name,
+	}.Export("")
+	if nil != err {
+		return nil, err
+	}
+	jsonBlock, _ := pem.Decode(export.JsonPem)
+	jsonBlock.Headers = map[string]string{
+		"Directory": directoryURL,
+		"Location":  reg.Location,
+		"Name":      reg.Name,
+	}
+	return append(pem.EncodeToMemory(jsonBlock), export.SigningKeyPem...), nil
+}
+
+// ImportPublicRegistration loads a registration exported with ExportPublic
+// and returns it with the directory URL
+func ImportPublicRegistration(data []byte) (*Registration, string, error) {
+	jsonBlock, rest := pem.Decode(data)
+	if nil == jsonBlock || pemTypeAcmeJsonRegistration != jsonBlock.Type {
+		return nil, "", UnexpectedPemBlock
+	}
+	headers := jsonBlock.Headers
+	jsonBlock.Headers = nil
+	var reg Registration
+	if err := reg.Import(RegistrationExport{
+		JsonPem:       pem.EncodeToMemory(jsonBlock),
+		SigningKeyPem: rest,
+		Location:      headers["Location"],
+		Name:          headers["Name"],
+	}, func() (string, error) {
+		return "", fmt.Errorf("Public registration export must not be encrypted")
+	}); nil != err {
+		return nil, "", err
+	}
+	if reg.SigningKey.CanSign() {
+		return nil, "", fmt.Errorf("Public registration export contains a private key")
+	}
+	return &reg, headers["Directory"], nil
+}
@@ -0,0 +1,267 @@
+package types
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	jose "github.com/letsencrypt/go-jose"
+	"github.com/stbuehler/go-acme-client/utils"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// go-jose can only sign with raw RSA and ECDSA private keys and doesn't
+// know EdDSA (RFC 8037); JWS are built, parsed and verified here so any
+// crypto.Signer (including Ed25519 keys) works
+
+// RFC 8037 signature algorithm for Ed25519 keys
+const EdDSA = jose.SignatureAlgorithm("EdDSA")
+
+type jwsProtectedHeader struct {
+	Algorithm string          `json:"alg"`
+	JWK       json.RawMessage `json:"jwk,omitempty"`
+	Nonce     string          `json:"nonce,omitempty"`
+}
+
+// JsonWebSignature with a single signature; FullSerialize returns the
+// flattened JSON serialization
+type JsonWebSignature struct {
+	Protected string          `json:"protected"`
+	Header    json.RawMessage `json:"header,omitempty"`
+	Payload   string          `json:"payload"`
+	Signature string          `json:"signature"`
+}
+
+// general JSON serialization (only a single signature is supported)
+type jwsGeneral struct {
+	Payload    string `json:"payload"`
+	Signatures []struct {
+		Protected string          `json:"protected"`
+		Header    json.RawMessage `json:"header,omitempty"`
+		Signature string          `json:"signature"`
+	} `json:"signatures"`
+}
+
+// signers which need the message instead of the digest (e.g. ssh-agent,
+// which hashes the data itself); same method as crypto.MessageSigner in
+// newer Go versions
+type MessageSigner interface {
+	crypto.Signer
+	SignMessage(rand io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+var MalformedJWS = errors.New("Malformed JWS")
+
+// 0 for EdDSA (which signs the message itself)
+func jwsHash(alg jose.SignatureAlgorithm) (crypto.Hash, error) {
+	switch alg {
+	case jose.ES256, jose.RS256, jose.PS256:
+		return crypto.SHA256, nil
+	case jose.ES384, jose.RS384, jose.PS384:
+		return crypto.SHA384, nil
+	case jose.ES512, jose.RS512, jose.PS512:
+		return crypto.SHA512, nil
+	case EdDSA:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("Unsupported signature algorithm %s", alg)
+	}
+}
+
+// accepts the flattened and general JSON serialization (with a single
+// signature) and the compact serialization
+func ParseJWS(input string) (*JsonWebSignature, error) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, "{") {
+		parts := strings.Split(input, ".")
+		if 3 != len(parts) {
+			return nil, MalformedJWS
+		}
+		return &JsonWebSignature{Protected: parts[0], Payload: parts[1], Signature: parts[2]}, nil
+	}
+
+	var general jwsGeneral
+	if err := json.Unmarshal([]byte(input), &general); nil != err {
+		return nil, err
+	}
+	if nil != general.Signatures {
+		if 1 != len(general.Signatures) {
+			return nil, fmt.Errorf("Expected a single signature in JWS, got %d", len(general.Signatures))
+		}
+		return &JsonWebSignature{
+			Protected: general.Signatures[0].Protected,
+			Header:    general.Signatures[0].Header,
+			Payload:   general.Payload,
+			Signature: general.Signatures[0].Signature,
+		}, nil
+	}
+	var jws JsonWebSignature
+	if err := json.Unmarshal([]byte(input), &jws); nil != err {
+		return nil, err
+	} else if 0 == len(jws.Protected) || 0 == len(jws.Signature) {
+		return nil, MalformedJWS
+	}
+	return &jws, nil
+}
+
+func (jws *JsonWebSignature) FullSerialize() string {
+	data, err := json.Marshal(jws)
+	if nil != err {
+		// only strings and raw JSON from ParseJWS
+		panic(err)
+	}
+	return string(data)
+}
+
+func (jws *JsonWebSignature) CompactSerialize() (string, error) {
+	if 0 != len(jws.Header) {
+		return "", errors.New("JWS with unprotected header can't be serialized in compact form")
+	}
+	return jws.Protected + "." + jws.Payload + "." + jws.Signature, nil
+}
+
+// decoded protected header
+func (jws *JsonWebSignature) protectedHeader() (*jwsProtectedHeader, error) {
+	data, err := utils.Base64UrlDecode(jws.Protected)
+	if nil != err {
+		return nil, err
+	}
+	var header jwsProtectedHeader
+	if err := json.Unmarshal(data, &header); nil != err {
+		return nil, err
+	}
+	return &header, nil
+}
+
+// checks the signature was made with the given key (using the algorithm
+// for the key, see signatureAlgorithm) and returns the payload and the
+// nonce from the protected header
+func (jws *JsonWebSignature) Verify(publicKey crypto.PublicKey) ([]byte, string, error) {
+	header, err := jws.protectedHeader()
+	if nil != err {
+		return nil, "", err
+	}
+	alg := jose.SignatureAlgorithm(header.Algorithm)
+	if expected, err := signatureAlgorithm(publicKey); nil != err {
+		return nil, "", err
+	} else if expected != alg {
+		return nil, "", fmt.Errorf("Signature algorithm %s doesn't match the key (expected %s)", alg, expected)
+	}
+	hash, err := jwsHash(alg)
+	if nil != err {
+		return nil, "", err
+	}
+	signature, err := utils.Base64UrlDecode(jws.Signature)
+	if nil != err {
+		return nil, "", err
+	}
+	signingInput := []byte(jws.Protected + "." + jws.Payload)
+	var digest []byte
+	if 0 != hash {
+		h := hash.New()
+		h.Write(signingInput)
+		digest = h.Sum(nil)
+	}
+
+	valid := false
+	switch pubKey := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		size := (pubKey.Curve.Params().BitSize + 7) / 8
+		if 2*size == len(signature) {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			valid = ecdsa.Verify(pubKey, digest, r, s)
+		}
+	case *rsa.PublicKey:
+		valid = nil == rsa.VerifyPSS(pubKey, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(pubKey, signingInput, signature)
+	}
+	if !valid {
+		return nil, "", fmt.Errorf("Invalid %s signature", alg)
+	}
+
+	payload, err := utils.Base64UrlDecode(jws.Payload)
+	if nil != err {
+		return nil, "", err
+	}
+	return payload, header.Nonce, nil
+}
+
+func signJWS(signer crypto.Signer, alg jose.SignatureAlgorithm, payload []byte, nonce string) (*JsonWebSignature, error) {
+	jwk, err := jwkMembers(signer.Public())
+	if nil != err {
+		return nil, err
+	}
+	protected, err := json.Marshal(jwsProtectedHeader{
+		Algorithm: string(alg),
+		JWK:       json.RawMessage(jwk),
+		Nonce:     nonce,
+	})
+	if nil != err {
+		return nil, err
+	}
+
+	hash, err := jwsHash(alg)
+	if nil != err {
+		return nil, err
+	}
+	result := &JsonWebSignature{
+		Protected: utils.Base64UrlEncode(protected),
+		Payload:   utils.Base64UrlEncode(payload),
+	}
+	signingInput := []byte(result.Protected + "." + result.Payload)
+
+	var signature []byte
+	switch pubKey := signer.Public().(type) {
+	case *rsa.PublicKey:
+		h := hash.New()
+		h.Write(signingInput)
+		if signature, err = signer.Sign(rand.Reader, h.Sum(nil), &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+			Hash:       hash,
+		}); nil != err {
+			return nil, err
+		}
+	case *ecdsa.PublicKey:
+		// signers return ASN.1 DER, JWS wants fixed size R || S
+		var der []byte
+		if msgSigner, ok := signer.(MessageSigner); ok {
+			der, err = msgSigner.SignMessage(rand.Reader, signingInput, hash)
+		} else {
+			h := hash.New()
+			h.Write(signingInput)
+			der, err = signer.Sign(rand.Reader, h.Sum(nil), hash)
+		}
+		if nil != err {
+			return nil, err
+		}
+		var sig ecdsaSignature
+		if _, err := asn1.Unmarshal(der, &sig); nil != err {
+			return nil, fmt.Errorf("Invalid ECDSA signature from signer: %s", err)
+		}
+		size := (pubKey.Curve.Params().BitSize + 7) / 8
+		signature = append(utils.PaddedBytes(sig.R, size), utils.PaddedBytes(sig.S, size)...)
+	case ed25519.PublicKey:
+		// pure Ed25519 signs the message itself
+		if signature, err = signer.Sign(rand.Reader, signingInput, crypto.Hash(0)); nil != err {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unknown public key type %T", pubKey)
+	}
+	result.Signature = utils.Base64UrlEncode(signature)
+
+	return result, nil
+}
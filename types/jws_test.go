@@ -0,0 +1,126 @@
+package types
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	jose "github.com/letsencrypt/go-jose"
+	"github.com/stbuehler/go-acme-client/utils"
+	"strings"
+	"testing"
+)
+
+func testSigningKeys(t *testing.T) map[jose.SignatureAlgorithm]SigningKey {
+	keys := make(map[jose.SignatureAlgorithm]SigningKey)
+	for alg, spec := range map[jose.SignatureAlgorithm]utils.KeySpec{
+		jose.PS512: utils.KeySpecRSA2048,
+		jose.ES256: utils.KeySpecEC256,
+		jose.ES384: utils.KeySpecEC384,
+		jose.ES512: utils.KeySpecEC521,
+		EdDSA:      utils.KeySpecEd25519,
+	} {
+		skey, err := CreateSigningKey(spec)
+		if nil != err {
+			t.Fatal(err)
+		}
+		keys[alg] = skey
+	}
+	return keys
+}
+
+// protected header with a different algorithm, keeping the rest
+func withAlgorithm(t *testing.T, jws JsonWebSignature, alg string) JsonWebSignature {
+	header, err := jws.protectedHeader()
+	if nil != err {
+		t.Fatal(err)
+	}
+	header.Algorithm = alg
+	protected, err := json.Marshal(header)
+	if nil != err {
+		t.Fatal(err)
+	}
+	jws.Protected = utils.Base64UrlEncode(protected)
+	return jws
+}
+
+func TestSignVerify(t *testing.T) {
+	keys := testSigningKeys(t)
+	for alg, skey := range keys {
+		if actual, err := skey.GetSignatureAlgorithm(); nil != err || alg != actual {
+			t.Fatalf("Expected algorithm %s, got %s (%v)", alg, actual, err)
+		}
+		jws, err := skey.Sign([]byte(`{"resource":"test"}`), "nonce-1")
+		if nil != err {
+			t.Fatalf("%s: %s", alg, err)
+		}
+
+		compact, err := jws.CompactSerialize()
+		if nil != err {
+			t.Fatal(err)
+		}
+		for _, serialized := range []string{jws.FullSerialize(), compact} {
+			var payload []byte
+			var nonce string
+			if err := skey.PublicOnly().Verify(serialized, &payload, &nonce); nil != err {
+				t.Fatalf("%s: %s", alg, err)
+			}
+			if `{"resource":"test"}` != string(payload) || "nonce-1" != nonce {
+				t.Fatalf("%s: unexpected payload %q, nonce %q", alg, payload, nonce)
+			}
+		}
+
+		tampered := *jws
+		tampered.Payload = utils.Base64UrlEncode([]byte(`{"resource":"other"}`))
+		if _, _, err := tampered.Verify(skey.getPublicKey()); nil == err {
+			t.Fatalf("%s: accepted tampered payload", alg)
+		}
+		tampered = *jws
+		tampered.Signature = jws.Signature[:len(jws.Signature)-4]
+		if _, _, err := tampered.Verify(skey.getPublicKey()); nil == err {
+			t.Fatalf("%s: accepted truncated signature", alg)
+		}
+
+		for otherAlg, other := range keys {
+			if otherAlg == alg {
+				continue
+			}
+			if _, _, err := jws.Verify(other.getPublicKey()); nil == err {
+				t.Fatalf("%s signature verified with %s key", alg, otherAlg)
+			}
+		}
+	}
+}
+
+func TestVerifyAlgorithmMismatch(t *testing.T) {
+	keys := testSigningKeys(t)
+
+	// same curve size in the signature, but the key says ES384
+	jws, err := keys[jose.ES384].Sign([]byte("payload"), "")
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, alg := range []string{"ES256", "ES512", "none", ""} {
+		changed := withAlgorithm(t, *jws, alg)
+		if _, _, err := changed.Verify(keys[jose.ES384].getPublicKey()); nil == err || !strings.Contains(err.Error(), "doesn't match") {
+			t.Fatalf("Expected algorithm %#v to be rejected, got %v", alg, err)
+		}
+	}
+
+	// a valid PKCS#1 v1.5 signature is still not accepted for RSA keys
+	signer, err := keys[jose.PS512].getSigner()
+	if nil != err {
+		t.Fatal(err)
+	}
+	rs256 := withAlgorithm(t, *jws, "RS256")
+	digest := sha256.Sum256([]byte(rs256.Protected + "." + rs256.Payload))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, signer.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+	if nil != err {
+		t.Fatal(err)
+	}
+	rs256.Signature = utils.Base64UrlEncode(signature)
+	if _, _, err := rs256.Verify(keys[jose.PS512].getPublicKey()); nil == err || !strings.Contains(err.Error(), "doesn't match") {
+		t.Fatalf("Expected RS256 signature to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package types
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"sort"
+	"sync"
+)
+
+// KeyBackend provides registration keys living outside the database (in
+// an ssh-agent, a token, a remote signing service, ...); the database only
+// stores the backend name, a backend specific reference and the public key
+type KeyBackend interface {
+	// load the signer the reference points to
+	Load(UI ui.UserInterface, reference string) (crypto.Signer, error)
+}
+
+// backends which can create new keys (the reference names the new key)
+type KeyGeneratingBackend interface {
+	KeyBackend
+	Generate(UI ui.UserInterface, reference string, spec utils.KeySpec) (crypto.Signer, error)
+}
+
+var keyBackends = make(map[string]KeyBackend)
+
+// user interface for backends loading keys on first use (e.g. to prompt
+// for a PIN)
+var KeyBackendUI ui.UserInterface = ui.CLI
+
+func RegisterKeyBackend(name string, backend KeyBackend) {
+	if _, exists := keyBackends[name]; exists {
+		panic("Key backend " + name + " already registered")
+	}
+	keyBackends[name] = backend
+}
+
+func KeyBackendNames() []string {
+	var names []string
+	for name := range keyBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func getKeyBackend(backendName string) (KeyBackend, error) {
+	backend, ok := keyBackends[backendName]
+	if !ok {
+		return nil, fmt.Errorf("Unknown key backend %#v", backendName)
+	}
+	return backend, nil
+}
+
+// load a key (registration or certificate key) from a backend
+func LoadBackendKey(UI ui.UserInterface, backendName string, reference string) (crypto.Signer, error) {
+	backend, err := getKeyBackend(backendName)
+	if nil != err {
+		return nil, err
+	}
+	return backend.Load(UI, reference)
+}
+
+// create a new key (registration or certificate key) in a backend
+func GenerateBackendKey(UI ui.UserInterface, backendName string, reference string, spec utils.KeySpec) (crypto.Signer, error) {
+	backend, err := getKeyBackend(backendName)
+	if nil != err {
+		return nil, err
+	}
+	generator, ok := backend.(KeyGeneratingBackend)
+	if !ok {
+		return nil, fmt.Errorf("Key backend %s can't generate keys", backendName)
+	}
+	return generator.Generate(UI, reference, spec)
+}
+
+// PEM block to store a reference to a backend key instead of the key
+// itself (also used for certificate keys)
+func EncodeKeyReference(backendName string, reference string, publicKey crypto.PublicKey) (*pem.Block, error) {
+	return (&externalKey{
+		backend:   backendName,
+		reference: reference,
+		publicKey: publicKey,
+	}).encode()
+}
+
+// NewExternalSigningKey loads the key from the backend right away (a new
+// registration needs to be signed anyway) and remembers the reference
+func NewExternalSigningKey(UI ui.UserInterface, backendName string, reference string) (SigningKey, error) {
+	signer, err := LoadBackendKey(UI, backendName, reference)
+	if nil != err {
+		return SigningKey{}, err
+	}
+	return newExternalSigningKey(backendName, reference, signer)
+}
+
+// GenerateExternalSigningKey creates a new registration key in the backend
+func GenerateExternalSigningKey(UI ui.UserInterface, backendName string, reference string, spec utils.KeySpec) (SigningKey, error) {
+	signer, err := GenerateBackendKey(UI, backendName, reference, spec)
+	if nil != err {
+		return SigningKey{}, err
+	}
+	return newExternalSigningKey(backendName, reference, signer)
+}
+
+func newExternalSigningKey(backendName string, reference string, signer crypto.Signer) (SigningKey, error) {
+	// check type is supported and key isn't weak
+	if _, err := NewSigningKey(signer); nil != err {
+		return SigningKey{}, err
+	}
+	return SigningKey{external: &externalKey{
+		backend:   backendName,
+		reference: reference,
+		publicKey: signer.Public(),
+		signer:    signer,
+	}}, nil
+}
+
+// the backend name and reference of a key from a KeyBackend
+func (skey SigningKey) ExternalReference() (backend string, reference string, ok bool) {
+	if nil == skey.external {
+		return "", "", false
+	}
+	return skey.external.backend, skey.external.reference, true
+}
+
+type externalKey struct {
+	backend   string
+	reference string
+	publicKey crypto.PublicKey
+
+	mutex  sync.Mutex
+	signer crypto.Signer // loaded on first use
+}
+
+type rawExternalKey struct {
+	Backend   string
+	Reference string
+	PublicKey []byte // PKIX DER
+}
+
+func (ekey *externalKey) getSigner() (crypto.Signer, error) {
+	ekey.mutex.Lock()
+	defer ekey.mutex.Unlock()
+	if nil != ekey.signer {
+		return ekey.signer, nil
+	}
+	signer, err := LoadBackendKey(KeyBackendUI, ekey.backend, ekey.reference)
+	if nil != err {
+		return nil, err
+	}
+	expected, err := utils.PublicKeyFingerprint(ekey.publicKey)
+	if nil != err {
+		return nil, err
+	}
+	if actual, err := utils.PublicKeyFingerprint(signer.Public()); nil != err {
+		return nil, err
+	} else if expected != actual {
+		return nil, fmt.Errorf("Key %#v from backend %s doesn't match the stored public key (expected %s, found %s)",
+			ekey.reference, ekey.backend, expected, actual)
+	}
+	ekey.signer = signer
+	return signer, nil
+}
+
+func (ekey *externalKey) encode() (*pem.Block, error) {
+	publicKey, err := x509.MarshalPKIXPublicKey(ekey.publicKey)
+	if nil != err {
+		return nil, err
+	}
+	data, err := json.Marshal(rawExternalKey{
+		Backend:   ekey.backend,
+		Reference: ekey.reference,
+		PublicKey: publicKey,
+	})
+	if nil != err {
+		return nil, err
+	}
+	return &pem.Block{
+		Type:  pemTypeAcmeKeyReference,
+		Bytes: data,
+	}, nil
+}
+
+func decodeExternalKey(block pem.Block) (*externalKey, error) {
+	var raw rawExternalKey
+	if err := json.Unmarshal(block.Bytes, &raw); nil != err {
+		return nil, err
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(raw.PublicKey)
+	if nil != err {
+		return nil, err
+	}
+	return &externalKey{
+		backend:   raw.Backend,
+		reference: raw.Reference,
+		publicKey: publicKey,
+	}, nil
+}
@@ -0,0 +1,72 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// what happens to the private key of a certificate when it gets reissued:
+// "rotate" creates a new key each time (default), "reuse" keeps it (e.g.
+// for pinned keys or TLSA records), "rotate-N" uses a key for N
+// certificates
+type KeyPolicy string
+
+const (
+	KeyPolicyRotate = KeyPolicy("rotate")
+	KeyPolicyReuse  = KeyPolicy("reuse")
+)
+
+func ParseKeyPolicy(s string) (KeyPolicy, error) {
+	policy := KeyPolicy(s)
+	if _, err := policy.rotateAfter(); nil != err {
+		return "", err
+	}
+	return policy, nil
+}
+
+// number of certificates a key gets used for; 0 for unlimited
+func (policy KeyPolicy) rotateAfter() (int, error) {
+	switch policy {
+	case "", KeyPolicyRotate:
+		return 1, nil
+	case KeyPolicyReuse:
+		return 0, nil
+	}
+	if s := string(policy); strings.HasPrefix(s, "rotate-") {
+		if n, err := strconv.Atoi(s[len("rotate-"):]); nil == err && n > 0 {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("Invalid key policy %#v (expected rotate, reuse or rotate-N)", string(policy))
+}
+
+// whether a key already used for keyUses certificates should be used
+// for the next certificate too
+func (policy KeyPolicy) ReuseKey(keyUses int) bool {
+	n, err := policy.rotateAfter()
+	if nil != err {
+		return false
+	}
+	if keyUses < 1 {
+		keyUses = 1
+	}
+	return 0 == n || keyUses < n
+}
+
+func (policy KeyPolicy) String() string {
+	if 0 == len(policy) {
+		return string(KeyPolicyRotate)
+	}
+	return string(policy)
+}
+
+// implement flag.Value
+func (policy *KeyPolicy) Set(value string) error {
+	p, err := ParseKeyPolicy(value)
+	if nil != err {
+		return err
+	}
+	*policy = p
+	return nil
+}
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestParseKeyPolicy(t *testing.T) {
+	for _, test := range []struct {
+		policy      string
+		rotateAfter int
+		valid       bool
+	}{
+		{"", 1, true},
+		{"rotate", 1, true},
+		{"reuse", 0, true},
+		{"rotate-1", 1, true},
+		{"rotate-5", 5, true},
+		{"rotate-0", 0, false},
+		{"rotate--1", 0, false},
+		{"rotate-", 0, false},
+		{"rotate-x", 0, false},
+		{"Reuse", 0, false},
+		{"never", 0, false},
+	} {
+		policy, err := ParseKeyPolicy(test.policy)
+		if test.valid != (nil == err) {
+			t.Fatalf("ParseKeyPolicy(%#v): unexpected error %v", test.policy, err)
+		}
+		if !test.valid {
+			continue
+		}
+		if n, err := policy.rotateAfter(); nil != err || test.rotateAfter != n {
+			t.Fatalf("%#v: expected rotation after %d certificates, got %d (%v)", test.policy, test.rotateAfter, n, err)
+		}
+	}
+}
+
+func TestReuseKey(t *testing.T) {
+	for _, test := range []struct {
+		policy  KeyPolicy
+		keyUses int
+		reuse   bool
+	}{
+		{"", 0, false},
+		{"", 1, false},
+		{KeyPolicyRotate, 1, false},
+		{KeyPolicyReuse, 0, true},
+		{KeyPolicyReuse, 1, true},
+		{KeyPolicyReuse, 100, true},
+		// unknown uses (0) count as one
+		{"rotate-1", 0, false},
+		{"rotate-2", 0, true},
+		{"rotate-2", 1, true},
+		{"rotate-2", 2, false},
+		{"rotate-3", 2, true},
+		{"rotate-3", 3, false},
+		{"rotate-3", 4, false},
+		// invalid policies never reuse
+		{"rotate-0", 1, false},
+		{"bogus", 1, false},
+	} {
+		if reuse := test.policy.ReuseKey(test.keyUses); test.reuse != reuse {
+			t.Fatalf("%#v with %d uses: expected reuse %v", string(test.policy), test.keyUses, test.reuse)
+		}
+	}
+}
+
+func TestKeyPolicyFlag(t *testing.T) {
+	var policy KeyPolicy
+	if "rotate" != policy.String() {
+		t.Fatalf("Unexpected default %s", policy.String())
+	}
+	if err := policy.Set("rotate-3"); nil != err || "rotate-3" != policy.String() {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := policy.Set("rotate-none"); nil == err || "rotate-3" != policy.String() {
+		t.Fatalf("Invalid policy accepted or changed the value: %v", err)
+	}
+}
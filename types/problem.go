@@ -0,0 +1,78 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// problem document (application/problem+json) describing why the server
+// rejected something
+type Problem struct {
+	Type   string `json:"type,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	Status int    `json:"status,omitempty"`
+}
+
+func (problem *Problem) String() string {
+	if 0 == len(problem.Detail) {
+		return problem.Type
+	}
+	return fmt.Sprintf("%s: %s", problem.Type, problem.Detail)
+}
+
+// servers send the port either as number or as string
+type ValidationPort string
+
+func (port *ValidationPort) UnmarshalJSON(data []byte) error {
+	var number json.Number
+	if err := json.Unmarshal(data, &number); nil == err {
+		*port = ValidationPort(number.String())
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); nil != err {
+		return err
+	}
+	*port = ValidationPort(str)
+	return nil
+}
+
+// what the server did to validate a challenge
+type ValidationRecord struct {
+	Hostname          string         `json:"hostname,omitempty"`
+	Port              ValidationPort `json:"port,omitempty"`
+	AddressesResolved []string       `json:"addressesResolved,omitempty"`
+	AddressUsed       string         `json:"addressUsed,omitempty"`
+	URL               string         `json:"url,omitempty"`
+}
+
+func (record ValidationRecord) String() string {
+	var parts []string
+	if 0 != len(record.URL) {
+		parts = append(parts, record.URL)
+	}
+	if 0 != len(record.Hostname) {
+		parts = append(parts, fmt.Sprintf("host %s port %s", record.Hostname, record.Port))
+	}
+	if 0 != len(record.AddressesResolved) {
+		parts = append(parts, fmt.Sprintf("resolved to %s", strings.Join(record.AddressesResolved, ", ")))
+	}
+	if 0 != len(record.AddressUsed) {
+		parts = append(parts, fmt.Sprintf("used %s", record.AddressUsed))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ValidationDetails describes the server's error and validation records of
+// a challenge (one line each); empty if there are none
+func (challenge *Challenge) ValidationDetails() []string {
+	var lines []string
+	if problem := challenge.GetError(); nil != problem {
+		lines = append(lines, fmt.Sprintf("Error: %s", problem))
+	}
+	for _, record := range challenge.GetValidationRecords() {
+		lines = append(lines, fmt.Sprintf("Validation: %s", record))
+	}
+	return lines
+}
@@ -1,73 +1,193 @@
 package types
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
+	"fmt"
 	jose "github.com/letsencrypt/go-jose"
 	"github.com/stbuehler/go-acme-client/utils"
+	"math/big"
 )
 
 // wrapper to marshal/unmarshal json
 type JSONSignature struct {
-	Signature *jose.JsonWebSignature
+	Signature *JsonWebSignature
 }
 
+// the signer can be a raw *rsa.PrivateKey / *ecdsa.PrivateKey /
+// ed25519.PrivateKey or anything
+// else implementing crypto.Signer (see KeyBackend for keys living outside
+// the database)
 type SigningKey struct {
-	privateKey interface{}
+	signer crypto.Signer
+	// only set for keys kept offline (signer and external are nil then)
+	publicKey crypto.PublicKey
+	// only set for keys from a KeyBackend (signer is nil then)
+	external *externalKey
 }
 
-func (skey SigningKey) GetSignatureAlgorithm() jose.SignatureAlgorithm {
-	switch pkey := skey.privateKey.(type) {
-	case *ecdsa.PrivateKey:
+// signs payloads without nonce (challenge validation objects) for keys
+// kept offline; set by the offline bundle workflow
+var DetachedSigner func(skey SigningKey, payload []byte) (*JsonWebSignature, error)
+
+var PrivateKeyNotAvailable = errors.New("Private key not available (kept offline)")
+var UnsupportedRegistrationKey = errors.New("Only RSA, ECDSA and Ed25519 keys can be used for registrations")
+
+func (skey SigningKey) getPublicKey() crypto.PublicKey {
+	if nil != skey.signer {
+		return skey.signer.Public()
+	} else if nil != skey.external {
+		return skey.external.publicKey
+	}
+	return skey.publicKey
+}
+
+// whether the private key is available for signing
+func (skey SigningKey) CanSign() bool {
+	return nil != skey.signer || nil != skey.external
+}
+
+func (skey SigningKey) getSigner() (crypto.Signer, error) {
+	if nil != skey.signer {
+		return skey.signer, nil
+	} else if nil != skey.external {
+		return skey.external.getSigner()
+	}
+	return nil, PrivateKeyNotAvailable
+}
+
+// copy of the key without the private part
+func (skey SigningKey) PublicOnly() SigningKey {
+	return SigningKey{publicKey: skey.getPublicKey()}
+}
+
+// JWS algorithm used for the public key; signatures made with other
+// algorithms are not accepted
+func signatureAlgorithm(publicKey crypto.PublicKey) (jose.SignatureAlgorithm, error) {
+	switch pkey := publicKey.(type) {
+	case *ecdsa.PublicKey:
 		switch pkey.Curve {
-		case elliptic.P224():
-			return jose.ES256
 		case elliptic.P256():
-			return jose.ES256
+			return jose.ES256, nil
 		case elliptic.P384():
-			return jose.ES384
+			return jose.ES384, nil
 		case elliptic.P521():
-			return jose.ES512
+			return jose.ES512, nil
 		default:
-			panic("Unknown elliptic curve")
+			return "", fmt.Errorf("Unsupported elliptic curve %s", pkey.Curve.Params().Name)
 		}
-	case *rsa.PrivateKey:
-		return jose.PS512
+	case *rsa.PublicKey:
+		return jose.PS512, nil
+	case ed25519.PublicKey:
+		return EdDSA, nil
 	default:
-		panic("Unkown private key type")
+		return "", fmt.Errorf("Unknown public key type %T", pkey)
 	}
 }
 
-func (skey SigningKey) GetPublicKey() *jose.JsonWebKey {
-	return &jose.JsonWebKey{
-		Key:       utils.MustPublicKey(skey.privateKey),
-		Algorithm: string(skey.GetSignatureAlgorithm()),
+func (skey SigningKey) GetSignatureAlgorithm() (jose.SignatureAlgorithm, error) {
+	return signatureAlgorithm(skey.getPublicKey())
+}
+
+// required JWK members in lexicographic order (RFC 7638), also used as
+// "jwk" header when signing
+func jwkMembers(publicKey crypto.PublicKey) (string, error) {
+	switch pubKey := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		size := (pubKey.Curve.Params().BitSize + 7) / 8
+		return fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`,
+			pubKey.Curve.Params().Name,
+			utils.Base64UrlEncode(utils.PaddedBytes(pubKey.X, size)),
+			utils.Base64UrlEncode(utils.PaddedBytes(pubKey.Y, size))), nil
+	case *rsa.PublicKey:
+		return fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`,
+			utils.Base64UrlEncode(big.NewInt(int64(pubKey.E)).Bytes()),
+			utils.Base64UrlEncode(pubKey.N.Bytes())), nil
+	case ed25519.PublicKey:
+		// RFC 8037 section 2
+		return fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`,
+			utils.Base64UrlEncode(pubKey)), nil
+	default:
+		return "", fmt.Errorf("Unknown public key type %T", pubKey)
+	}
+}
+
+// RFC 7638 thumbprint (SHA-256, base64url encoded) of the public key
+func (skey SigningKey) Thumbprint() (string, error) {
+	members, err := jwkMembers(skey.getPublicKey())
+	if nil != err {
+		return "", err
 	}
+	digest := sha256.Sum256([]byte(members))
+	return utils.Base64UrlEncode(digest[:]), nil
 }
+
+// exports only the public key for keys kept offline, and only the
+// reference for keys from a KeyBackend
 func (skey SigningKey) EncryptPrivateKey(password string, alg x509.PEMCipher) (*pem.Block, error) {
-	return utils.EncryptPrivateKey(skey.privateKey, password, alg)
+	var block *pem.Block
+	var err error
+	if nil != skey.external {
+		block, err = skey.external.encode()
+	} else if nil == skey.signer {
+		block, err = utils.EncodePublicKey(skey.publicKey)
+	} else {
+		block, err = utils.EncodePrivateKey(skey.signer)
+	}
+	if nil != err {
+		return nil, err
+	}
+	if err := utils.EncryptPemBlock(block, password, alg); nil != err {
+		return nil, err
+	}
+	return block, nil
+}
+
+// private key file in the given format (see utils.ExportPrivateKey); only
+// possible for keys stored in the database
+func (skey SigningKey) ExportPrivateKey(format utils.KeyFormat, password string) ([]byte, error) {
+	if nil != skey.external {
+		return nil, fmt.Errorf("Key is kept in key backend %s", skey.external.backend)
+	} else if nil == skey.signer {
+		return nil, PrivateKeyNotAvailable
+	}
+	return utils.ExportPrivateKey(skey.signer, format, password)
 }
 
-func (skey SigningKey) Sign(payload []byte, nonce string) (*jose.JsonWebSignature, error) {
-	signer, err := jose.NewSigner(skey.GetSignatureAlgorithm(), skey.privateKey)
+func (skey SigningKey) Sign(payload []byte, nonce string) (*JsonWebSignature, error) {
+	if !skey.CanSign() {
+		if 0 == len(nonce) && nil != DetachedSigner {
+			return DetachedSigner(skey, payload)
+		}
+		return nil, PrivateKeyNotAvailable
+	}
+	alg, err := skey.GetSignatureAlgorithm()
+	if nil != err {
+		return nil, err
+	}
+	signer, err := skey.getSigner()
 	if nil != err {
 		return nil, err
 	}
-	return signer.Sign(payload, nonce)
+	return signJWS(signer, alg, payload, nonce)
 }
 
 func (skey SigningKey) Verify(signature string, payload *[]byte, nonce *string) error {
-	if sig, err := jose.ParseSigned(signature); nil != err {
+	if sig, err := ParseJWS(signature); nil != err {
 		return err
-	} else if sigPayload, sigHeader, err := sig.Verify(skey.GetPublicKey()); nil != err {
+	} else if sigPayload, sigNonce, err := sig.Verify(skey.getPublicKey()); nil != err {
 		return err
 	} else {
 		if nil != nonce {
-			*nonce = sigHeader.Nonce
+			*nonce = sigNonce
 		}
 		if nil != payload {
 			*payload = sigPayload
@@ -76,24 +196,63 @@ func (skey SigningKey) Verify(signature string, payload *[]byte, nonce *string)
 	}
 }
 
-func CreateSigningKey(keyType utils.KeyType, curve utils.Curve, rsaBits *int) (SigningKey, error) {
-	pkey, err := utils.CreatePrivateKey(keyType, curve, rsaBits)
+func CreateSigningKey(spec utils.KeySpec) (SigningKey, error) {
+	pkey, err := utils.CreateKeySpecPrivateKey(spec)
 	if nil != err {
 		return SigningKey{}, err
 	}
-	return SigningKey{privateKey: pkey}, nil
+	return NewSigningKey(pkey.(crypto.Signer))
+}
+
+// refuses weak keys (see utils.CheckKey)
+func NewSigningKey(signer crypto.Signer) (SigningKey, error) {
+	if err := utils.CheckKey(signer.Public()); nil != err {
+		return SigningKey{}, err
+	}
+	switch signer.Public().(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return SigningKey{signer: signer}, nil
+	default:
+		return SigningKey{}, UnsupportedRegistrationKey
+	}
 }
 
+// accepts public keys (for keys kept offline) and key references (for keys
+// from a KeyBackend) too; all are checked like in NewSigningKey
 func LoadSigningKey(block pem.Block) (SigningKey, error) {
+	switch block.Type {
+	case pemTypePublicKey:
+		publicKey, err := utils.DecodePublicKey(block)
+		if nil != err {
+			return SigningKey{}, err
+		}
+		return checkedPublicKey(SigningKey{publicKey: publicKey})
+	case pemTypeAcmeKeyReference:
+		external, err := decodeExternalKey(block)
+		if nil != err {
+			return SigningKey{}, err
+		}
+		return checkedPublicKey(SigningKey{external: external})
+	}
 	privateKey, err := utils.DecodePrivateKey(block)
 	if nil != err {
 		return SigningKey{}, err
 	}
-	return SigningKey{privateKey: privateKey}, nil
+	return NewSigningKey(privateKey.(crypto.Signer))
+}
+
+func checkedPublicKey(skey SigningKey) (SigningKey, error) {
+	if err := utils.CheckKey(skey.getPublicKey()); nil != err {
+		return SigningKey{}, err
+	}
+	if _, err := skey.GetSignatureAlgorithm(); nil != err {
+		return SigningKey{}, UnsupportedRegistrationKey
+	}
+	return skey, nil
 }
 
 func (sig JSONSignature) MarshalJSON() ([]byte, error) {
-	if nil == sig.Signature || 0 == len(sig.Signature.Signatures) {
+	if nil == sig.Signature {
 		return json.Marshal(nil)
 	}
 	return []byte(sig.Signature.FullSerialize()), nil
@@ -101,7 +260,7 @@ func (sig JSONSignature) MarshalJSON() ([]byte, error) {
 
 func (sig *JSONSignature) UnmarshalJSON(data []byte) error {
 	sig.Signature = nil
-	if s, err := jose.ParseSigned(string(data)); nil != err {
+	if s, err := ParseJWS(string(data)); nil != err {
 		return err
 	} else {
 		sig.Signature = s
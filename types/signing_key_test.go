@@ -0,0 +1,42 @@
+package types
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"github.com/stbuehler/go-acme-client/utils"
+	"testing"
+)
+
+func TestLoadSigningKeyChecksPublicKey(t *testing.T) {
+	p224Key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	block, err := utils.EncodePublicKey(&p224Key.PublicKey)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := LoadSigningKey(*block); nil == err {
+		t.Fatal("Loaded offline key on unsupported curve P-224")
+	}
+	if _, err := (SigningKey{publicKey: &p224Key.PublicKey}).Sign([]byte("payload"), "nonce"); nil == err {
+		t.Fatal("Signed with key on unsupported curve P-224")
+	}
+
+	skey, err := CreateSigningKey(utils.KeySpecEC256)
+	if nil != err {
+		t.Fatal(err)
+	}
+	block, err = skey.PublicOnly().EncryptPrivateKey("", 0)
+	if nil != err {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSigningKey(*block)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if loaded.CanSign() {
+		t.Fatal("Offline key can sign")
+	}
+}
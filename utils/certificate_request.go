@@ -14,13 +14,19 @@ type CertificateRequestParameters struct {
 	DefaultSignatureAlgorithm x509.SignatureAlgorithm
 	Subject                   pkix.Name
 	DNSNames                  []string
+	// for S/MIME certificates
+	EmailAddresses []string
 }
 
+// refuses weak keys (see CheckKey)
 func MakeCertificateRequest(parameters CertificateRequestParameters) (*pem.Block, error) {
 	publicKey, err := PublicKey(parameters.PrivateKey)
 	if nil != err {
 		return nil, err
 	}
+	if err := CheckKey(publicKey); nil != err {
+		return nil, err
+	}
 	if x509.UnknownSignatureAlgorithm == parameters.DefaultSignatureAlgorithm {
 		parameters.DefaultSignatureAlgorithm = x509.SHA256WithRSA
 	}
@@ -29,6 +35,8 @@ func MakeCertificateRequest(parameters CertificateRequestParameters) (*pem.Block
 	if 0 == len(parameters.Subject.CommonName) {
 		if 0 != len(parameters.DNSNames) {
 			parameters.Subject.CommonName = parameters.DNSNames[0]
+		} else if 0 != len(parameters.EmailAddresses) {
+			parameters.Subject.CommonName = parameters.EmailAddresses[0]
 		}
 	}
 
@@ -37,6 +45,7 @@ func MakeCertificateRequest(parameters CertificateRequestParameters) (*pem.Block
 		PublicKey:          publicKey,
 		Subject:            parameters.Subject,
 		DNSNames:           parameters.DNSNames,
+		EmailAddresses:     parameters.EmailAddresses,
 	}
 
 	csr, err := x509.CreateCertificateRequest(rand.Reader, &req, parameters.PrivateKey)
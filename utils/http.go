@@ -93,7 +93,8 @@ func (req *HttpRequest) Run() (*HttpResponse, error) {
 	DebugLogHttpResponse(&resp)
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("HTTP error code: %s", resp.Status)
+		// the response is still returned for callers interested in the body
+		return &resp, fmt.Errorf("HTTP error code: %s", resp.Status)
 	}
 
 	return &resp, nil
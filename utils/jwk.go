@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// private keys as JSON Web Key (RFC 7517, RFC 7518 section 6)
+
+var InvalidJWK = errors.New("Invalid JSON Web Key")
+
+type rawJWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	D   string `json:"d,omitempty"`
+	P   string `json:"p,omitempty"`
+	Q   string `json:"q,omitempty"`
+	Dp  string `json:"dp,omitempty"`
+	Dq  string `json:"dq,omitempty"`
+	Qi  string `json:"qi,omitempty"`
+}
+
+func jwkBigInt(s string) (*big.Int, error) {
+	if 0 == len(s) {
+		return nil, InvalidJWK
+	}
+	data, err := Base64UrlDecode(s)
+	if nil != err {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// big-endian, left padded with zeroes to size bytes (fixed size JWK
+// members and JWS ECDSA signatures)
+func PaddedBytes(n *big.Int, size int) []byte {
+	raw := n.Bytes()
+	if len(raw) >= size {
+		return raw
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(raw):], raw)
+	return padded
+}
+
+func jwkCurve(crv string) (elliptic.Curve, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("Unsupported JWK curve %#v", crv)
+	}
+}
+
+func MarshalJWKPrivateKey(privateKey interface{}) ([]byte, error) {
+	switch pkey := privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		params := pkey.Curve.Params()
+		if _, err := jwkCurve(params.Name); nil != err {
+			return nil, err
+		}
+		size := (params.BitSize + 7) / 8
+		return json.Marshal(rawJWK{
+			Kty: "EC",
+			Crv: params.Name,
+			X:   Base64UrlEncode(PaddedBytes(pkey.X, size)),
+			Y:   Base64UrlEncode(PaddedBytes(pkey.Y, size)),
+			D:   Base64UrlEncode(PaddedBytes(pkey.D, size)),
+		})
+	case *rsa.PrivateKey:
+		if 2 != len(pkey.Primes) {
+			return nil, fmt.Errorf("JWK export of multi-prime RSA keys not supported")
+		}
+		pkey.Precompute()
+		return json.Marshal(rawJWK{
+			Kty: "RSA",
+			N:   Base64UrlEncode(pkey.N.Bytes()),
+			E:   Base64UrlEncode(big.NewInt(int64(pkey.E)).Bytes()),
+			D:   Base64UrlEncode(pkey.D.Bytes()),
+			P:   Base64UrlEncode(pkey.Primes[0].Bytes()),
+			Q:   Base64UrlEncode(pkey.Primes[1].Bytes()),
+			Dp:  Base64UrlEncode(pkey.Precomputed.Dp.Bytes()),
+			Dq:  Base64UrlEncode(pkey.Precomputed.Dq.Bytes()),
+			Qi:  Base64UrlEncode(pkey.Precomputed.Qinv.Bytes()),
+		})
+	case ed25519.PrivateKey:
+		return json.Marshal(rawJWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   Base64UrlEncode(pkey.Public().(ed25519.PublicKey)),
+			D:   Base64UrlEncode(pkey.Seed()),
+		})
+	default:
+		return nil, UnknownPrivateKey
+	}
+}
+
+func ParseJWKPrivateKey(data []byte) (interface{}, error) {
+	var jwk rawJWK
+	if err := json.Unmarshal(data, &jwk); nil != err {
+		return nil, err
+	}
+	if 0 == len(jwk.D) {
+		return nil, fmt.Errorf("JWK doesn't contain a private key")
+	}
+	switch jwk.Kty {
+	case "EC":
+		curve, err := jwkCurve(jwk.Crv)
+		if nil != err {
+			return nil, err
+		}
+		pkey := &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve}}
+		if pkey.X, err = jwkBigInt(jwk.X); nil != err {
+			return nil, err
+		} else if pkey.Y, err = jwkBigInt(jwk.Y); nil != err {
+			return nil, err
+		} else if pkey.D, err = jwkBigInt(jwk.D); nil != err {
+			return nil, err
+		}
+		if !curve.IsOnCurve(pkey.X, pkey.Y) {
+			return nil, InvalidJWK
+		}
+		if x, y := curve.ScalarBaseMult(pkey.D.Bytes()); 0 != x.Cmp(pkey.X) || 0 != y.Cmp(pkey.Y) {
+			return nil, InvalidJWK
+		}
+		return pkey, nil
+	case "RSA":
+		n, err := jwkBigInt(jwk.N)
+		if nil != err {
+			return nil, err
+		}
+		e, err := jwkBigInt(jwk.E)
+		if nil != err {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, InvalidJWK
+		}
+		d, err := jwkBigInt(jwk.D)
+		if nil != err {
+			return nil, err
+		}
+		p, err := jwkBigInt(jwk.P)
+		if nil != err {
+			return nil, fmt.Errorf("JWK RSA keys need the prime factors p and q")
+		}
+		q, err := jwkBigInt(jwk.Q)
+		if nil != err {
+			return nil, fmt.Errorf("JWK RSA keys need the prime factors p and q")
+		}
+		pkey := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		if err := pkey.Validate(); nil != err {
+			return nil, err
+		}
+		pkey.Precompute()
+		return pkey, nil
+	case "OKP":
+		if "Ed25519" != jwk.Crv {
+			return nil, fmt.Errorf("Unsupported JWK curve %#v", jwk.Crv)
+		}
+		seed, err := Base64UrlDecode(jwk.D)
+		if nil != err {
+			return nil, err
+		}
+		if ed25519.SeedSize != len(seed) {
+			return nil, InvalidJWK
+		}
+		pkey := ed25519.NewKeyFromSeed(seed)
+		if x, err := Base64UrlDecode(jwk.X); nil != err {
+			return nil, err
+		} else if !pkey.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(x)) {
+			return nil, InvalidJWK
+		}
+		return pkey, nil
+	default:
+		return nil, fmt.Errorf("Unsupported JWK key type %#v", jwk.Kty)
+	}
+}
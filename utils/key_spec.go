@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// KeySpec describes how to generate a key: "rsa2048", "rsa4096" (any size
+// in 2048..4096), "ec256", "ec384", "ec521" or "ed25519"
+type KeySpec string
+
+const (
+	KeySpecRSA2048 KeySpec = "rsa2048"
+	KeySpecRSA4096 KeySpec = "rsa4096"
+	KeySpecEC256   KeySpec = "ec256"
+	KeySpecEC384   KeySpec = "ec384"
+	KeySpecEC521   KeySpec = "ec521"
+	KeySpecEd25519 KeySpec = "ed25519"
+)
+
+var UnknownKeySpec = errors.New("Unknown Key Spec (use rsa2048, rsa4096, ec256, ec384, ec521 or ed25519)")
+
+// split into the parameters for CreatePrivateKey
+func (spec KeySpec) Parse() (keyType KeyType, curve Curve, rsaBits int, err error) {
+	switch s := string(spec); {
+	case strings.HasPrefix(s, "rsa"):
+		bits, err := strconv.Atoi(s[3:])
+		if nil != err {
+			return "", "", 0, UnknownKeySpec
+		}
+		if bits < 2048 || bits > 4096 {
+			return "", "", 0, InvalidRsaBits
+		}
+		return KeyRSA, "", bits, nil
+	case KeySpecEC256 == spec:
+		return KeyEcdsa, CurveP256, 0, nil
+	case KeySpecEC384 == spec:
+		return KeyEcdsa, CurveP384, 0, nil
+	case KeySpecEC521 == spec:
+		return KeyEcdsa, CurveP521, 0, nil
+	case KeySpecEd25519 == spec:
+		return KeyEd25519, "", 0, nil
+	default:
+		return "", "", 0, UnknownKeySpec
+	}
+}
+
+func MakeKeySpec(keyType KeyType, curve Curve, rsaBits int) (KeySpec, error) {
+	switch keyType {
+	case KeyRSA:
+		return KeySpec(fmt.Sprintf("rsa%d", rsaBits)), nil
+	case KeyEcdsa:
+		switch curve {
+		case CurveP256:
+			return KeySpecEC256, nil
+		case CurveP384:
+			return KeySpecEC384, nil
+		case CurveP521, curveDefault:
+			return KeySpecEC521, nil
+		default:
+			return "", UnknownCurve
+		}
+	case KeyEd25519:
+		return KeySpecEd25519, nil
+	default:
+		return "", UnknownKeyType
+	}
+}
+
+// the spec that generates keys like the given (public or private) key
+func KeySpecOf(key interface{}) (KeySpec, error) {
+	publicKey, err := PublicKey(key)
+	if nil != err {
+		return "", err
+	}
+	switch pubKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		return MakeKeySpec(KeyRSA, "", pubKey.N.BitLen())
+	case *ecdsa.PublicKey:
+		return MakeKeySpec(KeyEcdsa, Curve(pubKey.Curve.Params().Name), 0)
+	case ed25519.PublicKey:
+		return KeySpecEd25519, nil
+	default:
+		return "", UnknownPrivateKey
+	}
+}
+
+func CreateKeySpecPrivateKey(spec KeySpec) (interface{}, error) {
+	keyType, curve, rsaBits, err := spec.Parse()
+	if nil != err {
+		return nil, err
+	}
+	return CreatePrivateKey(keyType, curve, &rsaBits)
+}
+
+func (spec KeySpec) IsValid() bool {
+	_, _, _, err := spec.Parse()
+	return nil == err
+}
+
+func (spec *KeySpec) String() string {
+	return string(*spec)
+}
+
+func (spec *KeySpec) Set(v string) error {
+	s := KeySpec(strings.ToLower(v))
+	if _, _, _, err := s.Parse(); nil != err {
+		return err
+	}
+	*spec = s
+	return nil
+}
+
+// -key-spec flag (a comma separated list where multiple keys are
+// supported), and the older -key-type, -curve and -rsa-bits flags
+type KeySpecFlags struct {
+	specs   []KeySpec
+	specSet bool
+	keyType KeyType
+	curve   Curve
+	rsaBits int
+}
+
+type keySpecFlag struct {
+	f *KeySpecFlags
+}
+
+func (kf keySpecFlag) String() string {
+	if nil == kf.f {
+		return ""
+	}
+	var specs []string
+	for _, spec := range kf.f.specs {
+		specs = append(specs, string(spec))
+	}
+	return strings.Join(specs, ",")
+}
+
+func (kf keySpecFlag) Set(v string) error {
+	var specs []KeySpec
+	seen := make(map[KeySpec]bool)
+	for _, s := range strings.Split(v, ",") {
+		var spec KeySpec
+		if err := spec.Set(strings.TrimSpace(s)); nil != err {
+			return err
+		}
+		// one certificate per spec
+		if !seen[spec] {
+			seen[spec] = true
+			specs = append(specs, spec)
+		}
+	}
+	kf.f.specs = specs
+	kf.f.specSet = true
+	return nil
+}
+
+func AddKeySpecFlags(flagset *flag.FlagSet, defaultSpec KeySpec) *KeySpecFlags {
+	f := &KeySpecFlags{specs: []KeySpec{defaultSpec}, curve: CurveP521}
+	flagset.Var(keySpecFlag{f}, "key-spec", "Key to generate, one of rsa2048, rsa4096, ec256, ec384, ec521, ed25519 (certificate: comma separated list for one certificate per key)")
+	flagset.Var(&f.keyType, "key-type", "Key type to generate, RSA or ECDSA (deprecated, use -key-spec)")
+	flagset.Var(&f.curve, "curve", "Elliptic curve to generate ECDSA key with (deprecated, use -key-spec)")
+	flagset.IntVar(&f.rsaBits, "rsa-bits", 2048, "Number of bits to generate the RSA key with (deprecated, use -key-spec)")
+	return f
+}
+
+// all specs from -key-spec; the spec from -key-type (with -curve or
+// -rsa-bits) if only that was given
+func (f *KeySpecFlags) Specs() ([]KeySpec, error) {
+	if f.specSet || 0 == len(f.keyType) {
+		return f.specs, nil
+	}
+	spec, err := MakeKeySpec(f.keyType, f.curve, f.rsaBits)
+	if nil != err {
+		return nil, err
+	}
+	return []KeySpec{spec}, nil
+}
+
+// like Specs, but only a single spec is allowed
+func (f *KeySpecFlags) Spec() (KeySpec, error) {
+	specs, err := f.Specs()
+	if nil != err {
+		return "", err
+	}
+	if 1 != len(specs) {
+		return "", errors.New("Only a single key spec allowed")
+	}
+	return specs[0], nil
+}
+
+// whether the key spec was given explicitly
+func (f *KeySpecFlags) IsSet() bool {
+	return f.specSet || 0 != len(f.keyType)
+}
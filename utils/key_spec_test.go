@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestKeySpecParse(t *testing.T) {
+	for _, test := range []struct {
+		spec    KeySpec
+		keyType KeyType
+		curve   Curve
+		rsaBits int
+		err     error
+	}{
+		{KeySpecRSA2048, KeyRSA, "", 2048, nil},
+		{KeySpecRSA4096, KeyRSA, "", 4096, nil},
+		{"rsa3072", KeyRSA, "", 3072, nil},
+		{KeySpecEC256, KeyEcdsa, CurveP256, 0, nil},
+		{KeySpecEC384, KeyEcdsa, CurveP384, 0, nil},
+		{KeySpecEC521, KeyEcdsa, CurveP521, 0, nil},
+		{KeySpecEd25519, KeyEd25519, "", 0, nil},
+		{"rsa1024", "", "", 0, InvalidRsaBits},
+		{"rsa8192", "", "", 0, InvalidRsaBits},
+		{"rsa", "", "", 0, UnknownKeySpec},
+		{"rsax", "", "", 0, UnknownKeySpec},
+		{"ec224", "", "", 0, UnknownKeySpec},
+		{"EC256", "", "", 0, UnknownKeySpec},
+		{"", "", "", 0, UnknownKeySpec},
+	} {
+		keyType, curve, rsaBits, err := test.spec.Parse()
+		if test.err != err || test.keyType != keyType || test.curve != curve || test.rsaBits != rsaBits {
+			t.Errorf("%#v: expected %s %s %d %v, got %s %s %d %v", test.spec, test.keyType, test.curve, test.rsaBits, test.err, keyType, curve, rsaBits, err)
+		}
+		if nil == test.err {
+			// and back
+			if spec, err := MakeKeySpec(keyType, curve, rsaBits); nil != err || test.spec != spec {
+				t.Errorf("MakeKeySpec(%s, %s, %d): expected %s, got %s %v", keyType, curve, rsaBits, test.spec, spec, err)
+			}
+		}
+	}
+}
+
+func TestMakeKeySpec(t *testing.T) {
+	for _, test := range []struct {
+		keyType KeyType
+		curve   Curve
+		rsaBits int
+		spec    KeySpec
+		err     error
+	}{
+		// -curve defaults to P-521
+		{KeyEcdsa, "", 0, KeySpecEC521, nil},
+		{KeyEcdsa, "P-224", 0, "", UnknownCurve},
+		// -curve is ignored for other key types
+		{KeyRSA, CurveP256, 2048, KeySpecRSA2048, nil},
+		{KeyEd25519, CurveP256, 0, KeySpecEd25519, nil},
+		{"DSA", "", 0, "", UnknownKeyType},
+		{"", "", 0, "", UnknownKeyType},
+	} {
+		spec, err := MakeKeySpec(test.keyType, test.curve, test.rsaBits)
+		if test.err != err || test.spec != spec {
+			t.Errorf("MakeKeySpec(%s, %s, %d): expected %s %v, got %s %v", test.keyType, test.curve, test.rsaBits, test.spec, test.err, spec, err)
+		}
+	}
+}
+
+func TestKeySpecOf(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if nil != err {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	p224Key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name string
+		key  interface{}
+		spec KeySpec
+		err  error
+	}{
+		{"RSA private", rsaKey, KeySpecRSA2048, nil},
+		{"RSA public", &rsaKey.PublicKey, KeySpecRSA2048, nil},
+		{"ECDSA private", ecKey, KeySpecEC384, nil},
+		{"ECDSA public", &ecKey.PublicKey, KeySpecEC384, nil},
+		{"Ed25519 private", edPrivate, KeySpecEd25519, nil},
+		{"Ed25519 public", edPublic, KeySpecEd25519, nil},
+		{"ECDSA P-224", p224Key, "", UnknownCurve},
+	} {
+		spec, err := KeySpecOf(test.key)
+		if test.err != err || test.spec != spec {
+			t.Errorf("%s: expected %s %v, got %s %v", test.name, test.spec, test.err, spec, err)
+		}
+	}
+	if _, err := KeySpecOf("not a key"); nil == err {
+		t.Error("Got key spec for a string")
+	}
+}
+
+func TestKeySpecFlags(t *testing.T) {
+	for _, test := range []struct {
+		args  []string
+		specs string
+		isSet bool
+		err   string
+	}{
+		{nil, "rsa2048", false, ""},
+		{[]string{"-key-spec", "ec256"}, "ec256", true, ""},
+		{[]string{"-key-spec", "EC384, ed25519"}, "ec384,ed25519", true, ""},
+		// duplicates only give one certificate
+		{[]string{"-key-spec", "ec256,rsa2048,EC256,rsa2048"}, "ec256,rsa2048", true, ""},
+		// the last flag wins
+		{[]string{"-key-spec", "ec256,rsa2048", "-key-spec", "ed25519"}, "ed25519", true, ""},
+		{[]string{"-key-spec", "ec256,"}, "", false, "Unknown Key Spec"},
+		{[]string{"-key-spec", "rsa1024"}, "", false, "Invalid RSA bits"},
+		// deprecated flags
+		{[]string{"-key-type", "ECDSA"}, "ec521", true, ""},
+		{[]string{"-key-type", "ECDSA", "-curve", "P-384"}, "ec384", true, ""},
+		{[]string{"-key-type", "RSA", "-rsa-bits", "4096"}, "rsa4096", true, ""},
+		// -key-spec takes precedence
+		{[]string{"-key-type", "RSA", "-key-spec", "ec256"}, "ec256", true, ""},
+	} {
+		flagset := flag.NewFlagSet("test", flag.ContinueOnError)
+		flagset.SetOutput(ioutil.Discard)
+		f := AddKeySpecFlags(flagset, KeySpecRSA2048)
+		if err := flagset.Parse(test.args); nil != err {
+			if 0 == len(test.err) || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q: unexpected error %s", test.args, err)
+			}
+			continue
+		} else if 0 != len(test.err) {
+			t.Errorf("%q: expected error %#v", test.args, test.err)
+			continue
+		}
+		specs, err := f.Specs()
+		if nil != err {
+			t.Errorf("%q: %s", test.args, err)
+			continue
+		}
+		var names []string
+		for _, spec := range specs {
+			names = append(names, string(spec))
+		}
+		if test.specs != strings.Join(names, ",") || test.isSet != f.IsSet() {
+			t.Errorf("%q: expected %s (set %v), got %v (set %v)", test.args, test.specs, test.isSet, names, f.IsSet())
+		}
+		if _, err := f.Spec(); (1 == len(specs)) != (nil == err) {
+			t.Errorf("%q: unexpected single spec error %v", test.args, err)
+		}
+	}
+}
@@ -1,14 +1,20 @@
 package utils
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"io"
+	"io/ioutil"
 )
 
 type KeyType string
@@ -16,6 +22,9 @@ type KeyType string
 const (
 	KeyEcdsa KeyType = "ECDSA"
 	KeyRSA   KeyType = "RSA"
+	// registrations sign with EdDSA (RFC 8037), which the server needs to
+	// support
+	KeyEd25519 KeyType = "Ed25519"
 )
 
 type Curve string
@@ -35,6 +44,18 @@ var InvalidRsaBits = errors.New("Invalid RSA bits (must be in range 2048..4096)"
 const pemTypeEcPrivateKey = "EC PRIVATE KEY"
 const pemTypeRsaPrivateKey = "RSA PRIVATE KEY"
 const pemTypePublicKey = "PUBLIC KEY"
+const pemTypePrivateKey = "PRIVATE KEY" // PKCS#8
+
+// file formats for private keys
+type KeyFormat string
+
+const (
+	KeyFormatPEM   KeyFormat = "pem"   // "EC PRIVATE KEY" / "RSA PRIVATE KEY"
+	KeyFormatPKCS8 KeyFormat = "pkcs8" // "PRIVATE KEY" / "ENCRYPTED PRIVATE KEY"
+	KeyFormatJWK   KeyFormat = "jwk"
+)
+
+var UnknownKeyFormat = errors.New("Unknown Key Format")
 
 func CreateEcdsaPrivateKey(curve elliptic.Curve) (*ecdsa.PrivateKey, error) {
 	return ecdsa.GenerateKey(curve, rand.Reader)
@@ -44,7 +65,19 @@ func CreateRsaPrivateKey(bits int) (*rsa.PrivateKey, error) {
 	return rsa.GenerateKey(rand.Reader, bits)
 }
 
+// generated keys are checked with CheckKey too
 func CreatePrivateKey(keyType KeyType, curve Curve, rsaBits *int) (interface{}, error) {
+	privateKey, err := createPrivateKey(keyType, curve, rsaBits)
+	if nil != err {
+		return nil, err
+	}
+	if err := CheckKey(privateKey); nil != err {
+		return nil, err
+	}
+	return privateKey, nil
+}
+
+func createPrivateKey(keyType KeyType, curve Curve, rsaBits *int) (interface{}, error) {
 	switch keyType {
 	case KeyEcdsa:
 		switch curve {
@@ -68,6 +101,12 @@ func CreatePrivateKey(keyType KeyType, curve Curve, rsaBits *int) (interface{},
 			return nil, InvalidRsaBits
 		}
 		return CreateRsaPrivateKey(bits)
+	case KeyEd25519:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if nil != err {
+			return nil, err
+		}
+		return privateKey, nil
 	default:
 		return nil, UnknownKeyType
 	}
@@ -86,6 +125,13 @@ func PublicKey(privateKey interface{}) (pubKey interface{}, err error) {
 		pubKey = pkey
 	case *rsa.PrivateKey:
 		pubKey = &pkey.PublicKey
+	case ed25519.PublicKey:
+		pubKey = pkey
+	case ed25519.PrivateKey:
+		pubKey = pkey.Public()
+	case crypto.Signer:
+		// keys on tokens, in agents, ...
+		pubKey, err = PublicKey(pkey.Public())
 	default:
 		err = UnknownPrivateKey
 	}
@@ -100,12 +146,27 @@ func MustPublicKey(privateKey interface{}) interface{} {
 	return pubKey
 }
 
+// SHA-256 of the DER encoded SubjectPublicKeyInfo, as "SHA256:" and
+// unpadded base64 (not comparable to OpenSSH fingerprints, which hash the
+// SSH key blob)
+func PublicKeyFingerprint(key interface{}) (string, error) {
+	pubKey, err := PublicKey(key)
+	if nil != err {
+		return "", err
+	}
+	der, err := x509.MarshalPKIXPublicKey(pubKey)
+	if nil != err {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
+
 func PickSignatureAlgorithm(privateKey interface{}, defaultAlg x509.SignatureAlgorithm) x509.SignatureAlgorithm {
-	switch pkey := privateKey.(type) {
-	case *ecdsa.PrivateKey:
+	pubKey, _ := PublicKey(privateKey)
+	switch pkey := pubKey.(type) {
+	case *ecdsa.PublicKey:
 		switch pkey.Curve {
-		case elliptic.P224():
-			return x509.ECDSAWithSHA256
 		case elliptic.P256():
 			return x509.ECDSAWithSHA256
 		case elliptic.P384():
@@ -113,6 +174,8 @@ func PickSignatureAlgorithm(privateKey interface{}, defaultAlg x509.SignatureAlg
 		case elliptic.P521():
 			return x509.ECDSAWithSHA512
 		}
+	case ed25519.PublicKey:
+		return x509.PureEd25519
 	}
 	return defaultAlg
 }
@@ -133,6 +196,9 @@ func EncodePrivateKey(privateKey interface{}) (*pem.Block, error) {
 			Type:  pemTypeRsaPrivateKey,
 			Bytes: x509.MarshalPKCS1PrivateKey(pkey),
 		}, nil
+	case ed25519.PrivateKey:
+		// no traditional format
+		return EncodePKCS8PrivateKey(pkey)
 	default:
 		return nil, UnknownPrivateKey
 	}
@@ -144,11 +210,70 @@ func DecodePrivateKey(block pem.Block) (interface{}, error) {
 		return x509.ParseECPrivateKey(block.Bytes)
 	case pemTypeRsaPrivateKey:
 		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case pemTypePrivateKey:
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if nil != err {
+			return nil, err
+		}
+		switch privateKey.(type) {
+		case *ecdsa.PrivateKey, *rsa.PrivateKey, ed25519.PrivateKey:
+			return privateKey, nil
+		default:
+			return nil, UnknownPrivateKey
+		}
+	case pemTypeEncryptedPrivateKey:
+		return nil, errors.New("Encrypted PKCS#8 key needs to be decrypted first")
 	default:
 		return nil, UnknownPrivateKey
 	}
 }
 
+func EncodePKCS8PrivateKey(privateKey interface{}) (*pem.Block, error) {
+	data, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if nil != err {
+		return nil, err
+	}
+	return &pem.Block{
+		Type:  pemTypePrivateKey,
+		Bytes: data,
+	}, nil
+}
+
+// encodes the private key in the given format; an empty password means no
+// encryption (JWK can't be encrypted)
+func ExportPrivateKey(privateKey interface{}, format KeyFormat, password string) ([]byte, error) {
+	switch format {
+	case KeyFormatPEM:
+		block, err := EncryptPrivateKey(privateKey, password, PemDefaultCipher)
+		if nil != err {
+			return nil, err
+		}
+		return pem.EncodeToMemory(block), nil
+	case KeyFormatPKCS8:
+		block, err := EncodePKCS8PrivateKey(privateKey)
+		if nil != err {
+			return nil, err
+		}
+		if 0 != len(password) {
+			if block, err = EncryptPKCS8(block.Bytes, password); nil != err {
+				return nil, err
+			}
+		}
+		return pem.EncodeToMemory(block), nil
+	case KeyFormatJWK:
+		if 0 != len(password) {
+			return nil, errors.New("JWK keys can't be encrypted")
+		}
+		data, err := MarshalJWKPrivateKey(privateKey)
+		if nil != err {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	default:
+		return nil, UnknownKeyFormat
+	}
+}
+
 func EncryptPrivateKey(privateKey interface{}, password string, alg x509.PEMCipher) (*pem.Block, error) {
 	if block, err := EncodePrivateKey(privateKey); nil != err {
 		return nil, err
@@ -159,8 +284,16 @@ func EncryptPrivateKey(privateKey interface{}, password string, alg x509.PEMCiph
 	}
 }
 
+// accepts PEM (traditional and PKCS#8, optionally encrypted) and JWK files
 func LoadFirstPrivateKey(r io.Reader, prompt func() (string, error)) (interface{}, error) {
-	if block, err := FirstPemBlock(r, pemTypeEcPrivateKey, pemTypeRsaPrivateKey); nil != err {
+	data, err := ioutil.ReadAll(r)
+	if nil != err {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); 0 != len(trimmed) && '{' == trimmed[0] {
+		return ParseJWKPrivateKey(trimmed)
+	}
+	if block, err := FirstPemBlock(bytes.NewReader(data), pemTypeEcPrivateKey, pemTypeRsaPrivateKey, pemTypePrivateKey, pemTypeEncryptedPrivateKey); nil != err {
 		return nil, err
 	} else if err := DecryptPemBlock(block, prompt); nil != err {
 		return nil, err
@@ -236,6 +369,8 @@ func (keyType KeyType) IsValid() bool {
 		return true
 	case KeyRSA:
 		return true
+	case KeyEd25519:
+		return true
 	default:
 		return false
 	}
@@ -254,3 +389,30 @@ func (keyType *KeyType) Set(v string) error {
 		return UnknownKeyType
 	}
 }
+
+func (format KeyFormat) IsValid() bool {
+	switch format {
+	case KeyFormatPEM:
+		return true
+	case KeyFormatPKCS8:
+		return true
+	case KeyFormatJWK:
+		return true
+	default:
+		return false
+	}
+}
+
+func (format *KeyFormat) String() string {
+	return string(*format)
+}
+
+func (format *KeyFormat) Set(v string) error {
+	f := KeyFormat(v)
+	if f.IsValid() {
+		*format = f
+		return nil
+	} else {
+		return UnknownKeyFormat
+	}
+}
@@ -32,8 +32,18 @@ func EncryptPemBlock(block *pem.Block, password string, alg x509.PEMCipher) erro
 	return nil
 }
 
+// also decrypts encrypted PKCS#8 keys (to "PRIVATE KEY" blocks)
 func DecryptPemBlock(block *pem.Block, prompt func() (string, error)) (err error) {
-	if x509.IsEncryptedPEMBlock(block) {
+	if pemTypeEncryptedPrivateKey == block.Type {
+		if password, err := prompt(); nil != err {
+			return err
+		} else if data, err := DecryptPKCS8(block.Bytes, password); nil != err {
+			return err
+		} else {
+			block.Type = pemTypePrivateKey
+			block.Bytes = data
+		}
+	} else if x509.IsEncryptedPEMBlock(block) {
 		if password, err := prompt(); nil != err {
 			return err
 		} else if data, err := x509.DecryptPEMBlock(block, []byte(password)); nil != err {
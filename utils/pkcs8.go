@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// encrypted PKCS#8 (RFC 5958) with PBES2 (RFC 8018), as written by
+// "openssl pkcs8 -topk8" and "openssl genpkey -aes256"
+
+const pemTypeEncryptedPrivateKey = "ENCRYPTED PRIVATE KEY"
+
+// iterations used when encrypting
+const pkcs8Iterations = 100000
+
+// upper limit for the PBKDF2 iteration count of keys to decrypt; a file
+// could otherwise keep us busy for ages
+const maxPkcs8Iterations = 10000000
+
+var IncorrectPKCS8Password = errors.New("PKCS#8 decryption failed (wrong password?)")
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHmacWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHmacWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHmacWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHmacWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC     = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// RFC 8018 section 5.2
+func pbkdf2Key(password, salt []byte, iterations, keyLen int, h func() hash.Hash) []byte {
+	prf := hmac.New(h, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:4])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+
+		for n := 2; n <= iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = u[:0]
+			u = prf.Sum(u)
+			for x := range u {
+				t[x] ^= u[x]
+			}
+		}
+	}
+	return dk[:keyLen]
+}
+
+func pkcs8Cipher(scheme pkix.AlgorithmIdentifier) (newCipher func(key []byte) (cipher.Block, error), keyLen int, err error) {
+	switch {
+	case scheme.Algorithm.Equal(oidAES128CBC):
+		return aes.NewCipher, 16, nil
+	case scheme.Algorithm.Equal(oidAES192CBC):
+		return aes.NewCipher, 24, nil
+	case scheme.Algorithm.Equal(oidAES256CBC):
+		return aes.NewCipher, 32, nil
+	case scheme.Algorithm.Equal(oidDESEDE3CBC):
+		return des.NewTripleDESCipher, 24, nil
+	default:
+		return nil, 0, fmt.Errorf("Unsupported PKCS#8 encryption scheme %s", scheme.Algorithm)
+	}
+}
+
+func pkcs8PRF(prf pkix.AlgorithmIdentifier) (func() hash.Hash, error) {
+	switch {
+	case 0 == len(prf.Algorithm), prf.Algorithm.Equal(oidHmacWithSHA1):
+		return sha1.New, nil
+	case prf.Algorithm.Equal(oidHmacWithSHA256):
+		return sha256.New, nil
+	case prf.Algorithm.Equal(oidHmacWithSHA384):
+		return sha512.New384, nil
+	case prf.Algorithm.Equal(oidHmacWithSHA512):
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("Unsupported PBKDF2 PRF %s", prf.Algorithm)
+	}
+}
+
+// DecryptPKCS8 returns the DER encoded (unencrypted) PKCS#8 key
+func DecryptPKCS8(der []byte, password string) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); nil != err {
+		return nil, err
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("Unsupported PKCS#8 encryption %s (only PBES2 is supported)", info.Algorithm.Algorithm)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); nil != err {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("Unsupported PBES2 key derivation %s", params.KeyDerivationFunc.Algorithm)
+	}
+	var kdfParams pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); nil != err {
+		return nil, err
+	}
+	if kdfParams.IterationCount < 1 || kdfParams.IterationCount > maxPkcs8Iterations {
+		return nil, fmt.Errorf("Unsupported PBKDF2 iteration count %d (at most %d)", kdfParams.IterationCount, maxPkcs8Iterations)
+	}
+	prf, err := pkcs8PRF(kdfParams.PRF)
+	if nil != err {
+		return nil, err
+	}
+	newCipher, keyLen, err := pkcs8Cipher(params.EncryptionScheme)
+	if nil != err {
+		return nil, err
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); nil != err {
+		return nil, err
+	}
+
+	key := pbkdf2Key([]byte(password), kdfParams.Salt, kdfParams.IterationCount, keyLen, prf)
+	block, err := newCipher(key)
+	if nil != err {
+		return nil, err
+	}
+	data := info.EncryptedData
+	if len(iv) != block.BlockSize() || 0 == len(data) || 0 != len(data)%block.BlockSize() {
+		return nil, IncorrectPKCS8Password
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+
+	// PKCS#7 padding
+	padLen := int(plain[len(plain)-1])
+	if 0 == padLen || padLen > block.BlockSize() {
+		return nil, IncorrectPKCS8Password
+	}
+	for _, b := range plain[len(plain)-padLen:] {
+		if int(b) != padLen {
+			return nil, IncorrectPKCS8Password
+		}
+	}
+	// the padding check passes for some wrong passwords, the result must
+	// be a single DER SEQUENCE too
+	plain = plain[:len(plain)-padLen]
+	var seq asn1.RawValue
+	if rest, err := asn1.Unmarshal(plain, &seq); nil != err || 0 != len(rest) || asn1.TagSequence != seq.Tag {
+		return nil, IncorrectPKCS8Password
+	}
+	return plain, nil
+}
+
+// EncryptPKCS8 encrypts the DER encoded PKCS#8 key with
+// PBKDF2-HMAC-SHA256 and AES-256-CBC
+func EncryptPKCS8(der []byte, password string) (*pem.Block, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); nil != err {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); nil != err {
+		return nil, err
+	}
+
+	key := pbkdf2Key([]byte(password), salt, pkcs8Iterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if nil != err {
+		return nil, err
+	}
+	padLen := aes.BlockSize - len(der)%aes.BlockSize
+	data := make([]byte, len(der)+padLen)
+	copy(data, der)
+	for i := len(der); i < len(data); i++ {
+		data[i] = byte(padLen)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: pkcs8Iterations,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHmacWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if nil != err {
+		return nil, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if nil != err {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if nil != err {
+		return nil, err
+	}
+	encrypted, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: data,
+	})
+	if nil != err {
+		return nil, err
+	}
+	return &pem.Block{
+		Type:  pemTypeEncryptedPrivateKey,
+		Bytes: encrypted,
+	}, nil
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// policy for keys used for registrations and certificate requests
+
+// minimum size of RSA moduli
+var MinRsaBits = 2048
+
+// directory with the Debian openssl-blacklist files (blacklist.RSA-*),
+// loaded on first use if it exists
+var DebianBlocklistDir = "/usr/share/openssl-blacklist"
+
+type WeakKeyError struct {
+	Reason string
+}
+
+func (e WeakKeyError) Error() string {
+	return "Weak key refused: " + e.Reason
+}
+
+func weakKey(format string, args ...interface{}) error {
+	return WeakKeyError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// CheckKey returns a WeakKeyError if the (public or private) key must not
+// be used
+func CheckKey(key interface{}) error {
+	publicKey, err := PublicKey(key)
+	if nil != err {
+		return err
+	}
+	switch pubKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		return checkRsaKey(pubKey)
+	case *ecdsa.PublicKey:
+		return checkEcdsaKey(pubKey)
+	case ed25519.PublicKey:
+		if ed25519.PublicKeySize != len(pubKey) {
+			return weakKey("invalid Ed25519 public key")
+		}
+		return nil
+	default:
+		return weakKey("unsupported key type %T", publicKey)
+	}
+}
+
+func checkRsaKey(pubKey *rsa.PublicKey) error {
+	if bits := pubKey.N.BitLen(); bits < MinRsaBits {
+		return weakKey("RSA modulus has only %d bits (policy requires at least %d)", bits, MinRsaBits)
+	}
+	if 65537 != pubKey.E {
+		return weakKey("RSA public exponent %d is not allowed (only 65537)", pubKey.E)
+	}
+	if factor := smallFactor(pubKey.N); 0 != factor {
+		return weakKey("RSA modulus is divisible by %d", factor)
+	}
+	if rocaVulnerable(pubKey.N) {
+		return weakKey("RSA modulus was generated by a ROCA-vulnerable (CVE-2017-15361) implementation")
+	}
+	if debianBlocklisted(pubKey) {
+		return weakKey("RSA key is on the Debian weak key blocklist (CVE-2008-0166)")
+	}
+	return nil
+}
+
+func checkEcdsaKey(pubKey *ecdsa.PublicKey) error {
+	switch pubKey.Curve {
+	case elliptic.P256(), elliptic.P384(), elliptic.P521():
+	default:
+		return weakKey("elliptic curve %s is not supported (use P-256, P-384 or P-521)", pubKey.Curve.Params().Name)
+	}
+	if !pubKey.Curve.IsOnCurve(pubKey.X, pubKey.Y) {
+		return weakKey("public key is not on curve %s", pubKey.Curve.Params().Name)
+	}
+	return nil
+}
+
+var smallPrimes = func() []int64 {
+	var primes []int64
+	for n := int64(2); n < 1000; n++ {
+		if big.NewInt(n).ProbablyPrime(0) {
+			primes = append(primes, n)
+		}
+	}
+	return primes
+}()
+
+func smallFactor(n *big.Int) int64 {
+	var m big.Int
+	for _, p := range smallPrimes {
+		if 0 == m.Mod(n, big.NewInt(p)).Sign() {
+			return p
+		}
+	}
+	return 0
+}
+
+// ROCA fingerprint (Nemec et al., "The Return of Coppersmith's Attack"):
+// moduli from the vulnerable library are powers of 65537 modulo each of
+// these primes
+var rocaPrimes = []int64{3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67, 71, 73, 79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131, 137, 139, 149, 151, 157, 163, 167}
+
+var rocaOnce sync.Once
+var rocaPowers []map[int64]bool
+
+func rocaVulnerable(n *big.Int) bool {
+	rocaOnce.Do(func() {
+		for _, p := range rocaPrimes {
+			powers := make(map[int64]bool)
+			for x := int64(1); !powers[x]; x = (x * 65537) % p {
+				powers[x] = true
+			}
+			rocaPowers = append(rocaPowers, powers)
+		}
+	})
+	var m big.Int
+	for ndx, p := range rocaPrimes {
+		if !rocaPowers[ndx][m.Mod(n, big.NewInt(p)).Int64()] {
+			return false
+		}
+	}
+	return true
+}
+
+var debianBlocklistMutex sync.Mutex
+var debianBlocklistLoaded bool
+var debianBlocklist = make(map[string]bool)
+
+// LoadDebianBlocklist adds a file in the openssl-blacklist format (last 20
+// hex digits of the SHA-1 of "Modulus=<HEX>\n", one per line)
+func LoadDebianBlocklist(path string) error {
+	file, err := os.Open(path)
+	if nil != err {
+		return err
+	}
+	defer file.Close()
+
+	debianBlocklistMutex.Lock()
+	defer debianBlocklistMutex.Unlock()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if 0 == len(line) || strings.HasPrefix(line, "#") {
+			continue
+		}
+		debianBlocklist[strings.ToLower(line)] = true
+	}
+	return scanner.Err()
+}
+
+func debianBlocklisted(pubKey *rsa.PublicKey) bool {
+	debianBlocklistMutex.Lock()
+	if !debianBlocklistLoaded {
+		debianBlocklistLoaded = true
+		debianBlocklistMutex.Unlock()
+		files, _ := filepath.Glob(filepath.Join(DebianBlocklistDir, "blacklist.RSA-*"))
+		for _, file := range files {
+			if err := LoadDebianBlocklist(file); nil != err {
+				Warningf("Couldn't load weak key blocklist %s: %s", file, err)
+			}
+		}
+		debianBlocklistMutex.Lock()
+	}
+	defer debianBlocklistMutex.Unlock()
+
+	digest := sha1.Sum([]byte(fmt.Sprintf("Modulus=%X\n", pubKey.N)))
+	return debianBlocklist[hex.EncodeToString(digest[:])[20:]]
+}
+
+type blocklistFlag struct{}
+
+func (blocklistFlag) String() string {
+	return ""
+}
+
+func (blocklistFlag) Set(path string) error {
+	return LoadDebianBlocklist(path)
+}
+
+func AddKeyCheckFlags(flagset *flag.FlagSet) {
+	flagset.IntVar(&MinRsaBits, "min-rsa-bits", MinRsaBits, "Refuse RSA keys with fewer bits")
+	flagset.Var(blocklistFlag{}, "weak-key-blocklist", "Additional weak key blocklist in openssl-blacklist format (can be given multiple times)")
+}
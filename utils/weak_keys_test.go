@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	mathrand "math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// created with openssl:
+//
+//	openssl genrsa -out key.pem 2048
+//	openssl rsa -in key.pem -pubout
+//	openssl rsa -in key.pem -noout -modulus | sha1sum | cut -c21-40
+const debianFixtureKey = `-----BEGIN PUBLIC KEY-----
+MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAydCa9FAwj8WNYMVJIjoT
+pM+FJi9B6Lz5Q/vioyCkyFR/4Zroj+YT5N8MvydD8LsRoa0gsNuVWSd/6KEhWZLf
+YhkaiO8q9ZueIJXRK5KT6FT5XrGrGg7tZhP9YvSC/PEHZbyv5r3YRchTqiceUyw8
+Izw0ay2efCGfPDw14RiGb293gzmvNaNSNEFzq2KSCBesge6aCVyP0C0oBhz0XH9U
+JqC2k8Ddz/cwjDDmWsdJxtFQ2CJ0m4jI1JBfmStJfJPK776WuNqELOHSaizk1oZL
+WHBf/kVSq0mQMubES0oTkWDNv3tzurMfBe8pLbVSoHHVwV5+qV5o7IiJon/Uhl+K
+/QIDAQAB
+-----END PUBLIC KEY-----
+`
+
+const debianFixtureLine = "d55ccdcb45d17884cb98"
+
+// prime of the form k * M + (65537^a mod M) with M the product of the
+// first 39 primes, as generated by the ROCA-vulnerable library; the top two
+// bits are set, so the product of two has 2 * bits bits
+func rocaPrime(rnd *mathrand.Rand, bits int) *big.Int {
+	m := big.NewInt(2)
+	for _, p := range rocaPrimes {
+		m.Mul(m, big.NewInt(p))
+	}
+	generator := new(big.Int).Exp(big.NewInt(65537), big.NewInt(rnd.Int63()), m)
+	// k in [3 * 2^(bits-2) / M, 2^bits / M)
+	low := new(big.Int).Div(new(big.Int).Lsh(big.NewInt(3), uint(bits-2)), m)
+	low.Add(low, big.NewInt(1))
+	high := new(big.Int).Div(new(big.Int).Lsh(big.NewInt(1), uint(bits)), m)
+	span := new(big.Int).Sub(high, low)
+	for {
+		k := new(big.Int).Rand(rnd, span)
+		p := k.Add(k, low).Mul(k, m).Add(k, generator)
+		if p.ProbablyPrime(20) {
+			return p
+		}
+	}
+}
+
+func mustDecodePublicKey(t *testing.T, data string) interface{} {
+	block, _ := pem.Decode([]byte(data))
+	if nil == block {
+		t.Fatal("Invalid PEM fixture")
+	}
+	publicKey, err := DecodePublicKey(*block)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return publicKey
+}
+
+func TestCheckKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if nil != err {
+		t.Fatal(err)
+	}
+	shortRsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if nil != err {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	p224Key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	edPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	rnd := mathrand.New(mathrand.NewSource(1))
+	rocaModulus := new(big.Int).Mul(rocaPrime(rnd, 1024), rocaPrime(rnd, 1024))
+	if !rocaVulnerable(rocaModulus) {
+		t.Fatal("ROCA fingerprint not detected")
+	}
+
+	blocklist := filepath.Join(t.TempDir(), "blacklist.RSA-2048")
+	if err := ioutil.WriteFile(blocklist, []byte("# fixture\n"+debianFixtureLine+"\n"), 0600); nil != err {
+		t.Fatal(err)
+	}
+	if err := LoadDebianBlocklist(blocklist); nil != err {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		key    interface{}
+		reason string
+	}{
+		{"RSA 2048", rsaKey, ""},
+		{"RSA 2048 public", &rsaKey.PublicKey, ""},
+		{"ECDSA P-256", ecKey, ""},
+		{"Ed25519", edPublic, ""},
+		{"RSA 1024", shortRsaKey, "only 1024 bits"},
+		{"RSA e=3", &rsa.PublicKey{N: rsaKey.N, E: 3}, "exponent 3"},
+		{"RSA small factor", &rsa.PublicKey{N: new(big.Int).Mul(rsaKey.N, big.NewInt(7)), E: 65537}, "divisible by 7"},
+		{"RSA ROCA", &rsa.PublicKey{N: rocaModulus, E: 65537}, "ROCA"},
+		{"RSA Debian", mustDecodePublicKey(t, debianFixtureKey), "Debian"},
+		{"ECDSA P-224", p224Key, "P-224 is not supported"},
+		{"ECDSA P-224 public", &p224Key.PublicKey, "P-224 is not supported"},
+		{"ECDSA off curve", &ecdsa.PublicKey{Curve: elliptic.P256(), X: big.NewInt(1), Y: big.NewInt(1)}, "not on curve"},
+		{"Ed25519 truncated", edPublic[:16], "invalid Ed25519"},
+	} {
+		err := CheckKey(test.key)
+		if 0 == len(test.reason) {
+			if nil != err {
+				t.Errorf("%s: unexpected error %s", test.name, err)
+			}
+			continue
+		}
+		if _, ok := err.(WeakKeyError); !ok || !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("%s: expected weak key error with %#v, got %v", test.name, test.reason, err)
+		}
+	}
+
+	// the policy can be relaxed
+	defer func(bits int) { MinRsaBits = bits }(MinRsaBits)
+	MinRsaBits = 1024
+	if err := CheckKey(shortRsaKey); nil != err {
+		t.Errorf("RSA 1024 with -min-rsa-bits 1024: %s", err)
+	}
+}
@@ -0,0 +1,83 @@
+package webroot
+
+import (
+	"flag"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/solver_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+)
+
+const SolverName = "webroot"
+
+// places verification files in the configured document roots
+type webrootSolver struct {
+	webroots Webroots
+	// maps challenge uri to placed file
+	placed map[string]*File
+}
+
+var Solver = &webrootSolver{
+	placed: make(map[string]*File),
+}
+
+func init() {
+	solver_interface.Register(types.SimpleHttpIdentifier, SolverName, Solver)
+}
+
+func (solver *webrootSolver) AddFlags(flags *flag.FlagSet) {
+	flags.Var(&solver.webroots, "webroot", "Document root to place simpleHttp verification files in, either PATH or DOMAIN=PATH (can be repeated)")
+}
+
+func (solver *webrootSolver) Configured(domain string) bool {
+	_, ok := solver.webroots.Lookup(domain)
+	return ok
+}
+
+func (solver *webrootSolver) Present(UI ui.UserInterface, response types.ChallengeResponding) error {
+	fileResp, ok := response.(types.ChallengeFileResponding)
+	if !ok {
+		return fmt.Errorf("Challenge response %T can't be served from a webroot", response)
+	}
+	root, ok := solver.webroots.Lookup(response.DNSIdentifier())
+	if !ok {
+		return fmt.Errorf("No webroot configured for %s", response.DNSIdentifier())
+	}
+
+	// replace file from an earlier attempt
+	if err := solver.Cleanup(UI, response); nil != err {
+		return err
+	}
+
+	content, err := fileResp.VerificationFile()
+	if nil != err {
+		return err
+	}
+	file, err := Write(root, fileResp.WellKnownPath(), []byte(content))
+	if nil != err {
+		return err
+	}
+	challenge := response.Challenge()
+	solver.placed[challenge.GetURI()] = file
+	UI.Messagef("Placed verification file at %s", file.Path)
+	return nil
+}
+
+func (solver *webrootSolver) Verify(UI ui.UserInterface, response types.ChallengeResponding) error {
+	return response.Verify()
+}
+
+func (solver *webrootSolver) Cleanup(UI ui.UserInterface, response types.ChallengeResponding) error {
+	challenge := response.Challenge()
+	file := solver.placed[challenge.GetURI()]
+	if nil == file {
+		return nil
+	}
+	delete(solver.placed, challenge.GetURI())
+	if err := file.Remove(); nil != err {
+		return fmt.Errorf("Couldn't remove verification file %s: %s", file.Path, err)
+	}
+	utils.Infof("Removed verification file %s", file.Path)
+	return nil
+}
@@ -0,0 +1,165 @@
+package webroot
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const fileMode os.FileMode = 0644
+const dirMode os.FileMode = 0755
+
+var InvalidPath = errors.New("Verification file path escapes the document root")
+
+// maps domain names to document roots; the empty domain is the fallback for
+// all domains without an explicit entry
+type Webroots map[string]string
+
+// find document root for a domain
+func (webroots Webroots) Lookup(domain string) (string, bool) {
+	if root, ok := webroots[domain]; ok {
+		return root, true
+	}
+	root, ok := webroots[""]
+	return root, ok
+}
+
+func (webroots *Webroots) String() string {
+	var entries []string
+	for domain, root := range *webroots {
+		if 0 == len(domain) {
+			entries = append(entries, root)
+		} else {
+			entries = append(entries, domain+"="+root)
+		}
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// accepts either "PATH" (fallback root) or "DOMAIN=PATH"
+func (webroots *Webroots) Set(v string) error {
+	var domain, root string
+	if ndx := strings.Index(v, "="); -1 != ndx {
+		domain, root = v[:ndx], v[ndx+1:]
+		if 0 == len(domain) {
+			return fmt.Errorf("Empty domain in webroot %#v", v)
+		}
+	} else {
+		root = v
+	}
+	if 0 == len(root) {
+		return fmt.Errorf("Empty path in webroot %#v", v)
+	}
+	if nil == *webroots {
+		*webroots = make(Webroots)
+	}
+	(*webroots)[domain] = root
+	return nil
+}
+
+// a verification file placed in a document root
+type File struct {
+	Path string
+	// directories created for the file, innermost first
+	createdDirs []string
+}
+
+// Write creates the file relPath below root with the exact given content.
+// Missing directories are created and remembered so Remove can clean them up
+// again; the file is written to a temporary name first and then renamed, so
+// the web server never sees a partial file.
+func Write(root string, relPath string, content []byte) (*File, error) {
+	root = filepath.Clean(root)
+	path := filepath.Join(root, filepath.FromSlash(relPath))
+	if !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return nil, InvalidPath
+	}
+
+	file := &File{Path: path}
+
+	if err := file.createDirs(root, filepath.Dir(path)); nil != err {
+		file.removeDirs()
+		return nil, err
+	}
+
+	if err := writeFileAtomic(path, content); nil != err {
+		file.removeDirs()
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Remove deletes the file and all directories created for it (if they are
+// empty by now)
+func (file *File) Remove() error {
+	if err := os.Remove(file.Path); nil != err && !os.IsNotExist(err) {
+		return err
+	}
+	file.removeDirs()
+	return nil
+}
+
+func (file *File) createDirs(root string, dir string) error {
+	if dir == root {
+		return nil
+	}
+	if info, err := os.Stat(dir); nil == err {
+		if !info.IsDir() {
+			return fmt.Errorf("%s exists but is not a directory", dir)
+		}
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := file.createDirs(root, filepath.Dir(dir)); nil != err {
+		return err
+	}
+	if err := os.Mkdir(dir, dirMode); nil != err {
+		return err
+	}
+	// umask might have removed permissions
+	if err := os.Chmod(dir, dirMode); nil != err {
+		return err
+	}
+	file.createdDirs = append([]string{dir}, file.createdDirs...)
+	return nil
+}
+
+func (file *File) removeDirs() {
+	for _, dir := range file.createdDirs {
+		// fails if the directory isn't empty, which is fine
+		if nil != os.Remove(dir) {
+			break
+		}
+	}
+	file.createdDirs = nil
+}
+
+func writeFileAtomic(path string, content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".acme-tmp-")
+	if nil != err {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(content); nil != err {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	} else if err := tmp.Close(); nil != err {
+		os.Remove(tmpName)
+		return err
+	} else if err := os.Chmod(tmpName, fileMode); nil != err {
+		os.Remove(tmpName)
+		return err
+	} else if err := os.Rename(tmpName, path); nil != err {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}